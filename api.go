@@ -11,6 +11,7 @@ import (
 	genericregistry "k8s.io/apiserver/pkg/registry/generic"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder"
+	restbuilder "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	billingv1 "github.com/appuio/control-api/apis/billing/v1"
@@ -31,19 +32,32 @@ import (
 func APICommand() *cobra.Command {
 	roles := []string{}
 	usernamePrefix := ""
+	orgRoles := &organizationRoleFlags{}
+	invValidity := &invitationValidityFlags{}
+	invHandoff := &invitationTokenHandoffFlags{}
 	var allowEmptyBillingEntity, skipBillingEntityValidation bool
 
 	ob := &odooStorageBuilder{}
 	ost := orgStore.New(&roles, &usernamePrefix, &allowEmptyBillingEntity, &skipBillingEntityValidation)
-	ib := &invitationStorageBuilder{usernamePrefix: &usernamePrefix}
+	ib := &invitationStorageBuilder{usernamePrefix: &usernamePrefix, validity: invValidity, handoff: invHandoff}
+	jb := &joinRequestStorageBuilder{usernamePrefix: &usernamePrefix}
 
 	cmd, err := builder.APIServer.
 		WithResourceAndHandler(&orgv1.Organization{}, ost).
 		WithResourceAndHandler(organizationStatusRegisterer{&orgv1.Organization{}}, ost).
+		WithResourceAndHandler(user.NewSubResourceRegisterer(&orgv1.Organization{}, "leave", &orgv1.OrganizationLeaveRequest{}), orgStore.NewLeaveStorage(&usernamePrefix, &orgRoles.rolePrefix, &orgRoles.adminRoles, &orgRoles.memberRoles, &orgRoles.allowedMemberRoles)).
 		WithResourceAndHandler(&billingv1.BillingEntity{}, ob.Build).
 		WithResourceAndHandler(&userv1.Invitation{}, ib.Build).
 		WithResourceAndHandler(secretstorage.NewStatusSubResourceRegisterer(&userv1.Invitation{}), ib.Build).
+		WithResourceAndHandler(user.NewSubResourceRegisterer(&userv1.Invitation{}, "revoke", &userv1.InvitationRevokeRequest{}), ib.BuildRevoke).
+		WithResourceAndHandler(user.NewSubResourceRegisterer(&userv1.Invitation{}, "resend", &userv1.InvitationResendRequest{}), ib.BuildResend).
 		WithResourceAndHandler(&userv1.InvitationRedeemRequest{}, ib.BuildRedeem).
+		WithResourceAndHandler(&userv1.InvitationPreview{}, ib.BuildPreview).
+		WithResourceAndHandler(&userv1.JoinRequest{}, jb.Build).
+		WithResourceAndHandler(secretstorage.NewStatusSubResourceRegisterer(&userv1.JoinRequest{}), jb.Build).
+		WithResourceAndHandler(user.NewSubResourceRegisterer(&userv1.JoinRequest{}, "approve", &userv1.JoinRequestApproveRequest{}), jb.BuildApprove).
+		WithResourceAndHandler(user.NewSubResourceRegisterer(&userv1.JoinRequest{}, "reject", &userv1.JoinRequestRejectRequest{}), jb.BuildReject).
+		WithAdditionalSchemeInstallers(addSecretStorageFieldLabelConversionFuncs).
 		WithoutEtcd().
 		ExposeLoopbackAuthorizer().
 		ExposeLoopbackMasterClientConfig().
@@ -54,6 +68,9 @@ func APICommand() *cobra.Command {
 	cmd.Use = "api"
 	cmd.Flags().StringSliceVar(&roles, "cluster-roles", []string{}, "Cluster Roles to bind when creating an organization")
 	cmd.Flags().StringVar(&usernamePrefix, "username-prefix", "", "Prefix prepended to username claims. Usually the same as \"--oidc-username-prefix\" of the Kubernetes API server")
+	orgRoles.addFlags(cmd)
+	invValidity.addFlags(cmd)
+	invHandoff.addFlags(cmd)
 	cmd.Flags().BoolVar(&allowEmptyBillingEntity, "allow-empty-billing-entity", true, "Allow empty billing entity references")
 	cmd.Flags().BoolVar(&skipBillingEntityValidation, "organization-skip-billing-entity-validation", false, "Skip validation of billing entity references")
 
@@ -77,6 +94,13 @@ func APICommand() *cobra.Command {
 	cmd.Flags().IntVar(&ob.odoo16PaymentTermID, "billing-entity-odoo16-payment-term-id", 2, "Payment term ID of the Odoo record")
 
 	cmd.Flags().StringVar(&ib.backingNS, "invitation-storage-backing-ns", "default", "Namespace to store invitation secrets in")
+	cmd.Flags().StringVar(&ib.encryptionKeysFile, "invitation-storage-encryption-keys-file", "", "Path to a file containing the keys to encrypt invitation secrets with, one <id>:<base64 encoded AES key> per line. The first key is used for encryption, all keys for decryption.")
+	cmd.Flags().StringVar(&ib.encryptionKeysSecret, "invitation-storage-encryption-keys-secret", "", "Secret containing the keys to encrypt invitation secrets with under the \"keys\" key, given as <namespace>/<name>. The namespace must not be the backing namespace. Same format as --invitation-storage-encryption-keys-file.")
+	cmd.Flags().IntVar(&ib.redeemMaxFailedAttempts, "invitation-redeem-max-failed-attempts", 5, "Number of failed redeem attempts after which a user is locked out of an invitation. Other users can still redeem the invitation. 0 disables locking.")
+	cmd.Flags().IntVar(&ib.redeemMaxFailedAttemptsPerInvitation, "invitation-redeem-max-failed-attempts-per-invitation", 100, "Number of failed redeem attempts of all users after which an invitation is locked until it is resent. 0 disables locking.")
+	cmd.Flags().BoolVar(&ib.redeemRestrictToEmail, "invitation-redeem-restrict-to-email", false, "Only allow redeeming invitations by users with the invited e-mail address or an allowed e-mail domain. Can be overridden per invitation.")
+
+	cmd.Flags().StringVar(&jb.backingNS, "join-request-storage-backing-ns", "default", "Namespace to store join request secrets in")
 
 	rf := cmd.Run
 	cmd.Run = func(cmd *cobra.Command, args []string) {
@@ -146,15 +170,64 @@ func (o *odooStorageBuilder) Build(s *runtime.Scheme, g genericregistry.RESTOpti
 type invitationStorageBuilder struct {
 	usernamePrefix *string
 
-	backingNS string
+	backingNS                                string
+	encryptionKeysFile, encryptionKeysSecret string
+
+	redeemMaxFailedAttempts, redeemMaxFailedAttemptsPerInvitation int
+	redeemRestrictToEmail                                         bool
+
+	validity *invitationValidityFlags
+	handoff  *invitationTokenHandoffFlags
+
+	redeem, preview restbuilder.ResourceHandlerProvider
 }
 
 func (i *invitationStorageBuilder) Build(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
-	return user.NewInvitationStorage(i.backingNS)(s, g)
+	return user.NewInvitationStorage(i.backingNS, i.encryptionKeysFile, i.encryptionKeysSecret, i.handoff.namespace, i.validity.validFor, i.validity.maxValidFor)(s, g)
 }
 
 func (i *invitationStorageBuilder) BuildRedeem(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
-	return user.NewInvitationRedeemStorage(*i.usernamePrefix)(s, g)
+	i.initRedeemStorages()
+	return i.redeem(s, g)
+}
+
+func (i *invitationStorageBuilder) BuildPreview(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	i.initRedeemStorages()
+	return i.preview(s, g)
+}
+
+// initRedeemStorages creates the redeem and preview storages sharing the failed attempt tracking.
+// The storages are created on first use, after the flags are parsed.
+func (i *invitationStorageBuilder) initRedeemStorages() {
+	if i.redeem == nil {
+		i.redeem, i.preview = user.NewInvitationRedeemStorages(*i.usernamePrefix, i.redeemMaxFailedAttempts, i.redeemMaxFailedAttemptsPerInvitation, i.redeemRestrictToEmail)
+	}
+}
+
+func (i *invitationStorageBuilder) BuildRevoke(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	return user.NewInvitationRevokeStorage()(s, g)
+}
+
+func (i *invitationStorageBuilder) BuildResend(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	return user.NewInvitationResendStorage(i.handoff.namespace, i.validity.validFor, i.validity.maxValidFor)(s, g)
+}
+
+type joinRequestStorageBuilder struct {
+	usernamePrefix *string
+
+	backingNS string
+}
+
+func (j *joinRequestStorageBuilder) Build(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	return user.NewJoinRequestStorage(j.backingNS, *j.usernamePrefix)(s, g)
+}
+
+func (j *joinRequestStorageBuilder) BuildApprove(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	return user.NewJoinRequestApproveStorage()(s, g)
+}
+
+func (j *joinRequestStorageBuilder) BuildReject(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+	return user.NewJoinRequestRejectStorage()(s, g)
 }
 
 type organizationStatusRegisterer struct {
@@ -166,3 +239,16 @@ func (o organizationStatusRegisterer) GetGroupVersionResource() schema.GroupVers
 	gvr.Resource = fmt.Sprintf("%s/status", gvr.Resource)
 	return gvr
 }
+
+// addSecretStorageFieldLabelConversionFuncs registers the field selectors supported by the secret storage for the resources stored in secrets.
+func addSecretStorageFieldLabelConversionFuncs(s *runtime.Scheme) error {
+	for kind, obj := range map[string]runtime.Object{
+		"Invitation":  &userv1.Invitation{},
+		"JoinRequest": &userv1.JoinRequest{},
+	} {
+		if err := s.AddFieldLabelConversionFunc(userv1.GroupVersion.WithKind(kind), secretstorage.FieldLabelConversionFunc(obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// OrganizationLeaveRequest is the body of requests to the `organizations/leave` subresource.
+// The requesting user is removed from the organization.
+type OrganizationLeaveRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OrganizationLeaveRequest{})
+}
@@ -0,0 +1,37 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// InvitationRevokeRequest is the body of requests to the `invitations/revoke` subresource.
+// A revoked invitation can no longer be redeemed.
+type InvitationRevokeRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Reason is an optional human readable reason for revoking the invitation
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// InvitationResendRequest is the body of requests to the `invitations/resend` subresource.
+// Resending an invitation issues a new token and sends the invitation email again.
+type InvitationResendRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// ValidFor extends the validity of the invitation to the given duration from now
+	ValidFor *metav1.Duration `json:"validFor,omitempty"`
+
+	// Token is the new token of the invitation.
+	// It is only set in the response and is never returned again.
+	Token string `json:"token,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&InvitationRevokeRequest{}, &InvitationResendRequest{})
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+var _ resource.Object = &InvitationPreview{}
+
+// +kubebuilder:object:root=true
+// InvitationPreview is a request to inspect an invitation before redeeming it.
+// The name is the name of the invitation, the request is authorized by the token of the invitation.
+// The preview is returned in the status, the invitation is not redeemed.
+type InvitationPreview struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Token is the token of the invitation
+	Token string `json:"token"`
+
+	// Status is the preview of the invitation, it is set by the server
+	Status InvitationPreviewStatus `json:"status,omitempty"`
+}
+
+// InvitationPreviewStatus is the part of an invitation the invitee is allowed to see before redeeming it
+type InvitationPreviewStatus struct {
+	// Inviter is the display name of the user who created the invitation, or the username if the user has no display name
+	Inviter string `json:"inviter,omitempty"`
+	// Note is the note of the invitation
+	Note string `json:"note,omitempty"`
+	// ValidUntil is the time until the invitation can be redeemed
+	ValidUntil metav1.Time `json:"validUntil,omitempty"`
+	// Targets are the organizations, teams, and billing entities the invitee is invited into
+	Targets []InvitationPreviewTarget `json:"targets,omitempty"`
+}
+
+// InvitationPreviewTarget is an organization, team, or billing entity the invitee is invited into
+type InvitationPreviewTarget struct {
+	// Kind is the kind of the target, one of `Organization`, `Team`, or `BillingEntity`
+	Kind string `json:"kind"`
+	// Name is the name of the target
+	Name string `json:"name"`
+	// Organization is the name of the organization of a team
+	Organization string `json:"organization,omitempty"`
+	// DisplayName is the human-readable name of the target
+	DisplayName string `json:"displayName,omitempty"`
+	// Role is the role granted to the invitee, if the invitation grants a role
+	Role string `json:"role,omitempty"`
+}
+
+// GetObjectMeta returns the objects meta reference.
+func (o *InvitationPreview) GetObjectMeta() *metav1.ObjectMeta {
+	return &o.ObjectMeta
+}
+
+// GetGroupVersionResource returns the GroupVersionResource for this resource.
+// The resource should be the all lowercase and pluralized kind
+func (o *InvitationPreview) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    GroupVersion.Group,
+		Version:  GroupVersion.Version,
+		Resource: "invitationpreviews",
+	}
+}
+
+// IsStorageVersion returns true if the object is also the internal version -- i.e. is the type defined for the API group or an alias to this object.
+// If false, the resource is expected to implement MultiVersionObject interface.
+func (o *InvitationPreview) IsStorageVersion() bool {
+	return true
+}
+
+// NamespaceScoped returns true if the object is namespaced
+func (o *InvitationPreview) NamespaceScoped() bool {
+	return false
+}
+
+// New returns a new instance of the resource
+func (o *InvitationPreview) New() runtime.Object {
+	return &InvitationPreview{}
+}
+
+// NewList return a new list instance of the resource
+func (o *InvitationPreview) NewList() runtime.Object {
+	return &InvitationPreviewList{}
+}
+
+var _ resource.ObjectList = &InvitationPreviewList{}
+
+// +kubebuilder:object:root=true
+// InvitationPreviewList contains a list of InvitationPreviews
+type InvitationPreviewList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []InvitationPreview `json:"items"`
+}
+
+// GetListMeta returns the list meta reference.
+func (in *InvitationPreviewList) GetListMeta() *metav1.ListMeta {
+	return &in.ListMeta
+}
+
+func init() {
+	SchemeBuilder.Register(&InvitationPreview{}, &InvitationPreviewList{})
+}
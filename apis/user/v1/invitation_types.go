@@ -18,6 +18,22 @@ const (
 	// ConditionEmailSent is set when the invitation email has been sent
 	ConditionEmailSent        = "EmailSent"
 	ConditionReasonSendFailed = "SendFailed"
+	// ConditionReasonTokenUnavailable is set if the token of the invitation is no longer available to send the invitation email.
+	// The invitation must be resent to issue a new token.
+	ConditionReasonTokenUnavailable = "TokenUnavailable"
+	// ConditionLocked is set when users have been locked out of the invitation after too many failed redeem attempts.
+	// With reason UsersLockedOut, other users can still redeem the invitation.
+	// With reason TooManyFailedAttempts, the invitation can't be redeemed anymore until it is resent.
+	ConditionLocked                      = "Locked"
+	ConditionReasonUsersLockedOut        = "UsersLockedOut"
+	ConditionReasonTooManyFailedAttempts = "TooManyFailedAttempts"
+	// ConditionRevoked is set when the invitation has been revoked and can no longer be redeemed
+	ConditionRevoked = "Revoked"
+	// ConditionReminderSent is set when the reminder email has been sent before the invitation expires
+	ConditionReminderSent = "ReminderSent"
+	// ConditionExpiryNotificationSent is set when the inviter has been notified that the invitation expired unredeemed
+	ConditionExpiryNotificationSent = "ExpiryNotificationSent"
+	ConditionReasonNoRecipient      = "NoRecipient"
 )
 
 // +kubebuilder:object:root=true
@@ -41,6 +57,27 @@ type InvitationSpec struct {
 	Email string `json:"email,omitempty"`
 	// TargetRefs is a list of references to the target resources
 	TargetRefs []TargetRef `json:"targetRefs,omitempty"`
+	// RestrictToEmail overrides the server default on whether the invitation can only be redeemed by a user with the invited e-mail address or an e-mail address in one of the AllowedEmailDomains.
+	// +optional
+	RestrictToEmail *bool `json:"restrictToEmail,omitempty"`
+	// AllowedEmailDomains is a list of e-mail domains whose users can redeem the invitation if it is restricted to the invited e-mail address.
+	// Multi-use invitations with allowed e-mail domains are restricted unless RestrictToEmail is explicitly set to false.
+	// +optional
+	AllowedEmailDomains []string `json:"allowedEmailDomains,omitempty"`
+	// MaxRedemptions is the number of users that can redeem the invitation.
+	// Invitations with more than one redemption can be shared as a link, every redeeming user is tracked in the redeemers of the status.
+	// Defaults to a single redemption.
+	// +optional
+	MaxRedemptions int `json:"maxRedemptions,omitempty"`
+	// ValidFor is the requested validity of the invitation, starting when its token is issued.
+	// Defaults to the validity configured on the server. Mutually exclusive with ValidUntil.
+	// Both are bounded by the maximum validity configured on the server.
+	// Both can't be changed once the token is issued, the `invitations/resend` subresource extends the validity instead.
+	// +optional
+	ValidFor *metav1.Duration `json:"validFor,omitempty"`
+	// ValidUntil is the requested expiry of the invitation. Mutually exclusive with ValidFor.
+	// +optional
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
 }
 
 // TargetRef is a reference to a target resource
@@ -53,12 +90,24 @@ type TargetRef struct {
 	Name string `json:"name,omitempty"`
 	// Namespace is the namespace of the target resource
 	Namespace string `json:"namespace,omitempty"`
+	// Role is the role granted to the invited user, e.g. `admin` or `viewer`.
+	// Only supported for OrganizationMembers and BillingEntity targets.
+	// For OrganizationMembers targets the user is added to the organization members with the ClusterRole `<organization-role-prefix><role>`.
+	// The ClusterRole must be allowed to be assigned to organization members. Users added with a role are not bound to the default member roles.
+	// BillingEntity targets require either the `admin` or the `viewer` role, the user is added to the ClusterRoleBinding granting the role.
+	Role string `json:"role,omitempty"`
 }
 
 // InvitationStatus defines the observed state of the Invitation
 type InvitationStatus struct {
-	// Token is the invitation token
-	Token string `json:"token"`
+	// Token is the plaintext invitation token.
+	// It is never stored. It is only set in the response to the creation of the invitation and in the invitation email.
+	// Invitations created before tokens were hashed still store their token and have no TokenHash.
+	Token string `json:"token,omitempty"`
+	// TokenHash is the salted hash of the invitation token.
+	// Redeem requests are verified against the hash.
+	// It is set when the invitation is created and can only be changed by resending the invitation, which issues a new token.
+	TokenHash string `json:"tokenHash,omitempty"`
 	// ValidUntil is the time when the invitation expires
 	ValidUntil metav1.Time `json:"validUntil"`
 	// Conditions is a list of conditions for the invitation
@@ -67,6 +116,37 @@ type InvitationStatus struct {
 	TargetStatuses []TargetStatus `json:"targetStatuses,omitempty"`
 	// RedeemedBy is the user who redeemed the invitation
 	RedeemedBy string `json:"redeemedBy,omitempty"`
+	// FailedRedeemAttempts counts the redeem and preview attempts with an invalid token per user.
+	// Users reaching the maximum number of failed attempts are locked out of the invitation, other users can still redeem it.
+	// Only a limited number of users is tracked, the users with the fewest failed attempts are dropped first.
+	FailedRedeemAttempts []FailedRedeemAttempts `json:"failedRedeemAttempts,omitempty"`
+	// FailedRedeemAttemptsTotal is the number of redeem and preview attempts with an invalid token of all users.
+	// The invitation is locked once the maximum number of failed attempts per invitation is reached.
+	FailedRedeemAttemptsTotal int `json:"failedRedeemAttemptsTotal,omitempty"`
+	// Redeemers is the list of users who redeemed a multi-use invitation.
+	// Single-use invitations track the redeeming user in RedeemedBy and TargetStatuses.
+	Redeemers []Redeemer `json:"redeemers,omitempty"`
+	// ExpiryNotificationRecipients are the e-mail addresses the notification about the expired invitation was sent to.
+	// Failed notifications are only retried for the other inviters.
+	ExpiryNotificationRecipients []string `json:"expiryNotificationRecipients,omitempty"`
+}
+
+// Redeemer is a user who redeemed a multi-use invitation
+type Redeemer struct {
+	// Username is the user who redeemed the invitation
+	Username string `json:"username"`
+	// RedeemedAt is the time the user redeemed the invitation
+	RedeemedAt metav1.Time `json:"redeemedAt"`
+	// TargetStatuses is a list of statuses for the target resources of this user
+	TargetStatuses []TargetStatus `json:"targetStatuses,omitempty"`
+}
+
+// FailedRedeemAttempts are the failed redeem attempts of a user
+type FailedRedeemAttempts struct {
+	// Username is the user who tried to redeem the invitation
+	Username string `json:"username"`
+	// Count is the number of attempts with an invalid token
+	Count int `json:"count"`
 }
 
 // TargetStatus is the status of a target resource
@@ -78,6 +158,9 @@ type TargetStatus struct {
 // Invitation needs to implement the builder resource interface
 var _ status.ObjectWithStatusSubResource = &Invitation{}
 
+// InvitationStatus declares fields usable in field selectors
+var _ status.StatusWithSelectableFields = &InvitationStatus{}
+
 // GetObjectMeta returns the objects meta reference.
 func (o *Invitation) GetObjectMeta() *metav1.ObjectMeta {
 	return &o.ObjectMeta
@@ -114,11 +197,57 @@ func (o *Invitation) NewList() runtime.Object {
 	return &InvitationList{}
 }
 
-// IsRedeemed returns true if the invitation has been redeemed
+// IsRedeemed returns true if the invitation has been redeemed.
+// Multi-use invitations are redeemed once the maximum number of redemptions is reached.
 func (o *Invitation) IsRedeemed() bool {
 	return apimeta.IsStatusConditionTrue(o.Status.Conditions, ConditionRedeemed)
 }
 
+// IsMultiUse returns true if the invitation can be redeemed by more than one user
+func (o *Invitation) IsMultiUse() bool {
+	return o.Spec.MaxRedemptions > 1
+}
+
+// RedeemedByUser returns true if the given user already redeemed the invitation
+func (o *Invitation) RedeemedByUser(username string) bool {
+	if username != "" && o.Status.RedeemedBy == username {
+		return true
+	}
+	for _, r := range o.Status.Redeemers {
+		if r.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// FailedRedeemAttemptsOf returns the number of failed redeem attempts of the given user
+func (o *Invitation) FailedRedeemAttemptsOf(username string) int {
+	for _, a := range o.Status.FailedRedeemAttempts {
+		if a.Username == username {
+			return a.Count
+		}
+	}
+	return 0
+}
+
+// IsLocked returns true if the invitation has been locked after too many failed redeem attempts of all users.
+// Users locked out of the invitation don't lock the invitation.
+func (o *Invitation) IsLocked() bool {
+	c := apimeta.FindStatusCondition(o.Status.Conditions, ConditionLocked)
+	return c != nil && c.Status == metav1.ConditionTrue && c.Reason == ConditionReasonTooManyFailedAttempts
+}
+
+// IsRevoked returns true if the invitation has been revoked
+func (o *Invitation) IsRevoked() bool {
+	return apimeta.IsStatusConditionTrue(o.Status.Conditions, ConditionRevoked)
+}
+
+// HasToken returns true if a token has been issued for the invitation
+func (o *Invitation) HasToken() bool {
+	return o.Status.TokenHash != "" || o.Status.Token != ""
+}
+
 // SecretStorageGetStatus returns the status of the resource
 func (o *Invitation) SecretStorageGetStatus() status.StatusSubResource {
 	return &o.Status
@@ -133,6 +262,21 @@ func (s InvitationStatus) SubResourceName() string {
 	return "status"
 }
 
+// SecretStorageSelectableFields returns the status fields usable in field selectors.
+// status.redeemedBy contains the user who redeemed a single-use invitation and all users who redeemed a multi-use invitation.
+func (s *InvitationStatus) SecretStorageSelectableFields() status.SelectableFields {
+	redeemedBy := make([]string, 0, len(s.Redeemers)+1)
+	if s.RedeemedBy != "" {
+		redeemedBy = append(redeemedBy, s.RedeemedBy)
+	}
+	for _, r := range s.Redeemers {
+		redeemedBy = append(redeemedBy, r.Username)
+	}
+	return status.SelectableFields{
+		"status.redeemedBy": redeemedBy,
+	}
+}
+
 // +kubebuilder:object:root=true
 
 // InvitationList contains a list of Invitations
@@ -0,0 +1,177 @@
+package v1
+
+import (
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+
+	"github.com/appuio/control-api/apiserver/secretstorage/status"
+)
+
+const (
+	// ConditionApproved is set when an organization admin decided on the join request.
+	// It is true if the request was approved and false if it was rejected.
+	ConditionApproved       = "Approved"
+	ConditionReasonRejected = "Rejected"
+	// ConditionJoined is set when the requesting user has been added to the organization
+	ConditionJoined = "Joined"
+)
+
+// +kubebuilder:object:root=true
+
+// JoinRequest is a request of a user to join an organization.
+// It is created in the namespace of the organization and approved or rejected by the organization admins.
+type JoinRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec holds the join request
+	Spec JoinRequestSpec `json:"spec,omitempty"`
+	// Status holds the state of the join request
+	Status JoinRequestStatus `json:"status,omitempty"`
+}
+
+// JoinRequestSpec defines the desired state of the JoinRequest
+type JoinRequestSpec struct {
+	// Message is a free-form message to the organization admins
+	Message string `json:"message,omitempty"`
+}
+
+// JoinRequestStatus defines the observed state of the JoinRequest
+type JoinRequestStatus struct {
+	// Requester is the user who requested to join the organization.
+	// It is set by the API server when the join request is created.
+	Requester string `json:"requester,omitempty"`
+	// Conditions is a list of conditions for the join request
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// NotificationRecipients are the e-mail addresses of the organization admins notified about the join request.
+	// Failed notifications are only retried for the other admins.
+	NotificationRecipients []string `json:"notificationRecipients,omitempty"`
+}
+
+// JoinRequest needs to implement the builder resource interface
+var _ status.ObjectWithStatusSubResource = &JoinRequest{}
+
+// JoinRequestStatus declares fields usable in field selectors
+var _ status.StatusWithSelectableFields = &JoinRequestStatus{}
+
+// GetObjectMeta returns the objects meta reference.
+func (o *JoinRequest) GetObjectMeta() *metav1.ObjectMeta {
+	return &o.ObjectMeta
+}
+
+// GetGroupVersionResource returns the GroupVersionResource for this resource.
+// The resource should be the all lowercase and pluralized kind
+func (o *JoinRequest) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    GroupVersion.Group,
+		Version:  GroupVersion.Version,
+		Resource: "joinrequests",
+	}
+}
+
+// IsStorageVersion returns true if the object is also the internal version -- i.e. is the type defined for the API group or an alias to this object.
+// If false, the resource is expected to implement MultiVersionObject interface.
+func (o *JoinRequest) IsStorageVersion() bool {
+	return true
+}
+
+// NamespaceScoped returns true if the object is namespaced
+func (o *JoinRequest) NamespaceScoped() bool {
+	return true
+}
+
+// New returns a new instance of the resource
+func (o *JoinRequest) New() runtime.Object {
+	return &JoinRequest{}
+}
+
+// NewList return a new list instance of the resource
+func (o *JoinRequest) NewList() runtime.Object {
+	return &JoinRequestList{}
+}
+
+// IsDecided returns true if the join request has been approved or rejected
+func (o *JoinRequest) IsDecided() bool {
+	return apimeta.FindStatusCondition(o.Status.Conditions, ConditionApproved) != nil
+}
+
+// IsApproved returns true if the join request has been approved
+func (o *JoinRequest) IsApproved() bool {
+	return apimeta.IsStatusConditionTrue(o.Status.Conditions, ConditionApproved)
+}
+
+// IsRejected returns true if the join request has been rejected
+func (o *JoinRequest) IsRejected() bool {
+	return apimeta.IsStatusConditionFalse(o.Status.Conditions, ConditionApproved)
+}
+
+// HasJoined returns true if the requesting user has been added to the organization
+func (o *JoinRequest) HasJoined() bool {
+	return apimeta.IsStatusConditionTrue(o.Status.Conditions, ConditionJoined)
+}
+
+// SecretStorageGetStatus returns the status of the resource
+func (o *JoinRequest) SecretStorageGetStatus() status.StatusSubResource {
+	return &o.Status
+}
+
+// CopyTo copies the status to the given parent resource
+func (s *JoinRequestStatus) SecretStorageCopyTo(parent status.ObjectWithStatusSubResource) {
+	parent.(*JoinRequest).Status = *s.DeepCopy()
+}
+
+func (s JoinRequestStatus) SubResourceName() string {
+	return "status"
+}
+
+// SecretStorageSelectableFields returns the status fields usable in field selectors
+func (s *JoinRequestStatus) SecretStorageSelectableFields() status.SelectableFields {
+	return status.SelectableFields{
+		"status.requester": {s.Requester},
+	}
+}
+
+// +kubebuilder:object:root=true
+
+// JoinRequestList contains a list of JoinRequests
+type JoinRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []JoinRequest `json:"items"`
+}
+
+// JoinRequestList needs to implement the builder resource interface
+var _ resource.ObjectList = &JoinRequestList{}
+
+// GetListMeta returns the list meta reference.
+func (in *JoinRequestList) GetListMeta() *metav1.ListMeta {
+	return &in.ListMeta
+}
+
+// +kubebuilder:object:root=true
+
+// JoinRequestApproveRequest is the body of requests to the `joinrequests/approve` subresource.
+// The requesting user is added to the organization once the join request is approved.
+type JoinRequestApproveRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// JoinRequestRejectRequest is the body of requests to the `joinrequests/reject` subresource.
+type JoinRequestRejectRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Reason is an optional human readable reason for rejecting the join request
+	Reason string `json:"reason,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JoinRequest{}, &JoinRequestList{}, &JoinRequestApproveRequest{}, &JoinRequestRejectRequest{})
+}
@@ -10,6 +10,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedRedeemAttempts) DeepCopyInto(out *FailedRedeemAttempts) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedRedeemAttempts.
+func (in *FailedRedeemAttempts) DeepCopy() *FailedRedeemAttempts {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedRedeemAttempts)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Invitation) DeepCopyInto(out *Invitation) {
 	*out = *in
@@ -69,6 +84,100 @@ func (in *InvitationList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationPreview) DeepCopyInto(out *InvitationPreview) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationPreview.
+func (in *InvitationPreview) DeepCopy() *InvitationPreview {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationPreview)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InvitationPreview) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationPreviewList) DeepCopyInto(out *InvitationPreviewList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]InvitationPreview, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationPreviewList.
+func (in *InvitationPreviewList) DeepCopy() *InvitationPreviewList {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationPreviewList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InvitationPreviewList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationPreviewStatus) DeepCopyInto(out *InvitationPreviewStatus) {
+	*out = *in
+	in.ValidUntil.DeepCopyInto(&out.ValidUntil)
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]InvitationPreviewTarget, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationPreviewStatus.
+func (in *InvitationPreviewStatus) DeepCopy() *InvitationPreviewStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationPreviewStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationPreviewTarget) DeepCopyInto(out *InvitationPreviewTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationPreviewTarget.
+func (in *InvitationPreviewTarget) DeepCopy() *InvitationPreviewTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationPreviewTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InvitationRedeemRequest) DeepCopyInto(out *InvitationRedeemRequest) {
 	*out = *in
@@ -126,6 +235,61 @@ func (in *InvitationRedeemRequestList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationResendRequest) DeepCopyInto(out *InvitationResendRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.ValidFor != nil {
+		in, out := &in.ValidFor, &out.ValidFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationResendRequest.
+func (in *InvitationResendRequest) DeepCopy() *InvitationResendRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationResendRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InvitationResendRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvitationRevokeRequest) DeepCopyInto(out *InvitationRevokeRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationRevokeRequest.
+func (in *InvitationRevokeRequest) DeepCopy() *InvitationRevokeRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(InvitationRevokeRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *InvitationRevokeRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InvitationSpec) DeepCopyInto(out *InvitationSpec) {
 	*out = *in
@@ -134,6 +298,25 @@ func (in *InvitationSpec) DeepCopyInto(out *InvitationSpec) {
 		*out = make([]TargetRef, len(*in))
 		copy(*out, *in)
 	}
+	if in.RestrictToEmail != nil {
+		in, out := &in.RestrictToEmail, &out.RestrictToEmail
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowedEmailDomains != nil {
+		in, out := &in.AllowedEmailDomains, &out.AllowedEmailDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValidFor != nil {
+		in, out := &in.ValidFor, &out.ValidFor
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ValidUntil != nil {
+		in, out := &in.ValidUntil, &out.ValidUntil
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationSpec.
@@ -164,6 +347,23 @@ func (in *InvitationStatus) DeepCopyInto(out *InvitationStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.FailedRedeemAttempts != nil {
+		in, out := &in.FailedRedeemAttempts, &out.FailedRedeemAttempts
+		*out = make([]FailedRedeemAttempts, len(*in))
+		copy(*out, *in)
+	}
+	if in.Redeemers != nil {
+		in, out := &in.Redeemers, &out.Redeemers
+		*out = make([]Redeemer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExpiryNotificationRecipients != nil {
+		in, out := &in.ExpiryNotificationRecipients, &out.ExpiryNotificationRecipients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvitationStatus.
@@ -176,6 +376,157 @@ func (in *InvitationStatus) DeepCopy() *InvitationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequest) DeepCopyInto(out *JoinRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequest.
+func (in *JoinRequest) DeepCopy() *JoinRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JoinRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequestApproveRequest) DeepCopyInto(out *JoinRequestApproveRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequestApproveRequest.
+func (in *JoinRequestApproveRequest) DeepCopy() *JoinRequestApproveRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequestApproveRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JoinRequestApproveRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequestList) DeepCopyInto(out *JoinRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]JoinRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequestList.
+func (in *JoinRequestList) DeepCopy() *JoinRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JoinRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequestRejectRequest) DeepCopyInto(out *JoinRequestRejectRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequestRejectRequest.
+func (in *JoinRequestRejectRequest) DeepCopy() *JoinRequestRejectRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequestRejectRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *JoinRequestRejectRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequestSpec) DeepCopyInto(out *JoinRequestSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequestSpec.
+func (in *JoinRequestSpec) DeepCopy() *JoinRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JoinRequestStatus) DeepCopyInto(out *JoinRequestStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotificationRecipients != nil {
+		in, out := &in.NotificationRecipients, &out.NotificationRecipients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JoinRequestStatus.
+func (in *JoinRequestStatus) DeepCopy() *JoinRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(JoinRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RedeemOptions) DeepCopyInto(out *RedeemOptions) {
 	*out = *in
@@ -200,6 +551,29 @@ func (in *RedeemOptions) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Redeemer) DeepCopyInto(out *Redeemer) {
+	*out = *in
+	in.RedeemedAt.DeepCopyInto(&out.RedeemedAt)
+	if in.TargetStatuses != nil {
+		in, out := &in.TargetStatuses, &out.TargetStatuses
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Redeemer.
+func (in *Redeemer) DeepCopy() *Redeemer {
+	if in == nil {
+		return nil
+	}
+	out := new(Redeemer)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TargetRef) DeepCopyInto(out *TargetRef) {
 	*out = *in
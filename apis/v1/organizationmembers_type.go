@@ -4,6 +4,17 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ConditionMembersResolved is set when all referenced users could be resolved
+	ConditionMembersResolved = "MembersResolved"
+	// ConditionReasonUserNotFound is used if at least one referenced user does not exist
+	ConditionReasonUserNotFound = "UserNotFound"
+	// ConditionRolesAllowed is set when all roles of the referenced users are allowed to be assigned to organization members
+	ConditionRolesAllowed = "RolesAllowed"
+	// ConditionReasonRoleNotAllowed is used if at least one referenced user has a role which can't be assigned to organization members
+	ConditionReasonRoleNotAllowed = "RoleNotAllowed"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -18,12 +29,15 @@ type OrganizationMembers struct {
 
 // OrganizationMembersSpec contains the desired members of the organization
 type OrganizationMembersSpec struct {
-	UserRefs []UserRef `json:"userRefs,omitempty"`
+	UserRefs []OrganizationMemberRef `json:"userRefs,omitempty"`
 }
 
 // OrganizationMembersStatus contains the actual members of the organization
 type OrganizationMembersStatus struct {
 	ResolvedUserRefs []UserRef `json:"resolvedUserRefs,omitempty"`
+
+	// Conditions is a list of conditions for the organization members
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // UserRef points to a user
@@ -31,6 +45,29 @@ type UserRef struct {
 	Name string `json:"name,omitempty"`
 }
 
+// OrganizationMemberRef points to a user and its roles in the organization
+type OrganizationMemberRef struct {
+	Name string `json:"name,omitempty"`
+	// Roles are the ClusterRoles bound to the user for the organization namespace.
+	// Users without roles are bound to the default member roles.
+	// The roles must be allowed to be assigned to organization members, the admission webhook rejects other roles.
+	// Roles which are no longer allowed are ignored.
+	Roles []string `json:"roles,omitempty"`
+}
+
+// RolesOrDefault returns the roles of the member, or the given default roles if the member has no roles.
+func (r OrganizationMemberRef) RolesOrDefault(defaultRoles []string) []string {
+	if len(r.Roles) == 0 {
+		return defaultRoles
+	}
+	return r.Roles
+}
+
+// UserRef returns the reference to the user of the member.
+func (r OrganizationMemberRef) UserRef() UserRef {
+	return UserRef{Name: r.Name}
+}
+
 // +kubebuilder:object:root=true
 
 // OrganizationMembersList contains a list of OrganizationMembers resources
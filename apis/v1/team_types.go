@@ -4,6 +4,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// ConditionReasonNotOrganizationMember is used if at least one referenced user is not a member of the organization
+	ConditionReasonNotOrganizationMember = "NotOrganizationMember"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -32,6 +37,9 @@ type TeamStatus struct {
 	GroupRef GroupRef `json:"groupRef,omitempty"`
 
 	ResolvedUserRefs []UserRef `json:"resolvedUserRefs,omitempty"`
+
+	// Conditions is a list of conditions for the team
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -5,6 +5,21 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+const (
+	// UsageProfileAnnotation is the annotation on an organization selecting the usage profile applied to the organization's namespaces.
+	UsageProfileAnnotation = "organization.appuio.io/usage-profile"
+	// UsageProfileLabel is the label set on resources created from a usage profile.
+	// The value is the name of the usage profile.
+	UsageProfileLabel = "appuio.io/usage-profile"
+	// OrganizationLabel is the label identifying the organization owning a namespace.
+	OrganizationLabel = "appuio.io/organization"
+
+	// ConditionResourcesApplied is set when all resources of the usage profile have been applied
+	ConditionResourcesApplied = "ResourcesApplied"
+	// ConditionReasonApplyFailed is used if at least one resource could not be applied
+	ConditionReasonApplyFailed = "ApplyFailed"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:subresource:status
@@ -24,11 +39,35 @@ type UsageProfileSpec struct {
 	NamespaceCount int `json:"namespaceCount,omitempty"`
 	// Resources is the set of resources which are created in each namespace for which the usage profile is applied.
 	// The key is used as the name of the resource and the value is the resource definition.
+	// Supported kinds are ConfigMap, LimitRange, ResourceQuota and NetworkPolicy.
 	Resources map[string]runtime.RawExtension `json:"resources,omitempty"`
 }
 
 // UsageProfileStatus contains the actual state of the usage profile
 type UsageProfileStatus struct {
+	// Organizations is the number of organizations using this usage profile.
+	Organizations int `json:"organizations,omitempty"`
+	// Namespaces is the number of namespaces the usage profile is applied to.
+	Namespaces int `json:"namespaces,omitempty"`
+	// AppliedResources is the number of resources successfully applied across all namespaces.
+	AppliedResources int `json:"appliedResources,omitempty"`
+	// FailedResources is the number of resources which could not be applied.
+	FailedResources int `json:"failedResources,omitempty"`
+	// Resources is the list of resources last applied by the usage profile.
+	// It is used to find the kinds of the resources to prune.
+	Resources []UsageProfileResourceRef `json:"resources,omitempty"`
+	// Conditions is a list of conditions for the usage profile
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// UsageProfileResourceRef references a resource created from a usage profile
+type UsageProfileResourceRef struct {
+	// APIVersion is the API version of the resource
+	APIVersion string `json:"apiVersion"`
+	// Kind is the kind of the resource
+	Kind string `json:"kind"`
+	// Name is the name of the resource
+	Name string `json:"name"`
 }
 
 // +kubebuilder:object:root=true
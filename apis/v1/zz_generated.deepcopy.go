@@ -6,6 +6,7 @@
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -65,6 +66,26 @@ func (in *GroupRef) DeepCopy() *GroupRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrganizationMemberRef) DeepCopyInto(out *OrganizationMemberRef) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrganizationMemberRef.
+func (in *OrganizationMemberRef) DeepCopy() *OrganizationMemberRef {
+	if in == nil {
+		return nil
+	}
+	out := new(OrganizationMemberRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OrganizationMembers) DeepCopyInto(out *OrganizationMembers) {
 	*out = *in
@@ -129,8 +150,10 @@ func (in *OrganizationMembersSpec) DeepCopyInto(out *OrganizationMembersSpec) {
 	*out = *in
 	if in.UserRefs != nil {
 		in, out := &in.UserRefs, &out.UserRefs
-		*out = make([]UserRef, len(*in))
-		copy(*out, *in)
+		*out = make([]OrganizationMemberRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -152,6 +175,13 @@ func (in *OrganizationMembersStatus) DeepCopyInto(out *OrganizationMembersStatus
 		*out = make([]UserRef, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrganizationMembersStatus.
@@ -252,6 +282,13 @@ func (in *TeamStatus) DeepCopyInto(out *TeamStatus) {
 		*out = make([]UserRef, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TeamStatus.
@@ -291,7 +328,7 @@ func (in *UsageProfile) DeepCopyInto(out *UsageProfile) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageProfile.
@@ -344,6 +381,21 @@ func (in *UsageProfileList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UsageProfileResourceRef) DeepCopyInto(out *UsageProfileResourceRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageProfileResourceRef.
+func (in *UsageProfileResourceRef) DeepCopy() *UsageProfileResourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(UsageProfileResourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UsageProfileSpec) DeepCopyInto(out *UsageProfileSpec) {
 	*out = *in
@@ -369,6 +421,18 @@ func (in *UsageProfileSpec) DeepCopy() *UsageProfileSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UsageProfileStatus) DeepCopyInto(out *UsageProfileStatus) {
 	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]UsageProfileResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UsageProfileStatus.
@@ -18,6 +18,8 @@ import (
 type Authorizer struct {
 	Authorizer authorizer.Authorizer
 	rbacID     metav1.GroupVersionResource
+	// namespaced is true if the authorized resource is namespace-scoped
+	namespaced bool
 }
 
 func NewAuthorizer(rbacID metav1.GroupVersionResource, authorizer authorizer.Authorizer) Authorizer {
@@ -27,17 +29,31 @@ func NewAuthorizer(rbacID metav1.GroupVersionResource, authorizer authorizer.Aut
 	}
 }
 
+// NewNamespacedAuthorizer returns an Authorizer for namespace-scoped resources.
+// Requests are authorized in the namespace of the request or the object.
+func NewNamespacedAuthorizer(rbacID metav1.GroupVersionResource, authorizer authorizer.Authorizer) Authorizer {
+	return Authorizer{
+		rbacID:     rbacID,
+		Authorizer: authorizer,
+		namespaced: true,
+	}
+}
+
 // Authorizer makes an authorization decision based on the Attributes.
 // It returns nil when an action is authorized, otherwise it returns an error.
 func (a Authorizer) Authorize(ctx context.Context, attr authorizer.Attributes) error {
 	if attr.GetResource() != a.rbacID.Resource {
 		return fmt.Errorf("unkown resource %q", attr.GetResource())
 	}
+	namespace := attr.GetName() // We handle cluster wide resources
+	if a.namespaced {
+		namespace = attr.GetNamespace()
+	}
 	decision, reason, err := a.Authorizer.Authorize(ctx, authorizer.AttributesRecord{
 		User:            attr.GetUser(),
 		Verb:            attr.GetVerb(),
 		Name:            attr.GetName(),
-		Namespace:       attr.GetName(), // We handle cluster wide resources
+		Namespace:       namespace,
 		APIGroup:        a.rbacID.Group,
 		APIVersion:      a.rbacID.Version,
 		Resource:        attr.GetResource(),
@@ -70,6 +86,16 @@ func (a Authorizer) AuthorizeContext(ctx context.Context) error {
 // AuthorizerVerb makes an authorization decision based on the Attributes present in the given Context, but overriding the verb and object name to the provided values
 // It returns nil when the context contains Attributes and the action is authorized, otherwise it returns an error.
 func (a Authorizer) AuthorizeVerb(ctx context.Context, verb string, name string) error {
+	attr, err := filters.GetAuthorizerAttributes(ctx)
+	if err != nil {
+		return err
+	}
+	return a.AuthorizeVerbInNamespace(ctx, verb, attr.GetNamespace(), name)
+}
+
+// AuthorizeVerbInNamespace makes an authorization decision based on the Attributes present in the given Context, but overriding the verb, namespace and object name to the provided values
+// It returns nil when the context contains Attributes and the action is authorized, otherwise it returns an error.
+func (a Authorizer) AuthorizeVerbInNamespace(ctx context.Context, verb string, namespace string, name string) error {
 	attr, err := filters.GetAuthorizerAttributes(ctx)
 	if err != nil {
 		return err
@@ -78,7 +104,7 @@ func (a Authorizer) AuthorizeVerb(ctx context.Context, verb string, name string)
 		User:       attr.GetUser(),
 		Verb:       verb,
 		Name:       name,
-		Namespace:  attr.GetNamespace(),
+		Namespace:  namespace,
 		APIGroup:   attr.GetAPIGroup(),
 		APIVersion: attr.GetAPIVersion(),
 		Resource:   attr.GetResource(),
@@ -91,3 +117,9 @@ func (a Authorizer) AuthorizeVerb(ctx context.Context, verb string, name string)
 func (a Authorizer) AuthorizeGet(ctx context.Context, name string) error {
 	return a.AuthorizeVerb(ctx, "get", name)
 }
+
+// AuthorizeGetInNamespace makes an authorization decision based on the Attributes present in the given Context, but overriding the verb to `get` and the namespace and object name to the provided values
+// It returns nil when the context contains Attributes and the action is authorized, otherwise it returns an error.
+func (a Authorizer) AuthorizeGetInNamespace(ctx context.Context, namespace string, name string) error {
+	return a.AuthorizeVerbInNamespace(ctx, "get", namespace, name)
+}
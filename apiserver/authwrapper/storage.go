@@ -2,7 +2,6 @@ package authwrapper
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -14,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
 )
 
@@ -69,14 +69,15 @@ func (s *authorizedStorageWithLister) ConvertToTable(ctx context.Context, obj ru
 // It allows filtering list and watch results based on the user's RBAC permissions.
 // If the storage implements rest.StandardStorage, the returned storage will implement rest.StandardStorage.
 // If the storage implements rest.Storage, the returned storage will implement rest.Storage.
-// Only cluster-scoped resources currently are supported. Panics if the storage is namespace-scoped.
+// Namespace-scoped resources are authorized in the namespace of the request or, for list and watch results, in the namespace of the object.
 func NewAuthorizedStorage(storage StorageScoper, rbacID metav1.GroupVersionResource, auth authorizer.Authorizer) (Storage, error) {
+	a := NewAuthorizer(rbacID, auth)
 	if storage.NamespaceScoped() {
-		return nil, errors.New("namespace-scoped resources are not supported")
+		a = NewNamespacedAuthorizer(rbacID, auth)
 	}
 	s := &authorizedStorage{
 		storage:    storage,
-		authorizer: NewAuthorizer(rbacID, auth),
+		authorizer: a,
 	}
 	if _, ok := storage.(rest.Lister); ok {
 		return &authorizedStorageWithLister{s}, nil
@@ -100,9 +101,8 @@ func (s *authorizedStorage) Storage() rest.Storage {
 }
 
 // NamespaceScoped implements rest.Scoper
-// Only cluster-scoped resources are supported.
 func (s *authorizedStorage) NamespaceScoped() bool {
-	return false
+	return s.authorizer.namespaced
 }
 
 func (s *authorizedStorage) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
@@ -131,9 +131,67 @@ func (s *authorizedStorage) Delete(ctx context.Context, name string, deleteValid
 	return nil, false, newMethodNotSupported("delete")
 }
 
+// DeleteCollection lists the objects through the wrapped storage and deletes the objects the user is allowed to delete.
+// It returns the list of deleted objects.
 func (s *authorizedStorage) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
-	// TODO(swi): Implement DeleteCollection
-	return nil, newMethodNotSupported("deletecollection")
+	err := s.authorizer.AuthorizeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lister, canList := s.storage.(rest.Lister)
+	deleter, canDelete := s.storage.(rest.GracefulDeleter)
+	if !canList || !canDelete {
+		return nil, newMethodNotSupported("deletecollection")
+	}
+
+	obj, err := lister.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := apimeta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	ac := apimeta.NewAccessor()
+	deleted := make([]runtime.Object, 0, len(l))
+	for _, itm := range l {
+		name, err := ac.Name(itm)
+		if err != nil {
+			return nil, err
+		}
+		namespace, err := ac.Namespace(itm)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.authorizer.AuthorizeVerbInNamespace(ctx, "delete", namespace, name); err != nil {
+			continue
+		}
+
+		dctx := ctx
+		if namespace != "" {
+			dctx = request.WithNamespace(ctx, namespace)
+		}
+		d, _, err := deleter.Delete(dctx, name, deleteValidation, options)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if d != nil {
+			deleted = append(deleted, d)
+		}
+	}
+
+	dl := lister.NewList()
+	if err := apimeta.SetList(dl, deleted); err != nil {
+		return nil, err
+	}
+	return dl, nil
 }
 
 func (s *authorizedStorage) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
@@ -173,8 +231,12 @@ func (s *authorizedStorageWithLister) List(ctx context.Context, options *metaint
 		if err != nil {
 			return nil, err
 		}
+		namespace, err := ac.Namespace(itm)
+		if err != nil {
+			return nil, err
+		}
 
-		if err := s.authorizer.AuthorizeGet(ctx, name); err != nil {
+		if err := s.authorizer.AuthorizeGetInNamespace(ctx, namespace, name); err != nil {
 			continue
 		}
 
@@ -213,7 +275,11 @@ func (s *authorizedStorage) Watch(ctx context.Context, options *metainternalvers
 		if err != nil {
 			return in, false
 		}
-		if err := s.authorizer.AuthorizeGet(ctx, name); err != nil {
+		namespace, err := ac.Namespace(in.Object)
+		if err != nil {
+			return in, false
+		}
+		if err := s.authorizer.AuthorizeGetInNamespace(ctx, namespace, name); err != nil {
 			return in, false
 		}
 
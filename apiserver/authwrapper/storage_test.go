@@ -155,11 +155,50 @@ func TestDelete(t *testing.T) {
 }
 
 func TestDeleteCollection(t *testing.T) {
-	ctrl, _, _, subject := setupStandardStorage(t)
+	ctrl, store, mauth, subject := setupStandardStorage(t)
 	defer ctrl.Finish()
 
+	t.Run("delete allowed", func(t *testing.T) {
+		gomock.InOrder(
+			allowAuthResponse(mauth),
+			denyAuthResponse(mauth),
+			allowAuthResponse(mauth),
+		)
+		store.EXPECT().
+			NewList().
+			Return((&testresource.TestResource{}).NewList()).
+			Times(1)
+		store.EXPECT().
+			List(gomock.Any(), gomock.Any()).
+			Return((&testresource.TestResourceList{
+				Items: []testresource.TestResource{
+					{ObjectMeta: metav1.ObjectMeta{Name: "tr1"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "tr2"}},
+				},
+			}), nil).
+			Times(1)
+		store.EXPECT().
+			Delete(gomock.Any(), "tr2", gomock.Any(), gomock.Any()).
+			Return(&testresource.TestResource{ObjectMeta: metav1.ObjectMeta{Name: "tr2"}}, true, nil).
+			Times(1)
+		deleted, err := subject.DeleteCollection(ctxWithInfo("deletecollection", ""), nil, &metav1.DeleteOptions{}, nil)
+		require.NoError(t, err)
+		require.Len(t, deleted.(*testresource.TestResourceList).Items, 1)
+		assert.Equal(t, "tr2", deleted.(*testresource.TestResourceList).Items[0].Name)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		denyAuthResponse(mauth)
+		_, err := subject.DeleteCollection(ctxWithInfo("deletecollection", ""), nil, &metav1.DeleteOptions{}, nil)
+		assert.ErrorContains(t, err, "forbidden")
+	})
+
 	t.Run("not implemented", func(t *testing.T) {
-		_, err := subject.DeleteCollection(ctxWithInfo("delete", ""), nil, nil, nil)
+		allowAuthResponse(mauth)
+
+		basicStore := mock.NewMockStorage(ctrl)
+		subject := mustAuthorizedStorage(t, clusterScopedStorage{basicStore}, gvr, mauth).(rest.CollectionDeleter)
+		_, err := subject.DeleteCollection(ctxWithInfo("deletecollection", ""), nil, nil, nil)
 		assert.ErrorContains(t, err, "not supported")
 	})
 }
@@ -285,6 +324,63 @@ func TestWatch(t *testing.T) {
 	})
 }
 
+func TestNamespaced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewMockStandardStorage(ctrl)
+	mauth := mock.NewMockAuthorizer(ctrl)
+
+	subject := mustAuthorizedStorage(t, namespaceScopedStandardStorage{store}, gvr, mauth).(authwrapper.StandardStorage)
+	require.True(t, subject.(rest.Scoper).NamespaceScoped())
+
+	t.Run("authorize in request namespace", func(t *testing.T) {
+		mauth.EXPECT().
+			Authorize(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+				assert.Equal(t, "ns-1", attr.GetNamespace())
+				assert.Equal(t, "tr1", attr.GetName())
+				return authorizer.DecisionAllow, "", nil
+			}).
+			Times(1)
+		store.EXPECT().
+			Get(gomock.Any(), "tr1", gomock.Any()).
+			Return(nil, nil).
+			Times(1)
+		_, err := subject.Get(ctxWithNamespacedInfo("get", "ns-1", "tr1"), "tr1", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("filter list by object namespace", func(t *testing.T) {
+		allowAuthResponse(mauth)
+		mauth.EXPECT().
+			Authorize(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+				if attr.GetNamespace() == "ns-1" {
+					return authorizer.DecisionAllow, "", nil
+				}
+				return authorizer.DecisionDeny, "", nil
+			}).
+			Times(2)
+		store.EXPECT().
+			NewList().
+			Return((&testresource.TestResource{}).NewList()).
+			Times(1)
+		store.EXPECT().
+			List(gomock.Any(), gomock.Any()).
+			Return((&testresource.TestResourceList{
+				Items: []testresource.TestResource{
+					{ObjectMeta: metav1.ObjectMeta{Name: "tr1", Namespace: "ns-1"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "tr1", Namespace: "ns-2"}},
+				},
+			}), nil).
+			Times(1)
+		list, err := subject.List(ctxWithInfo("list", ""), nil)
+		require.NoError(t, err)
+		require.Len(t, list.(*testresource.TestResourceList).Items, 1)
+		assert.Equal(t, "ns-1", list.(*testresource.TestResourceList).Items[0].Namespace)
+	})
+}
+
 func TestConnectMethods(t *testing.T) {
 	ctrl, stor, mauth, _ := setupStandardStorage(t)
 	defer ctrl.Finish()
@@ -417,15 +513,20 @@ func denyAuthResponse(mauth *mock.MockAuthorizer) *gomock.Call {
 }
 
 func ctxWithInfo(verb string, name string) context.Context {
+	return ctxWithNamespacedInfo(verb, "", name)
+}
+
+func ctxWithNamespacedInfo(verb string, namespace string, name string) context.Context {
 	return request.WithUser(
-		request.WithRequestInfo(request.NewContext(),
+		request.WithRequestInfo(request.WithNamespace(request.NewContext(), namespace),
 			&request.RequestInfo{
 				APIGroup:   gvr.Group,
 				APIVersion: gvr.Version,
 				Resource:   gvr.Resource,
 
-				Verb: verb,
-				Name: name,
+				Verb:      verb,
+				Namespace: namespace,
+				Name:      name,
 			}),
 		&user.DefaultInfo{
 			Name: "testuser",
@@ -479,6 +580,14 @@ func (clusterScopedStandardStorage) NamespaceScoped() bool {
 	return false
 }
 
+type namespaceScopedStandardStorage struct {
+	rest.StandardStorage
+}
+
+func (namespaceScopedStandardStorage) NamespaceScoped() bool {
+	return true
+}
+
 type clusterScopedStorage struct {
 	rest.Storage
 }
@@ -0,0 +1,65 @@
+package authwrapper
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/registry/rest"
+)
+
+// SubResourceStorage is the storage of an action subresource, such as `invitations/revoke`.
+// Requests to the subresource are creates on the subresource of a named object.
+type SubResourceStorage interface {
+	rest.Storage
+	rest.Scoper
+	rest.NamedCreater
+}
+
+var _ SubResourceStorage = &authorizedSubResourceStorage{}
+
+// authorizedSubResourceStorage is a wrapper around a SubResourceStorage
+// authorizing all requests with a fixed verb on the parent object.
+type authorizedSubResourceStorage struct {
+	storage    SubResourceStorage
+	authorizer Authorizer
+	verb       string
+}
+
+// NewAuthorizedSubResourceStorage returns a new wrapper around the given subresource storage.
+// Requests are authorized with the given verb on the parent object based on rbacID, e.g. verb `revoke` on `invitations` for `invitations/revoke`.
+func NewAuthorizedSubResourceStorage(storage SubResourceStorage, rbacID metav1.GroupVersionResource, verb string, auth authorizer.Authorizer) SubResourceStorage {
+	a := NewAuthorizer(rbacID, auth)
+	if storage.NamespaceScoped() {
+		a = NewNamespacedAuthorizer(rbacID, auth)
+	}
+	return &authorizedSubResourceStorage{
+		storage:    storage,
+		authorizer: a,
+		verb:       verb,
+	}
+}
+
+// New implements rest.Storage
+func (s *authorizedSubResourceStorage) New() runtime.Object {
+	return s.storage.New()
+}
+
+// Destroy implements rest.Storage
+func (s *authorizedSubResourceStorage) Destroy() {
+	s.storage.Destroy()
+}
+
+// NamespaceScoped implements rest.Scoper
+func (s *authorizedSubResourceStorage) NamespaceScoped() bool {
+	return s.storage.NamespaceScoped()
+}
+
+// Create implements rest.NamedCreater
+func (s *authorizedSubResourceStorage) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	if err := s.authorizer.AuthorizeVerb(ctx, s.verb, name); err != nil {
+		return nil, err
+	}
+	return s.storage.Create(ctx, name, obj, createValidation, opts)
+}
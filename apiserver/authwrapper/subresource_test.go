@@ -0,0 +1,67 @@
+package authwrapper_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"github.com/appuio/control-api/apiserver/authwrapper"
+	"github.com/appuio/control-api/apiserver/authwrapper/mock"
+	"github.com/appuio/control-api/apiserver/testresource"
+)
+
+func TestSubResourceCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mauth := mock.NewMockAuthorizer(ctrl)
+	store := &subResourceStorage{}
+
+	subject := authwrapper.NewAuthorizedSubResourceStorage(store, gvr, "revoke", mauth)
+
+	t.Run("allow", func(t *testing.T) {
+		var attr authorizer.Attributes
+		mauth.EXPECT().
+			Authorize(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+				attr = a
+				return authorizer.DecisionAllow, "", nil
+			}).
+			Times(1)
+
+		_, err := subject.Create(ctxWithInfo("create", "tr1"), "tr1", &testresource.TestResource{}, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tr1"}, store.created)
+		require.NotNil(t, attr)
+		assert.Equal(t, "revoke", attr.GetVerb())
+		assert.Equal(t, "tr1", attr.GetName())
+		assert.Equal(t, gvr.Resource, attr.GetResource())
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		denyAuthResponse(mauth)
+		_, err := subject.Create(ctxWithInfo("create", "tr2"), "tr2", &testresource.TestResource{}, nil, nil)
+		assert.ErrorContains(t, err, "forbidden")
+		assert.Equal(t, []string{"tr1"}, store.created)
+	})
+}
+
+// subResourceStorage is a SubResourceStorage recording the names of the objects it was called for.
+type subResourceStorage struct {
+	created []string
+}
+
+func (s *subResourceStorage) New() runtime.Object   { return &testresource.TestResource{} }
+func (s *subResourceStorage) Destroy()              {}
+func (s *subResourceStorage) NamespaceScoped() bool { return false }
+
+func (s *subResourceStorage) Create(_ context.Context, name string, obj runtime.Object, _ rest.ValidateObjectFunc, _ *metav1.CreateOptions) (runtime.Object, error) {
+	s.created = append(s.created, name)
+	return obj, nil
+}
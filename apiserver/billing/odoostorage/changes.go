@@ -0,0 +1,150 @@
+package odoostorage
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/watch"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	"github.com/appuio/control-api/apiserver/billing/odoostorage/odoo"
+)
+
+// DefaultWatchHistory is the number of changes retained for watches resuming from a resource version if no other size is configured.
+const DefaultWatchHistory = 1000
+
+// changeLog tracks the changes of the billing entities in Odoo, which doesn't provide resource versions.
+// Successive lists are diffed and every change is assigned the next resource version.
+// The log is shared by lists and all watchers, so all of them agree on the resource version of a change.
+// Resource versions are only kept in memory, they restart on every restart of the process.
+type changeLog struct {
+	// pollMu serializes polling, so lists are applied in the order they were read from Odoo.
+	pollMu   sync.Mutex
+	lastPoll time.Time
+
+	mu sync.RWMutex
+	// resourceVersion is the resource version of the latest change.
+	resourceVersion uint64
+	// known are the billing entities as of the latest change, with the resource version of their latest change.
+	known map[string]billingv1.BillingEntity
+	// history are the latest changes, oldest first.
+	history []change
+	// retain is the number of changes kept in the history.
+	// Defaults to DefaultWatchHistory if zero.
+	retain int
+}
+
+type change struct {
+	resourceVersion uint64
+	typ             watch.EventType
+	object          billingv1.BillingEntity
+}
+
+// poll lists the billing entities and records the changes since the previous poll.
+// If the storage was polled within maxAge, the storage is not listed again.
+func (c *changeLog) poll(ctx context.Context, storage odoo.OdooStorage, maxAge time.Duration) error {
+	c.pollMu.Lock()
+	defer c.pollMu.Unlock()
+
+	if maxAge > 0 && time.Since(c.lastPoll) < maxAge {
+		return nil
+	}
+	current, err := storage.List(ctx)
+	if err != nil {
+		return err
+	}
+	c.lastPoll = time.Now()
+	c.record(current)
+	return nil
+}
+
+// record diffs the given billing entities against the known ones and records the changes.
+func (c *changeLog) record(current []billingv1.BillingEntity) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.known == nil {
+		c.known = make(map[string]billingv1.BillingEntity, len(current))
+	}
+	add := func(typ watch.EventType, be billingv1.BillingEntity) {
+		c.resourceVersion++
+		be.ResourceVersion = strconv.FormatUint(c.resourceVersion, 10)
+		if typ == watch.Deleted {
+			delete(c.known, be.Name)
+		} else {
+			c.known[be.Name] = be
+		}
+		c.history = append(c.history, change{resourceVersion: c.resourceVersion, typ: typ, object: be})
+	}
+
+	seen := make(map[string]struct{}, len(current))
+	for _, be := range current {
+		seen[be.Name] = struct{}{}
+		old, ok := c.known[be.Name]
+		if !ok {
+			add(watch.Added, be)
+			continue
+		}
+		be.ResourceVersion = old.ResourceVersion
+		if !apiequality.Semantic.DeepEqual(old, be) {
+			add(watch.Modified, be)
+		}
+	}
+	for _, name := range sortedNames(c.known) {
+		if _, ok := seen[name]; !ok {
+			add(watch.Deleted, c.known[name])
+		}
+	}
+
+	retain := c.retain
+	if retain == 0 {
+		retain = DefaultWatchHistory
+	}
+	if len(c.history) > retain {
+		c.history = append([]change(nil), c.history[len(c.history)-retain:]...)
+	}
+}
+
+// snapshot returns the known billing entities sorted by name and the current resource version.
+func (c *changeLog) snapshot() ([]billingv1.BillingEntity, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	items := make([]billingv1.BillingEntity, 0, len(c.known))
+	for _, name := range sortedNames(c.known) {
+		items = append(items, c.known[name])
+	}
+	return items, c.resourceVersion
+}
+
+// since returns the changes after the given resource version.
+// It returns false if the changes are no longer retained or the resource version is unknown, for example because the process restarted.
+func (c *changeLog) since(resourceVersion uint64) ([]change, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if resourceVersion > c.resourceVersion {
+		return nil, false
+	}
+	if resourceVersion == c.resourceVersion {
+		return nil, true
+	}
+	if len(c.history) == 0 || c.history[0].resourceVersion > resourceVersion+1 {
+		return nil, false
+	}
+	i := sort.Search(len(c.history), func(i int) bool { return c.history[i].resourceVersion > resourceVersion })
+	return append([]change(nil), c.history[i:]...), true
+}
+
+func sortedNames(bes map[string]billingv1.BillingEntity) []string {
+	names := make([]string, 0, len(bes))
+	for name := range bes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,44 @@
+package odoostorage
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	"github.com/appuio/control-api/apiserver/billing/odoostorage/odoo"
+)
+
+// Delete archives the BillingEntity in the underlying storage.
+func (s *billingEntityStorage) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	be, err := s.storage.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, odoo.ErrNotFound) {
+			return nil, false, apierrors.NewNotFound((&billingv1.BillingEntity{}).GetGroupVersionResource().GroupResource(), name)
+		}
+		return nil, false, err
+	}
+
+	if deleteValidation != nil {
+		if err := deleteValidation(ctx, be); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if options != nil && len(options.DryRun) > 0 {
+		return be, true, nil
+	}
+
+	if err := s.storage.Delete(ctx, name); err != nil {
+		if errors.Is(err, odoo.ErrNotFound) {
+			return nil, false, apierrors.NewNotFound((&billingv1.BillingEntity{}).GetGroupVersionResource().GroupResource(), name)
+		}
+		return nil, false, err
+	}
+
+	return be, true, nil
+}
@@ -2,20 +2,30 @@ package odoostorage
 
 import (
 	"context"
+	"strconv"
 
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	billingv1 "github.com/appuio/control-api/apis/billing/v1"
 )
 
-func (s billingEntityStorage) NewList() runtime.Object {
+func (s *billingEntityStorage) NewList() runtime.Object {
 	return &billingv1.BillingEntityList{}
 }
 
+// List returns the billing entities with the resource versions of their latest change.
+// The list is recorded in the change log, watches can resume from the resource version of the list.
 func (s *billingEntityStorage) List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error) {
-	bel, err := s.storage.List(ctx)
+	if err := s.changes.poll(ctx, s.storage, 0); err != nil {
+		return &billingv1.BillingEntityList{}, err
+	}
+	bel, rv := s.changes.snapshot()
 	return &billingv1.BillingEntityList{
+		ListMeta: metav1.ListMeta{
+			ResourceVersion: strconv.FormatUint(rv, 10),
+		},
 		Items: bel,
-	}, err
+	}, nil
 }
@@ -99,6 +99,19 @@ func (s *fakeOdooStorage) List(ctx context.Context) ([]billingv1.BillingEntity,
 	return list, nil
 }
 
+func (s *fakeOdooStorage) Delete(ctx context.Context, name string) error {
+	s.storeLock.Lock()
+	defer s.storeLock.Unlock()
+
+	if _, ok := s.store[name]; !ok {
+		return odoo.ErrNotFound
+	}
+
+	delete(s.store, name)
+
+	return nil
+}
+
 func (s *fakeOdooStorage) nextID() uint64 {
 	return atomic.AddUint64(&s.idCounter, 2)
 }
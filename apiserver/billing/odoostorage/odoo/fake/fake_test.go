@@ -51,6 +51,11 @@ func TestFakeStorageE2E(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, bes, 1)
 	require.Equal(t, "Another Test", bes[0].Spec.Name)
+
+	require.NoError(t, s.Delete(ctx, "be-2345"))
+	_, err = s.Get(ctx, "be-2345")
+	require.ErrorIs(t, err, odoo.ErrNotFound)
+	require.ErrorIs(t, s.Delete(ctx, "be-2345"), odoo.ErrNotFound)
 }
 
 func TestFakeStorage_List(t *testing.T) {
@@ -16,6 +16,9 @@ type OdooStorage interface {
 	Update(ctx context.Context, be *billingv1.BillingEntity) error
 	// List retrieves a list of objects from the storage.
 	List(ctx context.Context) ([]billingv1.BillingEntity, error)
+	// Delete archives the object in the storage.
+	// Archived objects are no longer returned by Get or List.
+	Delete(ctx context.Context, name string) error
 }
 
 var ErrNotFound = errors.New("not found")
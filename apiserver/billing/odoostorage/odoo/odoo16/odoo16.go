@@ -379,6 +379,30 @@ func (s *Odoo16Storage) Update(ctx context.Context, be *billingv1.BillingEntity)
 	return nil
 }
 
+// Delete archives the company and the accounting contact of the billing entity.
+func (s *Odoo16Storage) Delete(ctx context.Context, name string) error {
+	l := klog.FromContext(ctx)
+
+	company, accounting, err := s.get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error fetching billing entity to delete: %w", err)
+	}
+
+	session, err := s.sessionCreator(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := session.Update(odooclient.ResPartnerModel, []int64{company.Id.Get(), accounting.Id.Get()}, map[string]any{
+		"active": false,
+	}); err != nil {
+		return fmt.Errorf("error archiving billing entity: %w", err)
+	}
+	l.Info("archived billing entity", "id", accounting.Id.Get(), "parent_id", company.Id.Get())
+
+	return nil
+}
+
 // CleanupIncompleteRecords looks for partner records in Odoo that still have the "inflight" flag set despite being older than `minAge`. Those records are then deleted.
 // Such records might come into existence due to a partially failed creation request.
 func (s *FailedRecordScrubber) CleanupIncompleteRecords(ctx context.Context, minAge time.Duration) error {
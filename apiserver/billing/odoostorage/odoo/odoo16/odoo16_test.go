@@ -315,6 +315,27 @@ func TestUpdate(t *testing.T) {
 	}, s)
 }
 
+func TestDelete(t *testing.T) {
+	ctrl, mock, subject := createStorage(t)
+	defer ctrl.Finish()
+
+	gomock.InOrder(
+		// Fetch existing company
+		mock.EXPECT().FindResPartners(gomock.Any(), gomock.Any()).Return(&odooclient.ResPartners{{
+			Id:       odooclient.NewInt(702),
+			ParentId: odooclient.NewMany2One(700, ""),
+		}}, nil),
+		mock.EXPECT().FindResPartners(gomock.Any(), gomock.Any()).Return(&odooclient.ResPartners{{
+			Id:   odooclient.NewInt(700),
+			Name: odooclient.NewString("Test Company"),
+		}}, nil),
+		// Archive company and accounting contact
+		mock.EXPECT().Update(odooclient.ResPartnerModel, []int64{700, 702}, map[string]any{"active": false}),
+	)
+
+	require.NoError(t, subject.Delete(context.Background(), "be-702"))
+}
+
 func Test_CreateUpdate_UnknownCountry(t *testing.T) {
 	ctrl, _, subject := createStorage(t)
 	defer ctrl.Finish()
@@ -290,6 +290,31 @@ func (s *Odoo8Storage) Update(ctx context.Context, be *billingv1.BillingEntity)
 	return nil
 }
 
+// Delete archives the company and the accounting contact of the billing entity.
+func (s *Odoo8Storage) Delete(ctx context.Context, name string) error {
+	l := klog.FromContext(ctx)
+
+	company, accounting, err := s.get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error fetching billing entity to delete: %w", err)
+	}
+
+	session, err := s.sessionCreator(ctx)
+	if err != nil {
+		return err
+	}
+	o := model.NewOdoo(session)
+
+	if err := o.UpdateRawPartner(ctx, []int{company.ID, accounting.ID}, map[string]any{
+		"active": false,
+	}); err != nil {
+		return fmt.Errorf("error archiving billing entity: %w", err)
+	}
+	l.Info("archived billing entity", "id", accounting.ID, "parent_id", company.ID)
+
+	return nil
+}
+
 // CleanupIncompleteRecords looks for partner records in Odoo that still have the "inflight" flag set despite being older than `minAge`. Those records are then deleted.
 // Such records might come into existence due to a partially failed creation request.
 func (s *FailedRecordScrubber) CleanupIncompleteRecords(ctx context.Context, minAge time.Duration) error {
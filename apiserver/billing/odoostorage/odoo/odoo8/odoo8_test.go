@@ -422,6 +422,29 @@ func createFailedRecordScrubber(t *testing.T) (*gomock.Controller, *clientmock.M
 	}
 }
 
+func TestDelete(t *testing.T) {
+	ctrl, mock, subject := createStorage(t)
+	defer ctrl.Finish()
+
+	gomock.InOrder(
+		// Fetch existing company
+		mock.EXPECT().SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, model.PartnerList{
+			Items: []model.Partner{
+				{ID: 702, Parent: model.OdooCompositeID{ID: 700, Valid: true}},
+			},
+		}),
+		mock.EXPECT().SearchGenericModel(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, model.PartnerList{
+			Items: []model.Partner{
+				{ID: 700, Name: "Test Company"},
+			},
+		}),
+		// Archive company and accounting contact
+		mock.EXPECT().UpdateGenericModel(gomock.Any(), model.PartnerModel, []int{700, 702}, map[string]any{"active": false}),
+	)
+
+	require.NoError(t, subject.Delete(context.Background(), "be-702"))
+}
+
 func TestCleanup(t *testing.T) {
 	ctrl, mock, subject := createFailedRecordScrubber(t)
 	defer ctrl.Finish()
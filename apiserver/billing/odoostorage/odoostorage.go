@@ -1,6 +1,8 @@
 package odoostorage
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apiserver/pkg/registry/rest"
 
@@ -34,6 +36,12 @@ func NewOdoo16Storage(credentials odoo16.OdooCredentials, config odoo16.Config)
 
 type billingEntityStorage struct {
 	storage odoo.OdooStorage
+
+	// watchPollInterval is the interval in which watchers poll the storage for changes.
+	// Defaults to DefaultWatchPollInterval if zero.
+	watchPollInterval time.Duration
+	// changes assigns resource versions to the changes of the billing entities, shared by lists and watchers.
+	changes changeLog
 }
 
 // Storage defines the features of a storage provider for BillingEntities
@@ -44,15 +52,17 @@ type Storage interface {
 	rest.CreaterUpdater
 	rest.Lister
 	rest.Getter
+	rest.Watcher
+	rest.GracefulDeleter
 }
 
 var _ Storage = &billingEntityStorage{}
 
-func (s billingEntityStorage) New() runtime.Object {
+func (s *billingEntityStorage) New() runtime.Object {
 	return &billingv1.BillingEntity{}
 }
 
-func (s billingEntityStorage) Destroy() {}
+func (s *billingEntityStorage) Destroy() {}
 
 func (s *billingEntityStorage) NamespaceScoped() bool {
 	return false
@@ -0,0 +1,148 @@
+package odoostorage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+)
+
+// DefaultWatchPollInterval is the interval in which the storage is polled for changes if no other interval is configured.
+const DefaultWatchPollInterval = 10 * time.Second
+
+// Watch returns a watcher polling the underlying storage for changes.
+// Odoo does not support watching for changes, so successive lists are diffed and the differences emitted as events.
+// The changes are recorded in a log shared by all watchers and lists, so every watcher sees the same resource version for a change.
+// If no resource version is given, the current state is emitted as ADDED events first.
+// Watches can resume from any resource version whose changes are still retained, older resource versions are rejected as expired, making the client list again.
+// Resource versions are only kept in memory, resource versions from before a restart of the process are rejected as well.
+func (s *billingEntityStorage) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
+	if err := s.changes.poll(ctx, s.storage, 0); err != nil {
+		return nil, err
+	}
+
+	var initial []change
+	var resourceVersion uint64
+	if options == nil || options.ResourceVersion == "" || options.ResourceVersion == "0" {
+		var items []billingv1.BillingEntity
+		items, resourceVersion = s.changes.snapshot()
+		for _, be := range items {
+			initial = append(initial, change{typ: watch.Added, object: be})
+		}
+	} else {
+		rv, err := strconv.ParseUint(options.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q: %s", options.ResourceVersion, err))
+		}
+		changes, ok := s.changes.since(rv)
+		if !ok {
+			return nil, expired(options.ResourceVersion)
+		}
+		initial, resourceVersion = changes, rv
+		if len(changes) > 0 {
+			resourceVersion = changes[len(changes)-1].resourceVersion
+		}
+	}
+
+	ch := make(chan watch.Event)
+	w := watch.NewProxyWatcher(ch)
+	go s.pollChanges(ctx, ch, w, options, initial, resourceVersion)
+	return w, nil
+}
+
+// pollChanges emits the initial changes and then polls for the changes after the given resource version.
+// The storage is listed at most once per poll interval, no matter how many watchers are polling.
+func (s *billingEntityStorage) pollChanges(ctx context.Context, ch chan<- watch.Event, w *watch.ProxyWatcher, options *metainternalversion.ListOptions, initial []change, resourceVersion uint64) {
+	defer w.Stop()
+	defer close(ch)
+	l := klog.FromContext(ctx)
+
+	interval := s.watchPollInterval
+	if interval == 0 {
+		interval = DefaultWatchPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	send := func(ev watch.Event) bool {
+		select {
+		case ch <- ev:
+			return true
+		case <-w.StopChan():
+		case <-ctx.Done():
+		}
+		return false
+	}
+	sendChanges := func(changes []change) bool {
+		for _, c := range changes {
+			if !matchesListOptions(c.object, options) {
+				continue
+			}
+			be := c.object
+			if !send(watch.Event{Type: c.typ, Object: &be}) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !sendChanges(initial) {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.StopChan():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.changes.poll(ctx, s.storage, interval/2); err != nil {
+			l.Error(err, "failed to poll billing entities")
+			continue
+		}
+		changes, ok := s.changes.since(resourceVersion)
+		if !ok {
+			// The watcher fell behind the retained changes, the client has to list again.
+			rv := strconv.FormatUint(resourceVersion, 10)
+			send(watch.Event{Type: watch.Error, Object: &expired(rv).ErrStatus})
+			return
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if !sendChanges(changes) {
+			return
+		}
+		resourceVersion = changes[len(changes)-1].resourceVersion
+	}
+}
+
+// expired returns the error for watches from resource versions whose changes are no longer retained.
+func expired(resourceVersion string) *apierrors.StatusError {
+	return apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %s", resourceVersion))
+}
+
+func matchesListOptions(be billingv1.BillingEntity, options *metainternalversion.ListOptions) bool {
+	if options == nil {
+		return true
+	}
+	if options.LabelSelector != nil && !options.LabelSelector.Matches(labels.Set(be.Labels)) {
+		return false
+	}
+	if options.FieldSelector != nil && !options.FieldSelector.Matches(fields.Set{"metadata.name": be.Name}) {
+		return false
+	}
+	return true
+}
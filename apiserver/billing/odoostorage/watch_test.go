@@ -0,0 +1,193 @@
+package odoostorage
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	"github.com/appuio/control-api/apiserver/billing/odoostorage/odoo"
+)
+
+func TestBillingEntityStorage_Watch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stor := &listStorage{items: []billingv1.BillingEntity{newBE("be-1", "one")}}
+	s := &billingEntityStorage{storage: stor, watchPollInterval: 10 * time.Millisecond}
+
+	w, err := s.Watch(ctx, &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	lastRV := 0
+	expectEvent := func(t *testing.T, typ watch.EventType, name, displayName string) {
+		t.Helper()
+		select {
+		case ev := <-w.ResultChan():
+			require.Equal(t, typ, ev.Type)
+			be := ev.Object.(*billingv1.BillingEntity)
+			assert.Equal(t, name, be.Name)
+			assert.Equal(t, displayName, be.Spec.Name)
+			rv, err := strconv.Atoi(be.ResourceVersion)
+			require.NoError(t, err)
+			assert.Greater(t, rv, lastRV, "resource version must increase")
+			lastRV = rv
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", typ)
+		}
+	}
+
+	expectEvent(t, watch.Added, "be-1", "one")
+
+	stor.set(newBE("be-1", "one"), newBE("be-2", "two"))
+	expectEvent(t, watch.Added, "be-2", "two")
+
+	stor.set(newBE("be-1", "uno"), newBE("be-2", "two"))
+	expectEvent(t, watch.Modified, "be-1", "uno")
+
+	stor.set(newBE("be-1", "uno"))
+	expectEvent(t, watch.Deleted, "be-2", "two")
+
+	cancel()
+	select {
+	case _, ok := <-w.ResultChan():
+		assert.False(t, ok, "result channel should be closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watcher to stop")
+	}
+}
+
+func TestBillingEntityStorage_Watch_FieldSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stor := &listStorage{items: []billingv1.BillingEntity{newBE("be-1", "one"), newBE("be-2", "two")}}
+	s := &billingEntityStorage{storage: stor, watchPollInterval: 10 * time.Millisecond}
+
+	w, err := s.Watch(ctx, &metainternalversion.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", "be-2"),
+	})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	select {
+	case ev := <-w.ResultChan():
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "be-2", ev.Object.(*billingv1.BillingEntity).Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+
+	stor.set(newBE("be-1", "uno"), newBE("be-2", "two"))
+	select {
+	case ev := <-w.ResultChan():
+		t.Fatalf("unexpected event %s for %s", ev.Type, ev.Object.(*billingv1.BillingEntity).Name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBillingEntityStorage_Watch_ResourceVersion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stor := &listStorage{items: []billingv1.BillingEntity{newBE("be-1", "one")}}
+	s := &billingEntityStorage{storage: stor, watchPollInterval: 10 * time.Millisecond}
+	s.changes.retain = 2
+
+	list := func() string {
+		t.Helper()
+		l, err := s.List(ctx, &metainternalversion.ListOptions{})
+		require.NoError(t, err)
+		return l.(*billingv1.BillingEntityList).ResourceVersion
+	}
+	first := list()
+	stor.set(newBE("be-1", "one"), newBE("be-2", "two"))
+	second := list()
+	stor.set(newBE("be-1", "one"), newBE("be-2", "two"), newBE("be-3", "three"))
+	list()
+	stor.set(newBE("be-1", "one"), newBE("be-2", "two"), newBE("be-3", "three"), newBE("be-4", "four"))
+	latest := list()
+
+	_, err := s.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: first})
+	require.Error(t, err)
+	assert.True(t, apierrors.IsResourceExpired(err), "resource versions older than the retained changes must expire")
+	_, err = s.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: "1000"})
+	require.Error(t, err)
+	assert.True(t, apierrors.IsResourceExpired(err), "unknown resource versions must expire")
+
+	resumed, err := s.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: second})
+	require.NoError(t, err)
+	defer resumed.Stop()
+	for _, name := range []string{"be-3", "be-4"} {
+		select {
+		case ev := <-resumed.ResultChan():
+			assert.Equal(t, watch.Added, ev.Type)
+			assert.Equal(t, name, ev.Object.(*billingv1.BillingEntity).Name, "watch resumed from a retained resource version must emit the changes since")
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+
+	watchers := make([]watch.Interface, 2)
+	for i := range watchers {
+		watchers[i], err = s.Watch(ctx, &metainternalversion.ListOptions{ResourceVersion: latest})
+		require.NoError(t, err)
+		defer watchers[i].Stop()
+	}
+	stor.set(newBE("be-1", "uno"), newBE("be-2", "two"), newBE("be-3", "three"), newBE("be-4", "four"))
+	rvs := []string{}
+	for _, w := range append(watchers, resumed) {
+		select {
+		case ev := <-w.ResultChan():
+			assert.Equal(t, watch.Modified, ev.Type)
+			assert.Equal(t, "be-1", ev.Object.(*billingv1.BillingEntity).Name)
+			rvs = append(rvs, ev.Object.(*billingv1.BillingEntity).ResourceVersion)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+	}
+	assert.Equal(t, []string{rvs[0], rvs[0], rvs[0]}, rvs, "all watchers must see the same resource version for a change")
+
+	l, err := s.List(ctx, &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, rvs[0], l.(*billingv1.BillingEntityList).ResourceVersion)
+	assert.Equal(t, rvs[0], l.(*billingv1.BillingEntityList).Items[0].ResourceVersion, "items must have the resource version of their latest change")
+}
+
+func newBE(name, displayName string) billingv1.BillingEntity {
+	return billingv1.BillingEntity{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       billingv1.BillingEntitySpec{Name: displayName},
+	}
+}
+
+// listStorage is an odoo.OdooStorage whose List result can be replaced.
+type listStorage struct {
+	odoo.OdooStorage
+
+	mu    sync.Mutex
+	items []billingv1.BillingEntity
+}
+
+func (s *listStorage) set(items ...billingv1.BillingEntity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = items
+}
+
+func (s *listStorage) List(_ context.Context) ([]billingv1.BillingEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]billingv1.BillingEntity{}, s.items...), nil
+}
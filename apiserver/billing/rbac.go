@@ -2,9 +2,12 @@ package billing
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -13,6 +16,8 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
 	"github.com/appuio/control-api/apiserver/billing/odoostorage"
 	"github.com/appuio/control-api/pkg/billingrbac"
 )
@@ -21,6 +26,7 @@ import (
 // +kubebuilder:rbac:groups=rbac.appuio.io;billing.appuio.io,resources=billingentities,verbs=*
 
 // createRBACWrapper is a wrapper around the storage that creates a ClusterRole and ClusterRoleBinding for each BillingEntity on creation.
+// The ClusterRoles and ClusterRoleBindings are removed again on deletion.
 type createRBACWrapper struct {
 	odoostorage.Storage
 	client client.Client
@@ -78,3 +84,51 @@ func (c *createRBACWrapper) Create(ctx context.Context, obj runtime.Object, crea
 
 	return createdObj, nil
 }
+
+// Delete deletes the BillingEntity and its ClusterRoles and ClusterRoleBindings.
+// Deletion is refused as long as an Organization references the BillingEntity.
+// The references are checked in the delete validation, after the wrapped storage authorized the request.
+func (c *createRBACWrapper) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, opts *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	validate := func(ctx context.Context, obj runtime.Object) error {
+		if err := c.validateNotReferenced(ctx, name); err != nil {
+			return err
+		}
+		if deleteValidation != nil {
+			return deleteValidation(ctx, obj)
+		}
+		return nil
+	}
+
+	deletedObj, immediate, err := c.Storage.Delete(ctx, name, validate, opts)
+	if err != nil {
+		return deletedObj, immediate, err
+	}
+
+	var dryRun []string
+	if opts != nil {
+		dryRun = opts.DryRun
+	}
+	ar, arb, vr, vrb := billingrbac.ClusterRoles(name, billingrbac.ClusterRolesParams{})
+	var deleteErr error
+	for _, obj := range []client.Object{ar, arb, vr, vrb} {
+		multierr.AppendInto(&deleteErr, client.IgnoreNotFound(c.client.Delete(ctx, obj, &client.DeleteOptions{DryRun: dryRun})))
+	}
+	return deletedObj, immediate, deleteErr
+}
+
+// validateNotReferenced returns a Conflict error if an Organization references the BillingEntity.
+// The error does not name the organizations, the user deleting the BillingEntity might not be allowed to see them.
+func (c *createRBACWrapper) validateNotReferenced(ctx context.Context, name string) error {
+	nsl := corev1.NamespaceList{}
+	if err := c.client.List(ctx, &nsl, client.MatchingLabels{orgv1.TypeKey: orgv1.OrgType}); err != nil {
+		return fmt.Errorf("could not list organizations: %w", err)
+	}
+	for _, ns := range nsl.Items {
+		if ns.Annotations[orgv1.BillingEntityRefKey] == name {
+			return apierrors.NewConflict(
+				(&billingv1.BillingEntity{}).GetGroupVersionResource().GroupResource(), name,
+				errors.New("billing entity is still referenced by organizations"))
+		}
+	}
+	return nil
+}
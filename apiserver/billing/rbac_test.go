@@ -2,15 +2,18 @@ package billing
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -20,8 +23,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
 	"github.com/appuio/control-api/apiserver/authwrapper/mock"
 	"github.com/appuio/control-api/apiserver/testresource"
+	"github.com/appuio/control-api/pkg/billingrbac"
 )
 
 func Test_createRBACWrapper(t *testing.T) {
@@ -100,6 +105,105 @@ func Test_createRBACWrapper_rollback(t *testing.T) {
 	assert.True(t, apierrors.IsNotFound(err), "expected role to be deleted on rollback")
 }
 
+func Test_createRBACWrapper_Delete(t *testing.T) {
+	beName := "be-2345"
+
+	c := newClient()
+	ctrl, store := newStore(t)
+	defer ctrl.Finish()
+
+	subject := &createRBACWrapper{
+		Storage: clusterScopedStorage{store},
+		client:  c,
+	}
+
+	ar, arb, vr, vrb := billingrbac.ClusterRoles(beName, billingrbac.ClusterRolesParams{})
+	for _, obj := range []client.Object{ar, arb, vr} {
+		require.NoError(t, c.Create(context.Background(), obj))
+	}
+
+	store.EXPECT().
+		Delete(gomock.Any(), beName, gomock.Any(), gomock.Any()).
+		Return(&testresource.TestResource{ObjectMeta: metav1.ObjectMeta{Name: beName}}, true, nil).
+		Times(1)
+
+	_, _, err := subject.Delete(ctxWithInfo("delete", beName, "testuser"), beName, nil, &metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	for _, obj := range []client.Object{ar, arb, vr, vrb} {
+		err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj)
+		assert.True(t, apierrors.IsNotFound(err), "expected %s to be deleted", obj.GetName())
+	}
+}
+
+func Test_createRBACWrapper_Delete_referenced(t *testing.T) {
+	beName := "be-2345"
+
+	c := newClient()
+	ctrl, store := newStore(t)
+	defer ctrl.Finish()
+
+	subject := &createRBACWrapper{
+		Storage: clusterScopedStorage{store},
+		client:  c,
+	}
+
+	require.NoError(t, c.Create(context.Background(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "acme-corp",
+			Labels:      map[string]string{orgv1.TypeKey: orgv1.OrgType},
+			Annotations: map[string]string{orgv1.BillingEntityRefKey: beName},
+		},
+	}))
+	viewer := "billingentities-" + beName + "-viewer"
+	require.NoError(t, c.Create(context.Background(), &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: viewer}}))
+
+	store.EXPECT().
+		Delete(gomock.Any(), beName, gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, _ *metav1.DeleteOptions) (runtime.Object, bool, error) {
+			return nil, false, deleteValidation(ctx, &testresource.TestResource{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		}).
+		Times(1)
+
+	_, _, err := subject.Delete(ctxWithInfo("delete", beName, "testuser"), beName, nil, &metav1.DeleteOptions{})
+	require.Error(t, err)
+	assert.True(t, apierrors.IsConflict(err), "expected conflict error, got %v", err)
+	assert.NotContains(t, err.Error(), "acme-corp", "organization names must not be leaked")
+
+	var role rbacv1.ClusterRole
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: viewer}, &role), "expected role to be kept")
+}
+
+func Test_createRBACWrapper_Delete_referenced_unauthorized(t *testing.T) {
+	beName := "be-2345"
+
+	c := newClient()
+	ctrl, store := newStore(t)
+	defer ctrl.Finish()
+
+	subject := &createRBACWrapper{
+		Storage: clusterScopedStorage{store},
+		client:  c,
+	}
+
+	require.NoError(t, c.Create(context.Background(), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "org",
+			Labels:      map[string]string{orgv1.TypeKey: orgv1.OrgType},
+			Annotations: map[string]string{orgv1.BillingEntityRefKey: beName},
+		},
+	}))
+
+	// The authorized storage rejects the request before calling the delete validation
+	store.EXPECT().
+		Delete(gomock.Any(), beName, gomock.Any(), gomock.Any()).
+		Return(nil, false, apierrors.NewForbidden(schema.GroupResource{Group: "billing.appuio.io", Resource: "billingentities"}, beName, errors.New("not allowed"))).
+		Times(1)
+
+	_, _, err := subject.Delete(ctxWithInfo("delete", beName, "testuser"), beName, nil, &metav1.DeleteOptions{})
+	assert.True(t, apierrors.IsForbidden(err), "expected forbidden error before the reference check, got %v", err)
+}
+
 func newStore(t *testing.T) (*gomock.Controller, *mock.MockStandardStorage) {
 	ctrl := gomock.NewController(t)
 	store := mock.NewMockStandardStorage(ctrl)
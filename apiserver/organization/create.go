@@ -70,10 +70,10 @@ func (s *organizationStorage) create(ctx context.Context, org *orgv1.Organizatio
 }
 
 func newOrganizationMembers(ctx context.Context, organization *orgv1.Organization, usernamePrefix string) *controlv1.OrganizationMembers {
-	userRefs := []controlv1.UserRef{}
+	userRefs := []controlv1.OrganizationMemberRef{}
 	user, ok := userFrom(ctx, usernamePrefix)
 	if ok {
-		userRefs = append(userRefs, controlv1.UserRef{
+		userRefs = append(userRefs, controlv1.OrganizationMemberRef{
 			Name: strings.TrimPrefix(user.GetName(), usernamePrefix),
 		})
 	}
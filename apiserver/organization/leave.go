@@ -0,0 +1,267 @@
+package organization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
+	restbuilder "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/apiserver/authwrapper"
+	"github.com/appuio/control-api/controllers/targetref"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/orgadmins"
+)
+
+// +kubebuilder:rbac:groups="appuio.io",resources=users,verbs=get;update
+
+// NewLeaveStorage returns a new storage provider for the `organizations/leave` subresource.
+// The admins of an organization are computed like in the organization admin webhook, from the admin roles and the roles of the organization members.
+// The admin roles default to `<organizationRolePrefix>admin`.
+func NewLeaveStorage(usernamePrefix, organizationRolePrefix *string, adminRoles, memberRoles, allowedMemberRoles *[]string) restbuilder.ResourceHandlerProvider {
+	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		c, err := sharedClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return &organizationLeaver{
+			client:                 c,
+			usernamePrefix:         *usernamePrefix,
+			organizationRolePrefix: *organizationRolePrefix,
+			admins: orgadmins.Resolver{
+				AdminRoles: orgadmins.AdminRolesOrDefault(*adminRoles, *organizationRolePrefix),
+				UserPrefix: *usernamePrefix,
+				MemberRoles: memberroles.Resolver{
+					DefaultRoles: *memberRoles,
+					AllowedRoles: *allowedMemberRoles,
+				},
+			},
+		}, nil
+	}
+}
+
+var _ authwrapper.SubResourceStorage = &organizationLeaver{}
+
+// organizationLeaver implements the `organizations/leave` subresource.
+// Requests are not authorized on the organization, users can only remove themselves from organizations they are a member of.
+type organizationLeaver struct {
+	client client.Client
+
+	usernamePrefix         string
+	organizationRolePrefix string
+	admins                 orgadmins.Resolver
+}
+
+func (l organizationLeaver) NamespaceScoped() bool {
+	return false
+}
+
+func (l organizationLeaver) New() runtime.Object {
+	return &orgv1.OrganizationLeaveRequest{}
+}
+
+func (l organizationLeaver) Destroy() {}
+
+// Create removes the requesting user from the organization with the given name, it accepts `OrganizationLeaveRequest`.
+// The user is removed from the organization members, all teams of the organization, and the organization role bindings.
+// If the user's default organization is the organization, the default organization is cleared.
+// The last admin of an organization can't leave it.
+//
+// The steps are not rolled back on failure. The user is removed from the organization members first, the remaining steps only remove leftovers.
+// A failed request can be retried: a user who is no longer a member but is still referenced by the organization can leave again to remove the leftovers.
+func (l *organizationLeaver) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	lr, ok := obj.(*orgv1.OrganizationLeaveRequest)
+	if !ok {
+		return nil, fmt.Errorf("not an OrganizationLeaveRequest: %#v", obj)
+	}
+
+	user, ok := userFrom(ctx, l.usernamePrefix)
+	if !ok {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Group: orgv1.GroupVersion.Group, Resource: "organizations"}, name, errors.New("only users can leave organizations"))
+	}
+	username := strings.TrimPrefix(user.GetName(), l.usernamePrefix)
+
+	ns := corev1.Namespace{}
+	if err := l.client.Get(ctx, client.ObjectKey{Name: name}, &ns); err != nil {
+		return nil, convertNamespaceError(err)
+	}
+	if orgv1.NewOrganizationFromNS(&ns) == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: orgv1.GroupVersion.Group, Resource: "organizations"}, name)
+	}
+
+	dryRun := opts.DryRun
+	wasMember, err := l.leaveMembers(ctx, name, username, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	leftTeams, err := l.leaveTeams(ctx, name, username, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove user from teams: %w", err)
+	}
+	leftRoleBindings, err := l.leaveRoleBindings(ctx, name, username, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove user from organization roles: %w", err)
+	}
+	if !wasMember && !leftTeams && !leftRoleBindings {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("user %q is not a member of organization %q", username, name))
+	}
+	if err := l.clearDefaultOrganization(ctx, name, username, dryRun); err != nil {
+		return nil, fmt.Errorf("failed to clear default organization: %w", err)
+	}
+
+	return lr, nil
+}
+
+// leaveMembers removes the user from the organization members and returns whether the user was a member.
+// The admin check and the update use the same version of the organization members, so concurrent leave requests are serialized by the update.
+func (l *organizationLeaver) leaveMembers(ctx context.Context, organization, username string, dryRun []string) (wasMember bool, err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		members := controlv1.OrganizationMembers{}
+		if err := l.client.Get(ctx, client.ObjectKey{Name: "members", Namespace: organization}, &members); err != nil {
+			return err
+		}
+		a, err := targetref.NewUserAccessor(&members)
+		if err != nil {
+			return err
+		}
+		wasMember = a.RemoveUser("", username)
+		if !wasMember {
+			return nil
+		}
+		if err := l.checkNotLastAdmin(ctx, &members, username); err != nil {
+			return err
+		}
+		return l.client.Update(ctx, &members, &client.UpdateOptions{DryRun: dryRun})
+	})
+	if err != nil && !apierrors.IsBadRequest(err) {
+		return wasMember, fmt.Errorf("failed to remove user from organization members: %w", err)
+	}
+	return wasMember, err
+}
+
+// checkNotLastAdmin returns an error if the user is an admin of the organization and no other admin remains after the organization members change to memb.
+// Admin users only count if they remain organization members. Since the user is removed from the organization members before any other step,
+// admins concurrently leaving the organization don't count for each other.
+func (l *organizationLeaver) checkNotLastAdmin(ctx context.Context, memb *controlv1.OrganizationMembers, username string) error {
+	rbs := rbacv1.RoleBindingList{}
+	if err := l.client.List(ctx, &rbs, client.InNamespace(memb.Namespace)); err != nil {
+		return err
+	}
+	user := l.usernamePrefix + username
+
+	isAdmin := false
+	for _, s := range l.admins.Subjects(rbs.Items, nil) {
+		if s.Kind == rbacv1.UserKind && s.Name == user {
+			isAdmin = true
+		}
+	}
+	if !isAdmin {
+		return nil
+	}
+
+	members, err := targetref.NewUserAccessor(memb)
+	if err != nil {
+		return err
+	}
+	for _, s := range l.admins.Subjects(rbs.Items, l.admins.ReplaceMembers(memb.Name, memb)) {
+		if s.Kind != rbacv1.UserKind {
+			return nil
+		}
+		if s.Name != user && strings.HasPrefix(s.Name, l.usernamePrefix) && members.HasUser("", strings.TrimPrefix(s.Name, l.usernamePrefix)) {
+			return nil
+		}
+	}
+	return apierrors.NewBadRequest(fmt.Sprintf("user %q is the last admin of organization %q, add another admin before leaving", username, memb.Namespace))
+}
+
+// leaveTeams removes the user from all teams of the organization and returns whether the user was removed from any team.
+func (l *organizationLeaver) leaveTeams(ctx context.Context, organization, username string, dryRun []string) (left bool, err error) {
+	teams := controlv1.TeamList{}
+	if err := l.client.List(ctx, &teams, client.InNamespace(organization)); err != nil {
+		return false, err
+	}
+	for _, team := range teams.Items {
+		team := team
+		a, err := targetref.NewUserAccessor(&team)
+		if err != nil {
+			return left, err
+		}
+		if !a.HasUser("", username) {
+			continue
+		}
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := l.client.Get(ctx, client.ObjectKeyFromObject(&team), &team); err != nil {
+				return err
+			}
+			a, err := targetref.NewUserAccessor(&team)
+			if err != nil {
+				return err
+			}
+			if !a.RemoveUser("", username) {
+				return nil
+			}
+			return l.client.Update(ctx, &team, &client.UpdateOptions{DryRun: dryRun})
+		})
+		if err != nil {
+			return left, err
+		}
+		left = true
+	}
+	return left, nil
+}
+
+// leaveRoleBindings removes the user from the organization role RoleBindings, such as the admin RoleBinding, and returns whether the user was removed from any RoleBinding.
+// RoleBindings of member roles are managed through the organization members.
+func (l *organizationLeaver) leaveRoleBindings(ctx context.Context, organization, username string, dryRun []string) (left bool, err error) {
+	rbs := rbacv1.RoleBindingList{}
+	if err := l.client.List(ctx, &rbs, client.InNamespace(organization)); err != nil {
+		return false, err
+	}
+	for _, rb := range rbs.Items {
+		if !strings.HasPrefix(rb.Name, l.organizationRolePrefix) {
+			continue
+		}
+		rb := rb
+		a, err := targetref.NewUserAccessor(&rb)
+		if err != nil {
+			return left, err
+		}
+		if !a.RemoveUser(l.usernamePrefix, username) {
+			continue
+		}
+		if err := l.client.Update(ctx, &rb, &client.UpdateOptions{DryRun: dryRun}); err != nil {
+			return left, err
+		}
+		left = true
+	}
+	return left, nil
+}
+
+// clearDefaultOrganization clears the default organization of the user if it is the given organization.
+func (l *organizationLeaver) clearDefaultOrganization(ctx context.Context, organization, username string, dryRun []string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u := controlv1.User{}
+		if err := l.client.Get(ctx, client.ObjectKey{Name: username}, &u); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if u.Spec.Preferences.DefaultOrganizationRef != organization {
+			return nil
+		}
+		u.Spec.Preferences.DefaultOrganizationRef = ""
+		return l.client.Update(ctx, &u, &client.UpdateOptions{DryRun: dryRun})
+	})
+}
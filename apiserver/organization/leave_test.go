@@ -0,0 +1,202 @@
+package organization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/orgadmins"
+)
+
+func TestOrganizationLeaver_Create(t *testing.T) {
+	c := newLeaveClient(t, leaveFixtures(
+		[]rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#leaver"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#admin"},
+		},
+	)...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	members := controlv1.OrganizationMembers{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "members", Namespace: "foo"}, &members))
+	assert.Equal(t, []controlv1.OrganizationMemberRef{{Name: "admin"}}, members.Spec.UserRefs)
+
+	team := controlv1.Team{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "dev", Namespace: "foo"}, &team))
+	assert.Equal(t, []controlv1.UserRef{{Name: "admin"}}, team.Spec.UserRefs)
+
+	admins := rbacv1.RoleBinding{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "control-api:organization-admin", Namespace: "foo"}, &admins))
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#admin"}}, admins.Subjects)
+
+	u := controlv1.User{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "leaver"}, &u))
+	assert.Empty(t, u.Spec.Preferences.DefaultOrganizationRef)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "admin"}, &u))
+	assert.Equal(t, "foo", u.Spec.Preferences.DefaultOrganizationRef, "other users must not be changed")
+
+	_, err = subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request after leaving, got %v", err)
+}
+
+func TestOrganizationLeaver_Create_LastAdmin(t *testing.T) {
+	c := newLeaveClient(t, leaveFixtures(
+		[]rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#leaver"}},
+	)...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+
+	members := controlv1.OrganizationMembers{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "members", Namespace: "foo"}, &members))
+	assert.Len(t, members.Spec.UserRefs, 2, "members must not be changed")
+
+	_, err = subject.Create(leaveCtx("appuio#admin"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "non-admins can leave")
+}
+
+func TestOrganizationLeaver_Create_LastAdmin_MemberRole(t *testing.T) {
+	objs := leaveFixtures(nil)
+	members := objs[1].(*controlv1.OrganizationMembers)
+	members.Spec.UserRefs[0].Roles = []string{"org-owner"}
+	objs = append(objs, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      memberroles.RoleBindingName("org-owner"),
+			Namespace: "foo",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: controlv1.GroupVersion.String(),
+				Kind:       "OrganizationMembers",
+				Name:       "members",
+				Controller: pointer.Bool(true),
+			}},
+		},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#leaver"}},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "org-owner"},
+	})
+	c := newLeaveClient(t, objs...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "admins through member roles must count, got %v", err)
+}
+
+func TestOrganizationLeaver_Create_LastAdmin_OtherAdminNotMember(t *testing.T) {
+	c := newLeaveClient(t, leaveFixtures(
+		[]rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#leaver"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#stranger"},
+		},
+	)...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "admins which are not members must not count, got %v", err)
+}
+
+func TestOrganizationLeaver_Create_Retry(t *testing.T) {
+	objs := leaveFixtures(nil)
+	members := objs[1].(*controlv1.OrganizationMembers)
+	members.Spec.UserRefs = []controlv1.OrganizationMemberRef{{Name: "admin"}}
+	c := newLeaveClient(t, objs...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err, "leftovers of a failed request must be removed")
+
+	team := controlv1.Team{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "dev", Namespace: "foo"}, &team))
+	assert.Equal(t, []controlv1.UserRef{{Name: "admin"}}, team.Spec.UserRefs)
+	u := controlv1.User{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "leaver"}, &u))
+	assert.Empty(t, u.Spec.Preferences.DefaultOrganizationRef)
+}
+
+func TestOrganizationLeaver_Create_Invalid(t *testing.T) {
+	c := newLeaveClient(t, append(leaveFixtures(nil), barNs.DeepCopy())...)
+	subject := newLeaver(c)
+
+	_, err := subject.Create(leaveCtx("appuio#leaver"), "missing", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+
+	_, err = subject.Create(leaveCtx("appuio#stranger"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+
+	_, err = subject.Create(leaveCtx("system:admin"), "foo", &orgv1.OrganizationLeaveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsForbidden(err), "expected forbidden, got %v", err)
+}
+
+func newLeaver(c client.Client) *organizationLeaver {
+	return &organizationLeaver{
+		client:                 c,
+		usernamePrefix:         "appuio#",
+		organizationRolePrefix: "control-api:organization-",
+		admins: orgadmins.Resolver{
+			AdminRoles: []string{"control-api:organization-admin", "org-owner"},
+			UserPrefix: "appuio#",
+			MemberRoles: memberroles.Resolver{
+				AllowedRoles: []string{"org-owner"},
+			},
+		},
+	}
+}
+
+func newLeaveClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, controlv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func leaveCtx(username string) context.Context {
+	return request.WithUser(context.Background(), &user.DefaultInfo{Name: username})
+}
+
+func leaveFixtures(admins []rbacv1.Subject) []client.Object {
+	return []client.Object{
+		fooNs.DeepCopy(),
+		&controlv1.OrganizationMembers{
+			ObjectMeta: metav1.ObjectMeta{Name: "members", Namespace: "foo"},
+			Spec: controlv1.OrganizationMembersSpec{
+				UserRefs: []controlv1.OrganizationMemberRef{{Name: "leaver"}, {Name: "admin"}},
+			},
+		},
+		&controlv1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "foo"},
+			Spec: controlv1.TeamSpec{
+				UserRefs: []controlv1.UserRef{{Name: "leaver"}, {Name: "admin"}},
+			},
+		},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "control-api:organization-admin", Namespace: "foo"},
+			Subjects:   admins,
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "control-api:organization-admin"},
+		},
+		&controlv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "leaver"},
+			Spec:       controlv1.UserSpec{Preferences: controlv1.UserPreferences{DefaultOrganizationRef: "foo"}},
+		},
+		&controlv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+			Spec:       controlv1.UserSpec{Preferences: controlv1.UserPreferences{DefaultOrganizationRef: "foo"}},
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"sync"
 
 	billingv1 "github.com/appuio/control-api/apis/billing/v1"
 	orgv1 "github.com/appuio/control-api/apis/organization/v1"
@@ -32,16 +33,10 @@ func New(clusterRoles *[]string, usernamePrefix *string, allowEmptyBillingEntity
 	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
 		masterConfig := loopback.GetLoopbackMasterClientConfig()
 
-		c, err := client.NewWithWatch(loopback.GetLoopbackMasterClientConfig(), client.Options{})
+		c, err := sharedClient()
 		if err != nil {
 			return nil, err
 		}
-		if err := controlv1.AddToScheme(c.Scheme()); err != nil {
-			return nil, err
-		}
-		if err := billingv1.AddToScheme(c.Scheme()); err != nil {
-			return nil, err
-		}
 
 		stor := &organizationStorage{
 			namepaces: &kubeNamespaceProvider{
@@ -68,6 +63,33 @@ func New(clusterRoles *[]string, usernamePrefix *string, allowEmptyBillingEntity
 	}
 }
 
+var (
+	sharedClientOnce sync.Once
+	sharedClientC    client.WithWatch
+	sharedClientErr  error
+)
+
+// sharedClient returns the loopback client shared by the organization storage and its subresources.
+func sharedClient() (client.WithWatch, error) {
+	sharedClientOnce.Do(func() {
+		c, err := client.NewWithWatch(loopback.GetLoopbackMasterClientConfig(), client.Options{})
+		if err != nil {
+			sharedClientErr = err
+			return
+		}
+		if err := controlv1.AddToScheme(c.Scheme()); err != nil {
+			sharedClientErr = err
+			return
+		}
+		if err := billingv1.AddToScheme(c.Scheme()); err != nil {
+			sharedClientErr = err
+			return
+		}
+		sharedClientC = c
+	})
+	return sharedClientC, sharedClientErr
+}
+
 type organizationStorage struct {
 	namepaces namespaceProvider
 
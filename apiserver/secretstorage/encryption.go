@@ -0,0 +1,194 @@
+package secretstorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// secretDataKeyKey is the key of the encrypted data encryption key in the backing secret.
+	secretDataKeyKey = "dataKey"
+	// secretKeyIDKey is the key of the ID of the key encryption key in the backing secret.
+	secretKeyIDKey = "keyID"
+
+	// dataKeySize is the size of the randomly generated AES-256 data encryption keys.
+	dataKeySize = 32
+
+	// KeysSecretDataKey is the key of the encryption keys in a Kubernetes Secret loaded by LoadKeysFromSecret.
+	KeysSecretDataKey = "keys"
+)
+
+// Key is a named AES key used as a key encryption key.
+type Key struct {
+	// ID identifies the key. It is stored alongside the encrypted object to find the key for decryption.
+	ID string
+	// Secret is the AES key. It must be 16, 24 or 32 bytes long.
+	Secret []byte
+}
+
+// Encryption encrypts serialized objects before they are stored in the backing secret.
+// Objects are envelope encrypted: every object is encrypted with a random data key, which in turn is encrypted with the primary key.
+// All keys can be used for decryption, allowing key rotation. Objects are re-encrypted with the primary key on every update.
+type Encryption struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewEncryption returns a new Encryption using the first key for encryption and all keys for decryption.
+func NewEncryption(keys ...Key) (*Encryption, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one encryption key is required")
+	}
+
+	e := &Encryption{
+		primary: keys[0].ID,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for _, k := range keys {
+		if k.ID == "" {
+			return nil, errors.New("encryption key ID must not be empty")
+		}
+		if _, ok := e.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key ID %q", k.ID)
+		}
+		aead, err := newAEAD(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", k.ID, err)
+		}
+		e.keys[k.ID] = aead
+	}
+	return e, nil
+}
+
+// encrypt encrypts the serialized object and returns the data of the backing secret.
+// The additional data is authenticated but not encrypted, it binds the ciphertext to the backing secret.
+func (e *Encryption) encrypt(plaintext, additionalData []byte) (map[string][]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataAEAD, plaintext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	encryptedDataKey, err := seal(e.keys[e.primary], dataKey, []byte(e.primary))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		secretObjectKey:  ciphertext,
+		secretDataKeyKey: encryptedDataKey,
+		secretKeyIDKey:   []byte(e.primary),
+	}, nil
+}
+
+// decrypt decrypts the serialized object from the data of the backing secret.
+func (e *Encryption) decrypt(data map[string][]byte, additionalData []byte) ([]byte, error) {
+	keyID := string(data[secretKeyIDKey])
+	kek, ok := e.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	dataKey, err := open(kek, data[secretDataKeyKey], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, data[secretObjectKey], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt object: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext and returns the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts data created by seal.
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+}
+
+// ParseKeys parses encryption keys in the format `<id>:<base64 encoded key>`, one key per line.
+// Empty lines and lines starting with `#` are ignored. The first key is used for encryption.
+func ParseKeys(r io.Reader) ([]Key, error) {
+	keys := []Key{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid encryption key line, expected <id>:<base64 encoded key>")
+		}
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %w", id, err)
+		}
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: secret})
+	}
+	return keys, s.Err()
+}
+
+// LoadKeysFromFile loads encryption keys from the given file. See ParseKeys for the format.
+func LoadKeysFromFile(path string) ([]Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encryption keys file: %w", err)
+	}
+	defer f.Close()
+	return ParseKeys(f)
+}
+
+// LoadKeysFromSecret loads encryption keys from the `keys` data key of the given Kubernetes Secret. See ParseKeys for the format.
+func LoadKeysFromSecret(ctx context.Context, c client.Reader, key client.ObjectKey) ([]Key, error) {
+	s := corev1.Secret{}
+	if err := c.Get(ctx, key, &s); err != nil {
+		return nil, fmt.Errorf("failed to get encryption keys secret: %w", err)
+	}
+	data, ok := s.Data[KeysSecretDataKey]
+	if !ok {
+		return nil, fmt.Errorf("encryption keys secret %s has no %q key", key, KeysSecretDataKey)
+	}
+	return ParseKeys(bytes.NewReader(data))
+}
@@ -0,0 +1,113 @@
+package secretstorage_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/appuio/control-api/apiserver/secretstorage"
+	"github.com/appuio/control-api/apiserver/testresource"
+)
+
+func TestEncryption(t *testing.T) {
+	c := buildClient(t)
+	k1 := secretstorage.Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)}
+	k2 := secretstorage.Key{ID: "k2", Secret: bytes.Repeat([]byte{2}, 32)}
+
+	plain, err := secretstorage.NewStorage(new(testresource.TestResource), c, "default")
+	require.NoError(t, err)
+	_, err = plain.Create(context.Background(), &testresource.TestResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Field1:     "very-secret",
+	}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	storageWithKeys := func(t *testing.T, keys ...secretstorage.Key) secretstorage.ScopedStandardStorage {
+		t.Helper()
+		enc, err := secretstorage.NewEncryption(keys...)
+		require.NoError(t, err)
+		s, err := secretstorage.NewEncryptedStorage(new(testresource.TestResource), c, "default", enc)
+		require.NoError(t, err)
+		return s
+	}
+	update := func(t *testing.T, s rest.Updater, field1 string) {
+		t.Helper()
+		_, _, err := s.Update(context.Background(), "test", rest.DefaultUpdatedObjectInfo(&testresource.TestResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Field1:     field1,
+		}), nil, nil, false, &metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	requireField1 := func(t *testing.T, s rest.Getter, expected string) {
+		t.Helper()
+		obj, err := s.Get(context.Background(), "test", &metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, expected, obj.(*testresource.TestResource).Field1)
+	}
+	backingSecret := func(t *testing.T) corev1.Secret {
+		t.Helper()
+		secret := corev1.Secret{}
+		require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "test", Namespace: "default"}, &secret))
+		return secret
+	}
+
+	// Unencrypted objects are readable and encrypted on update
+	s1 := storageWithKeys(t, k1)
+	requireField1(t, s1, "very-secret")
+	update(t, s1, "still-secret")
+	secret := backingSecret(t)
+	assert.Equal(t, "k1", string(secret.Data["keyID"]))
+	assert.NotContains(t, string(secret.Data["object"]), "still-secret")
+	requireField1(t, s1, "still-secret")
+
+	_, err = plain.Get(context.Background(), "test", &metav1.GetOptions{})
+	require.ErrorContains(t, err, "no encryption keys are configured")
+
+	// Rotate to k2, objects encrypted with k1 are still readable and re-encrypted on update
+	s2 := storageWithKeys(t, k2, k1)
+	requireField1(t, s2, "still-secret")
+	update(t, s2, "rotated")
+	assert.Equal(t, "k2", string(backingSecret(t).Data["keyID"]))
+
+	// k1 can be removed after all objects are re-encrypted
+	s3 := storageWithKeys(t, k2)
+	requireField1(t, s3, "rotated")
+	_, err = storageWithKeys(t, k1).Get(context.Background(), "test", &metav1.GetOptions{})
+	require.ErrorContains(t, err, "unknown encryption key")
+}
+
+func TestNewEncryption(t *testing.T) {
+	_, err := secretstorage.NewEncryption()
+	assert.Error(t, err, "no keys")
+	_, err = secretstorage.NewEncryption(secretstorage.Key{ID: "k1", Secret: []byte("short")})
+	assert.Error(t, err, "invalid key length")
+	_, err = secretstorage.NewEncryption(
+		secretstorage.Key{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)},
+		secretstorage.Key{ID: "k1", Secret: bytes.Repeat([]byte{2}, 32)},
+	)
+	assert.Error(t, err, "duplicate key ID")
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, err := secretstorage.ParseKeys(strings.NewReader(`
+# new key
+k2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=
+k1: AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []secretstorage.Key{
+		{ID: "k2", Secret: bytes.Repeat([]byte{2}, 32)},
+		{ID: "k1", Secret: bytes.Repeat([]byte{1}, 32)},
+	}, keys)
+
+	_, err = secretstorage.ParseKeys(strings.NewReader("k1"))
+	assert.Error(t, err)
+}
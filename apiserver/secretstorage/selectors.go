@@ -0,0 +1,158 @@
+package secretstorage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+
+	"github.com/appuio/control-api/apiserver/secretstorage/status"
+)
+
+// projectedLabelPrefix is the prefix of the labels projected from the stored object onto the backing secret.
+// The label key of the object is hashed to not introduce collisions with controllers tracking state through labels like argocd does.
+const projectedLabelPrefix = "secretstorage.appuio.io/"
+
+// namespaceLabel is the label on the backing secret holding the namespace of a namespace-scoped object.
+const namespaceLabel = projectedLabelPrefix + "namespace"
+
+// projectedLabelKey returns the key of the label on the backing secret for the given label key of the object.
+func projectedLabelKey(key string) string {
+	h := sha256.Sum224([]byte(key))
+	return projectedLabelPrefix + hex.EncodeToString(h[:])
+}
+
+// projectLabels returns the labels to set on the backing secret for the given labels of the object.
+func projectLabels(objLabels map[string]string) map[string]string {
+	if len(objLabels) == 0 {
+		return nil
+	}
+	projected := make(map[string]string, len(objLabels))
+	for k, v := range objLabels {
+		projected[projectedLabelKey(k)] = v
+	}
+	return projected
+}
+
+// projectLabelSelector translates a label selector for the stored objects to a label selector for the backing secrets.
+func projectLabelSelector(sel labels.Selector) (labels.Selector, error) {
+	if sel == nil || sel.Empty() {
+		return nil, nil
+	}
+	reqs, selectable := sel.Requirements()
+	if !selectable {
+		return labels.Nothing(), nil
+	}
+	projected := labels.NewSelector()
+	for _, r := range reqs {
+		pr, err := labels.NewRequirement(projectedLabelKey(r.Key()), r.Operator(), r.Values().List())
+		if err != nil {
+			return nil, fmt.Errorf("failed to project label selector: %w", err)
+		}
+		projected = projected.Add(*pr)
+	}
+	return projected, nil
+}
+
+// isProjectedLabel returns true if the given label key of the backing secret was projected from the stored object.
+func isProjectedLabel(key string) bool {
+	return strings.HasPrefix(key, projectedLabelPrefix)
+}
+
+// objectFields returns the fields of the object usable in field selectors.
+// metadata.name is always supported, metadata.namespace for namespace-scoped objects, status fields can be declared by implementing status.StatusWithSelectableFields.
+func objectFields(obj runtime.Object) (status.SelectableFields, error) {
+	ac, err := apimeta.Accessor(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access object metadata: %w", err)
+	}
+	set := status.SelectableFields{"metadata.name": {ac.GetName()}}
+	if ro, ok := obj.(resource.Object); ok && ro.NamespaceScoped() {
+		set["metadata.namespace"] = []string{ac.GetNamespace()}
+	}
+	if ws, ok := obj.(status.ObjectWithStatusSubResource); ok {
+		if sf, ok := ws.SecretStorageGetStatus().(status.StatusWithSelectableFields); ok {
+			for k, v := range sf.SecretStorageSelectableFields() {
+				set[k] = v
+			}
+		}
+	}
+	return set, nil
+}
+
+// FieldLabelConversionFunc returns a field label conversion func accepting the fields supported by the storage for the given object.
+// It must be registered for the object's kind, otherwise the API server rejects field selectors on other fields than metadata.name and metadata.namespace before they reach the storage.
+func FieldLabelConversionFunc(obj runtime.Object) runtime.FieldLabelConversionFunc {
+	return func(label, value string) (string, string, error) {
+		supported, err := objectFields(obj)
+		if err != nil {
+			return "", "", err
+		}
+		if _, ok := supported[label]; !ok {
+			return "", "", fmt.Errorf("field label not supported: %s", label)
+		}
+		return label, value, nil
+	}
+}
+
+// validateFieldSelector returns a BadRequest error if the selector references fields not supported by the stored object.
+func (s *secretStorage) validateFieldSelector(sel fields.Selector) error {
+	if sel == nil || sel.Empty() {
+		return nil
+	}
+	supported, err := objectFields(s.object.New())
+	if err != nil {
+		return err
+	}
+	for _, r := range sel.Requirements() {
+		if _, ok := supported[r.Field]; !ok {
+			return apierrors.NewBadRequest(fmt.Sprintf("field label not supported for %s: %s", s.object.GetGroupVersionResource().Resource, r.Field))
+		}
+	}
+	return nil
+}
+
+// matchesFieldSelector returns true if the object matches the given field selector.
+func matchesFieldSelector(obj runtime.Object, sel fields.Selector) (bool, error) {
+	if sel == nil || sel.Empty() {
+		return true, nil
+	}
+	set, err := objectFields(obj)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range sel.Requirements() {
+		values := set[r.Field]
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		has := false
+		for _, v := range values {
+			if v == r.Value {
+				has = true
+				break
+			}
+		}
+		switch r.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			if !has {
+				return false, nil
+			}
+		case selection.NotEquals:
+			if has {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("unsupported field selector operator: %s", r.Operator)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,14 @@
+package status
+
+// SelectableFields are the values of the fields usable in field selectors, keyed by their path, e.g. status.redeemedBy.
+// A field can have multiple values. An equality requirement matches if any of the values is equal, an inequality requirement if none is.
+// A field without values has the empty value.
+type SelectableFields map[string][]string
+
+// StatusWithSelectableFields can be implemented by a status sub-resource to declare fields usable in field selectors.
+type StatusWithSelectableFields interface {
+	StatusSubResource
+	// SecretStorageSelectableFields returns the selectable fields and their values.
+	// The returned fields must not depend on the state of the status, so the supported fields can be determined from an empty status.
+	SecretStorageSelectableFields() SelectableFields
+}
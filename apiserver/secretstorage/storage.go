@@ -1,11 +1,15 @@
 // Package secretstorage implements a storage backend for resources implementing apiserver-runtime's resource.Object interface.
 // The storage backend stores the object in a kubernetes secret.
 // The secret is named after the object and the object is stored in the secret's data field.
+// Secrets of namespace-scoped objects are named after a hash of the object's namespace and name and labeled with the object's namespace.
+// The object's labels are projected onto the secret with hashed keys so label selectors can be evaluated by the Kubernetes API.
+// Secrets written before labels were projected get their labels backfilled before the first selection by labels.
+// Field selectors are evaluated in memory and support metadata.name and the status fields declared by status.StatusWithSelectableFields.
+// The API server only accepts them if FieldLabelConversionFunc is registered for the object's kind.
 // Warning: Not all features of the storage backend are implemented.
 // Missing features:
-// - Field selectors
-// - Label selectors
 // - you tell me
+// The object can optionally be envelope encrypted before it is stored in the secret, see Encryption.
 // UID, CreationTimestamp and ResourceVersion are taken from the secret's metadata.
 // UID are namespaced UUIDs, generated from the object's UID and a fixed random UUID as the namespace.
 package secretstorage
@@ -13,18 +17,25 @@ package secretstorage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/google/uuid"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -53,6 +64,13 @@ type secretStorage struct {
 	codec runtime.Codec
 	// namespace is the namespace to store the secrets in.
 	namespace string
+	// encryption is used to encrypt the serialized object if set.
+	encryption *Encryption
+
+	// backfillMu guards labelsBackfilled.
+	backfillMu sync.Mutex
+	// labelsBackfilled is set once the labels of all backing secrets have been backfilled.
+	labelsBackfilled bool
 }
 
 type ScopedStandardStorage interface {
@@ -62,12 +80,19 @@ type ScopedStandardStorage interface {
 
 // NewStorage creates a new storage for the given object.
 func NewStorage(object resource.Object, cc client.WithWatch, backingNS string) (ScopedStandardStorage, error) {
-	// Supporting namespaced objects would need some way to create a unique hash out of the namespace and name.
-	// k8s.io/apiserver/pkg/endpoints/request.NamespaceFrom(ctx)
-	if object.NamespaceScoped() {
-		return nil, fmt.Errorf("namespace scoped objects are not yet supported")
+	return newStorage(object, cc, backingNS, nil)
+}
+
+// NewEncryptedStorage creates a new storage for the given object encrypting the stored objects.
+// Objects stored unencrypted are still readable and are encrypted on their next update.
+func NewEncryptedStorage(object resource.Object, cc client.WithWatch, backingNS string, encryption *Encryption) (ScopedStandardStorage, error) {
+	if encryption == nil {
+		return nil, errors.New("encryption must not be nil")
 	}
+	return newStorage(object, cc, backingNS, encryption)
+}
 
+func newStorage(object resource.Object, cc client.WithWatch, backingNS string, encryption *Encryption) (ScopedStandardStorage, error) {
 	scheme := cc.Scheme()
 
 	vs := scheme.PrioritizedVersionsForGroup(object.GetGroupVersionResource().Group)
@@ -86,10 +111,11 @@ func NewStorage(object resource.Object, cc client.WithWatch, backingNS string) (
 	}
 
 	return &secretStorage{
-		object:    object,
-		client:    cc,
-		codec:     codec,
-		namespace: backingNS,
+		object:     object,
+		client:     cc,
+		codec:      codec,
+		namespace:  backingNS,
+		encryption: encryption,
 	}, nil
 }
 
@@ -119,6 +145,11 @@ func (s *secretStorage) ConvertToTable(ctx context.Context, obj runtime.Object,
 }
 
 func (s *secretStorage) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	secretName, err := s.secretNameFromRequest(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+
 	obj, err := s.Get(ctx, name, &metav1.GetOptions{})
 	if err != nil {
 		return nil, false, err
@@ -132,7 +163,7 @@ func (s *secretStorage) Delete(ctx context.Context, name string, deleteValidatio
 
 	return obj, true, s.client.Delete(ctx, &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
+			Name:      secretName,
 			Namespace: s.getBackingNamespace(),
 		},
 	}, &client.DeleteOptions{
@@ -143,14 +174,53 @@ func (s *secretStorage) Delete(ctx context.Context, name string, deleteValidatio
 	})
 }
 
+// DeleteCollection deletes all objects matching the given list options and returns the list of deleted objects.
 func (s *secretStorage) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
-	return nil, fmt.Errorf("not implemented")
+	objList, err := s.List(ctx, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	l, err := apimeta.ExtractList(objList)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]runtime.Object, 0, len(l))
+	for _, obj := range l {
+		ac, err := apimeta.Accessor(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to access object metadata: %w", err)
+		}
+
+		dctx := ctx
+		if s.object.NamespaceScoped() {
+			dctx = request.WithNamespace(ctx, ac.GetNamespace())
+		}
+		d, _, err := s.Delete(dctx, ac.GetName(), deleteValidation, options)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		deleted = append(deleted, d)
+	}
+
+	deletedList := s.object.NewList()
+	if err := apimeta.SetList(deletedList, deleted); err != nil {
+		return nil, err
+	}
+	return deletedList, nil
 }
 
 func (s *secretStorage) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
-	rs := corev1.Secret{}
+	secretName, err := s.secretNameFromRequest(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := s.client.Get(ctx, client.ObjectKey{Name: name, Namespace: s.getBackingNamespace()}, &rs); err != nil {
+	rs := corev1.Secret{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: secretName, Namespace: s.getBackingNamespace()}, &rs); err != nil {
 		// Wrapping the not found error breaks kubectl apply (404)
 		return nil, err
 	}
@@ -159,15 +229,23 @@ func (s *secretStorage) Get(ctx context.Context, name string, options *metav1.Ge
 }
 
 func (s *secretStorage) List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error) {
+	if err := s.validateFieldSelector(options.FieldSelector); err != nil {
+		return nil, err
+	}
+	labelSelector, err := s.backingLabelSelector(ctx, options.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	if err := s.backfillLabels(ctx, labelSelector); err != nil {
+		return nil, err
+	}
+
 	rsl := &corev1.SecretList{}
 	if err := s.client.List(ctx, rsl, &client.ListOptions{
-		// TODO(swi): Should we add labels to the secret so we can filter on them?
-		// Those would need to be hashed to not introduce collisions with controllers tracking state through labels like argocd does.
-		// LabelSelector:         nil,
-		// FieldSelector:         nil,
-		Namespace: s.getBackingNamespace(),
-		Limit:     options.Limit,
-		Continue:  options.Continue,
+		LabelSelector: labelSelector,
+		Namespace:     s.getBackingNamespace(),
+		Limit:         options.Limit,
+		Continue:      options.Continue,
 	}); err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
@@ -183,26 +261,58 @@ func (s *secretStorage) List(ctx context.Context, options *metainternalversion.L
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode object from secret: %w", err)
 		}
+		if ok, err := matchesFieldSelector(obj, options.FieldSelector); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
 		l = append(l, obj)
 	}
 
 	if err := apimeta.SetList(objList, l); err != nil {
 		return nil, err
 	}
+	// Watches can resume from the resource version of the list.
+	la, err := apimeta.ListAccessor(objList)
+	if err != nil {
+		return nil, err
+	}
+	la.SetResourceVersion(rsl.ResourceVersion)
 
 	return objList, nil
 }
 
 func (s *secretStorage) Watch(ctx context.Context, options *metainternalversion.ListOptions) (watch.Interface, error) {
+	if err := s.validateFieldSelector(options.FieldSelector); err != nil {
+		return nil, err
+	}
+	labelSelector, err := s.backingLabelSelector(ctx, options.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	if err := s.backfillLabels(ctx, labelSelector); err != nil {
+		return nil, err
+	}
+
+	// Field selectors are evaluated on the decoded object.
+	// selected tracks the objects matching the field selector, so objects starting or stopping to match it are emitted as ADDED or DELETED events.
+	// Watches without a resource version start with an ADDED event for every existing secret, so every object is known before it is modified.
+	// Watches resuming from a resource version only get the changes since, the objects matching at that resource version are listed first.
+	selected := map[string]struct{}{}
+	if options.FieldSelector != nil && !options.FieldSelector.Empty() && options.ResourceVersion != "" && options.ResourceVersion != "0" {
+		selected, err = s.selectedAt(ctx, labelSelector, options.FieldSelector, options.ResourceVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	rsl := &corev1.SecretList{}
 	w, err := s.client.Watch(ctx, rsl, &client.ListOptions{
-		// TODO(swi): Should we add labels to the secret so we can filter on them?
-		// Those would need to be hashed to not introduce collisions with controllers tracking state through labels like argocd does.
-		// LabelSelector:         nil,
-		// FieldSelector:         nil,
-		Namespace: s.getBackingNamespace(),
-		Limit:     options.Limit,
-		Continue:  options.Continue,
+		LabelSelector: labelSelector,
+		Namespace:     s.getBackingNamespace(),
+		Limit:         options.Limit,
+		Continue:      options.Continue,
+		Raw:           &metav1.ListOptions{ResourceVersion: options.ResourceVersion},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %w", err)
@@ -229,10 +339,65 @@ func (s *secretStorage) Watch(ctx context.Context, options *metainternalversion.
 		}
 		in.Object = obj
 
-		return in, true
+		if options.FieldSelector == nil || options.FieldSelector.Empty() {
+			return in, true
+		}
+		matches, err := matchesFieldSelector(obj, options.FieldSelector)
+		if err != nil {
+			return watch.Event{
+				Type:   watch.Error,
+				Object: &metav1.Status{Message: fmt.Sprintf("failed to evaluate field selector: %v", err)},
+			}, true
+		}
+		_, wasSelected := selected[rs.Name]
+		switch {
+		case in.Type == watch.Deleted:
+			delete(selected, rs.Name)
+			return in, wasSelected || matches
+		case matches:
+			selected[rs.Name] = struct{}{}
+			if !wasSelected {
+				in.Type = watch.Added
+			}
+			return in, true
+		case wasSelected:
+			delete(selected, rs.Name)
+			in.Type = watch.Deleted
+			return in, true
+		}
+		return in, false
 	}), nil
 }
 
+// selectedAt returns the names of the secrets whose objects matched the field selector at the given resource version.
+func (s *secretStorage) selectedAt(ctx context.Context, labelSelector labels.Selector, fieldSelector fields.Selector, resourceVersion string) (map[string]struct{}, error) {
+	rsl := &corev1.SecretList{}
+	if err := s.client.List(ctx, rsl, &client.ListOptions{
+		LabelSelector: labelSelector,
+		Namespace:     s.getBackingNamespace(),
+		Raw:           &metav1.ListOptions{ResourceVersion: resourceVersion, ResourceVersionMatch: metav1.ResourceVersionMatchExact},
+	}); err != nil {
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	selected := make(map[string]struct{}, len(rsl.Items))
+	for _, rs := range rsl.Items {
+		obj, err := s.objectFromBackingSecret(&rs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object from secret: %w", err)
+		}
+		if ok, err := matchesFieldSelector(obj, fieldSelector); err != nil {
+			return nil, err
+		} else if ok {
+			selected[rs.Name] = struct{}{}
+		}
+	}
+	return selected, nil
+}
+
 func (s *secretStorage) Update(
 	ctx context.Context, name string,
 	objInfo rest.UpdatedObjectInfo,
@@ -241,9 +406,14 @@ func (s *secretStorage) Update(
 	forceAllowCreate bool,
 	options *metav1.UpdateOptions,
 ) (runtime.Object, bool, error) {
+	secretName, err := s.secretNameFromRequest(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+
 	var isCreate bool
 	rs := &corev1.Secret{}
-	err := s.client.Get(ctx, types.NamespacedName{Name: name, Namespace: s.getBackingNamespace()}, rs)
+	err = s.client.Get(ctx, types.NamespacedName{Name: secretName, Namespace: s.getBackingNamespace()}, rs)
 	if err != nil {
 		if !forceAllowCreate {
 			return nil, false, fmt.Errorf("failed to get old object: %w", err)
@@ -284,12 +454,29 @@ func (s *secretStorage) Update(
 		return nil, false, fmt.Errorf("failed to encode new object: %w", err)
 	}
 
-	p, err := objectPatch(newObjRaw.Bytes())
+	newAc, err := apimeta.Accessor(newObj)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to access object metadata: %w", err)
+	}
+	// Updates based on an outdated object are rejected, unconditional updates without resource version are allowed
+	if rv := newAc.GetResourceVersion(); rv != "" && rv != rs.ResourceVersion {
+		return nil, false, s.conflict(name)
+	}
+
+	data, err := s.secretData(rs.Name, newObjRaw.Bytes())
+	if err != nil {
+		return nil, false, err
+	}
+
+	p, err := objectPatch(data, rs.ResourceVersion, rs.Labels, s.secretLabels(newAc))
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to create patch: %w", err)
 	}
 
 	err = s.client.Patch(ctx, rs, p, &client.PatchOptions{DryRun: options.DryRun})
+	if apierrors.IsConflict(err) {
+		return nil, false, s.conflict(name)
+	}
 	if err != nil {
 		return newObj, false, fmt.Errorf("failed to update backing secret: %w", err)
 	}
@@ -302,6 +489,13 @@ func (s *secretStorage) create(ctx context.Context, obj runtime.Object, createVa
 	if err != nil {
 		return nil, fmt.Errorf("failed to access object metadata: %w", err)
 	}
+	if s.object.NamespaceScoped() && ac.GetNamespace() == "" {
+		ns, err := requestNamespace(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ac.SetNamespace(ns)
+	}
 
 	// Add empty status if the object supports it
 	// Status can only be modified through the status subresource and update calls
@@ -320,14 +514,18 @@ func (s *secretStorage) create(ctx context.Context, obj runtime.Object, createVa
 	if err := s.codec.Encode(obj, raw); err != nil {
 		return nil, fmt.Errorf("failed to encode object: %w", err)
 	}
+	secretName := s.secretName(ac.GetNamespace(), ac.GetName())
+	data, err := s.secretData(secretName, raw.Bytes())
+	if err != nil {
+		return nil, err
+	}
 	rs := corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      ac.GetName(),
+			Name:      secretName,
 			Namespace: s.getBackingNamespace(),
+			Labels:    s.secretLabels(ac),
 		},
-		Data: map[string][]byte{
-			secretObjectKey: raw.Bytes(),
-		},
+		Data: data,
 	}
 
 	if err := s.client.Create(ctx, &rs, &client.CreateOptions{
@@ -346,9 +544,152 @@ func (s *secretStorage) getBackingNamespace() string {
 	return "default"
 }
 
+// secretName returns the name of the backing secret for the object with the given namespace and name.
+// Namespace-scoped objects are stored under a hash of the namespace and name to avoid collisions between namespaces.
+// The separator can't be part of a namespace or name, so the hashed value is unique.
+func (s *secretStorage) secretName(namespace, name string) string {
+	if !s.object.NamespaceScoped() {
+		return name
+	}
+	h := sha256.Sum224([]byte(namespace + "/" + name))
+	return "ns-" + hex.EncodeToString(h[:])
+}
+
+// secretNameFromRequest returns the name of the backing secret for the object with the given name in the namespace of the request.
+func (s *secretStorage) secretNameFromRequest(ctx context.Context, name string) (string, error) {
+	if !s.object.NamespaceScoped() {
+		return name, nil
+	}
+	ns, err := requestNamespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	return s.secretName(ns, name), nil
+}
+
+// secretLabels returns the labels of the backing secret for the given object.
+func (s *secretStorage) secretLabels(obj metav1.Object) map[string]string {
+	l := projectLabels(obj.GetLabels())
+	if s.object.NamespaceScoped() {
+		if l == nil {
+			l = map[string]string{}
+		}
+		l[namespaceLabel] = obj.GetNamespace()
+	}
+	return l
+}
+
+// backingLabelSelector returns the label selector for the backing secrets.
+// It translates the given label selector and restricts namespace-scoped objects to the namespace of the request, if any.
+func (s *secretStorage) backingLabelSelector(ctx context.Context, sel labels.Selector) (labels.Selector, error) {
+	projected, err := projectLabelSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	ns := request.NamespaceValue(ctx)
+	if !s.object.NamespaceScoped() || ns == "" {
+		return projected, nil
+	}
+	if projected == nil {
+		projected = labels.NewSelector()
+	}
+	r, err := labels.NewRequirement(namespaceLabel, selection.Equals, []string{ns})
+	if err != nil {
+		return nil, err
+	}
+	return projected.Add(*r), nil
+}
+
+// backfillLabels sets the labels of backing secrets written before labels were projected onto them.
+// It runs once per storage before the first selection by labels, so label selectors also match objects not updated since.
+func (s *secretStorage) backfillLabels(ctx context.Context, labelSelector labels.Selector) error {
+	if labelSelector == nil || labelSelector.Empty() {
+		return nil
+	}
+	s.backfillMu.Lock()
+	defer s.backfillMu.Unlock()
+	if s.labelsBackfilled {
+		return nil
+	}
+
+	rsl := &corev1.SecretList{}
+	if err := s.client.List(ctx, rsl, client.InNamespace(s.getBackingNamespace())); err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for i := range rsl.Items {
+		rs := &rsl.Items[i]
+		obj, err := s.objectFromBackingSecret(rs)
+		if err != nil {
+			// Not a backing secret of this storage
+			continue
+		}
+		ac, err := apimeta.Accessor(obj)
+		if err != nil {
+			return fmt.Errorf("failed to access object metadata: %w", err)
+		}
+		missing := map[string]string{}
+		for k, v := range s.secretLabels(ac) {
+			if cur, ok := rs.Labels[k]; !ok || cur != v {
+				missing[k] = v
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		p, err := json.Marshal(map[string]any{"metadata": map[string]any{"labels": missing}})
+		if err != nil {
+			return fmt.Errorf("failed to create patch: %w", err)
+		}
+		if err := s.client.Patch(ctx, rs, client.RawPatch(types.MergePatchType, p)); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to backfill labels of secret %q: %w", rs.Name, err)
+		}
+	}
+	s.labelsBackfilled = true
+	return nil
+}
+
+// requestNamespace returns the namespace of the request or a BadRequest error if there is none.
+func requestNamespace(ctx context.Context) (string, error) {
+	ns := request.NamespaceValue(ctx)
+	if ns == "" {
+		return "", apierrors.NewBadRequest("namespace is required for namespace-scoped resources")
+	}
+	return ns, nil
+}
+
+// secretData returns the data of the backing secret for the serialized object, encrypting it if encryption is configured.
+func (s *secretStorage) secretData(secretName string, serialized []byte) (map[string][]byte, error) {
+	if s.encryption == nil {
+		return map[string][]byte{
+			secretObjectKey: serialized,
+		}, nil
+	}
+	data, err := s.encryption.encrypt(serialized, []byte(secretName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt object: %w", err)
+	}
+	return data, nil
+}
+
+// serializedObject returns the serialized object from the backing secret, decrypting it if it is encrypted.
+func (s *secretStorage) serializedObject(rs *corev1.Secret) ([]byte, error) {
+	if _, encrypted := rs.Data[secretKeyIDKey]; !encrypted {
+		return rs.Data[secretObjectKey], nil
+	}
+	if s.encryption == nil {
+		return nil, errors.New("object is encrypted but no encryption keys are configured")
+	}
+	return s.encryption.decrypt(rs.Data, []byte(rs.Name))
+}
+
 func (s *secretStorage) objectFromBackingSecret(rs *corev1.Secret) (runtime.Object, error) {
+	serialized, err := s.serializedObject(rs)
+	if err != nil {
+		return nil, err
+	}
+
 	obj := s.object.New()
-	if _, _, err := s.codec.Decode(rs.Data[secretObjectKey], nil, obj); err != nil {
+	if _, _, err := s.codec.Decode(serialized, nil, obj); err != nil {
 		return nil, fmt.Errorf("failed to decode object: %w", err)
 	}
 
@@ -373,11 +714,40 @@ func (s *secretStorage) objectFromBackingSecret(rs *corev1.Secret) (runtime.Obje
 	return obj, nil
 }
 
-func objectPatch(serialized []byte) (client.Patch, error) {
+// conflict returns the error returned for updates of objects modified since they were read.
+func (s *secretStorage) conflict(name string) error {
+	return apierrors.NewConflict(s.object.GetGroupVersionResource().GroupResource(), name,
+		errors.New("the object has been modified; please apply your changes to the latest version and try again"))
+}
+
+// objectPatch returns a patch setting the data and the projected labels on the backing secret.
+// The patch fails with a conflict if the secret changed since the given resource version was read.
+// Encryption data and projected labels present on the secret but not in the given data and labels are removed.
+func objectPatch(data map[string][]byte, resourceVersion string, secretLabels, projectedLabels map[string]string) (client.Patch, error) {
+	labels := map[string]any{}
+	for k := range secretLabels {
+		if isProjectedLabel(k) {
+			labels[k] = nil
+		}
+	}
+	for k, v := range projectedLabels {
+		labels[k] = v
+	}
+
+	encodedData := map[string]any{
+		secretDataKeyKey: nil,
+		secretKeyIDKey:   nil,
+	}
+	for k, v := range data {
+		encodedData[k] = base64.StdEncoding.EncodeToString(v)
+	}
+
 	jp, err := json.Marshal(map[string]any{
-		"data": map[string]any{
-			secretObjectKey: base64.StdEncoding.EncodeToString(serialized),
+		"metadata": map[string]any{
+			"labels":          labels,
+			"resourceVersion": resourceVersion,
 		},
+		"data": encodedData,
 	})
 	return client.RawPatch(types.StrategicMergePatchType, jp), err
 }
@@ -400,6 +770,16 @@ func filterStatusUpdates(ctx context.Context, newObj, oldObj runtime.Object) (tr
 	if requestInfo.Subresource == "status" {
 		withUpdatedStatus := oldWithStatus.DeepCopyObject().(status.ObjectWithStatusSubResource)
 		newWithStatus.SecretStorageGetStatus().SecretStorageCopyTo(withUpdatedStatus)
+		// Keep the resource version of the update, status updates based on an outdated object must be rejected as well
+		newAc, err := apimeta.Accessor(newWithStatus)
+		if err != nil {
+			return nil, err
+		}
+		updatedAc, err := apimeta.Accessor(withUpdatedStatus)
+		if err != nil {
+			return nil, err
+		}
+		updatedAc.SetResourceVersion(newAc.GetResourceVersion())
 		return withUpdatedStatus, nil
 	}
 	// Status must be updated through the status subresource
@@ -7,8 +7,11 @@ import (
 
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -114,6 +117,292 @@ func TestStatusSubresource(t *testing.T) {
 	testStatusValue(t, s, 42)
 }
 
+func TestUpdateConflict(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResourceWithStatus), c, "default")
+	require.NoError(t, err)
+
+	_, err = s.Create(context.Background(), &testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	read, err := s.Get(context.Background(), "test", &metav1.GetOptions{})
+	require.NoError(t, err)
+	stale := read.(*testresource.TestResourceWithStatus)
+	require.NotEmpty(t, stale.ResourceVersion)
+
+	statusCtx := request.WithRequestInfo(request.NewContext(), &request.RequestInfo{Subresource: "status"})
+	updated := stale.DeepCopy()
+	updated.Status.Num = 1
+	_, _, err = s.Update(statusCtx, "test", rest.DefaultUpdatedObjectInfo(updated), nil, nil, false, &metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Updates based on the outdated object must not overwrite the previous update
+	stale.Status.Num = 2
+	_, _, err = s.Update(statusCtx, "test", rest.DefaultUpdatedObjectInfo(stale), nil, nil, false, &metav1.UpdateOptions{})
+	require.True(t, apierrors.IsConflict(err), "expected conflict, got %v", err)
+	stale.Field1 = "stale"
+	_, _, err = s.Update(request.WithRequestInfo(request.NewContext(), &request.RequestInfo{}), "test", rest.DefaultUpdatedObjectInfo(stale), nil, nil, false, &metav1.UpdateOptions{})
+	require.True(t, apierrors.IsConflict(err), "expected conflict, got %v", err)
+	testStatusValue(t, s, 1)
+
+	// Updates without resource version are unconditional
+	stale.ResourceVersion = ""
+	_, _, err = s.Update(statusCtx, "test", rest.DefaultUpdatedObjectInfo(stale), nil, nil, false, &metav1.UpdateOptions{})
+	require.NoError(t, err)
+	testStatusValue(t, s, 2)
+}
+
+func TestSelectors(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResourceWithStatus), c, "default")
+	require.NoError(t, err)
+
+	for _, ttr := range []*testresource.TestResourceWithStatus{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"team": "x"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"team": "y"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+	} {
+		_, err := s.Create(context.Background(), ttr, nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "a", Namespace: "default"}, secret))
+	require.NotContains(t, secret.Labels, "team", "labels must not be copied verbatim to the backing secret")
+
+	listNames := func(t *testing.T, opts *metainternalversion.ListOptions) []string {
+		t.Helper()
+		list, err := s.List(context.Background(), opts)
+		require.NoError(t, err)
+		names := []string{}
+		for _, itm := range list.(*testresource.TestResourceWithStatusList).Items {
+			names = append(names, itm.Name)
+		}
+		return names
+	}
+
+	require.ElementsMatch(t, []string{"a"}, listNames(t, &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "x"})}))
+	sel, err := labels.Parse("team")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, listNames(t, &metainternalversion.ListOptions{LabelSelector: sel}))
+	require.ElementsMatch(t, []string{"b"}, listNames(t, &metainternalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", "b")}))
+
+	// Label changes are projected onto the backing secret
+	_, _, err = s.Update(
+		request.WithRequestInfo(request.NewContext(), &request.RequestInfo{}),
+		"a", rest.DefaultUpdatedObjectInfo(&testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"team": "y"}}}),
+		nil, nil, false, &metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{}, listNames(t, &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "x"})}))
+	require.ElementsMatch(t, []string{"a", "b"}, listNames(t, &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "y"})}))
+
+	// Declared status fields can be selected
+	_, _, err = s.Update(
+		request.WithRequestInfo(request.NewContext(), &request.RequestInfo{Subresource: "status"}),
+		"c", rest.DefaultUpdatedObjectInfo(&testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "c"}, Status: testresource.TestResourceWithStatusStatus{Num: 3}}),
+		nil, nil, false, &metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"c"}, listNames(t, &metainternalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("status.num", "3")}))
+
+	_, err = s.List(context.Background(), &metainternalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("field1", "x")})
+	require.True(t, apierrors.IsBadRequest(err), "expected bad request for unsupported field, got %v", err)
+}
+
+func TestWatchFieldSelector(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResourceWithStatus), c, "default")
+	require.NoError(t, err)
+
+	w, err := s.Watch(context.Background(), &metainternalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("status.num", "3")})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	_, err = s.Create(context.Background(), &testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	setNum := func(num int) {
+		_, _, err := s.Update(
+			request.WithRequestInfo(request.NewContext(), &request.RequestInfo{Subresource: "status"}),
+			"test", rest.DefaultUpdatedObjectInfo(&testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Status: testresource.TestResourceWithStatusStatus{Num: num}}),
+			nil, nil, false, &metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	expectEvent := func(typ watch.EventType) {
+		t.Helper()
+		select {
+		case ev := <-w.ResultChan():
+			require.Equal(t, typ, ev.Type)
+			require.Equal(t, "test", ev.Object.(*testresource.TestResourceWithStatus).Name)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s event", typ)
+		}
+	}
+
+	setNum(3)
+	expectEvent(watch.Added)
+	setNum(3)
+	expectEvent(watch.Modified)
+	setNum(4)
+	expectEvent(watch.Deleted)
+	_, _, err = s.Delete(context.Background(), "test", nil, &metav1.DeleteOptions{})
+	require.NoError(t, err)
+
+	select {
+	case ev := <-w.ResultChan():
+		t.Fatalf("unexpected %s event for object not matching the field selector", ev.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchFieldSelector_ResourceVersion(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResourceWithStatus), c, "default")
+	require.NoError(t, err)
+
+	_, err = s.Create(context.Background(), &testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	setNum := func(num int) {
+		_, _, err := s.Update(
+			request.WithRequestInfo(request.NewContext(), &request.RequestInfo{Subresource: "status"}),
+			"test", rest.DefaultUpdatedObjectInfo(&testresource.TestResourceWithStatus{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Status: testresource.TestResourceWithStatusStatus{Num: num}}),
+			nil, nil, false, &metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	setNum(3)
+
+	selector := fields.OneTermEqualSelector("status.num", "3")
+	list, err := s.List(context.Background(), &metainternalversion.ListOptions{FieldSelector: selector})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.TestResourceWithStatusList).Items, 1)
+	// The fake client doesn't set the resource version of lists
+	rv := list.(*testresource.TestResourceWithStatusList).Items[0].ResourceVersion
+
+	w, err := s.Watch(context.Background(), &metainternalversion.ListOptions{FieldSelector: selector, ResourceVersion: rv})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	setNum(4)
+	select {
+	case ev := <-w.ResultChan():
+		require.Equal(t, watch.Deleted, ev.Type, "object listed before the watch must be deleted once it stops matching")
+		require.Equal(t, "test", ev.Object.(*testresource.TestResourceWithStatus).Name)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for DELETED event")
+	}
+}
+
+func TestBackfillLabels(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResource), c, "default")
+	require.NoError(t, err)
+
+	_, err = s.Create(context.Background(), &testresource.TestResource{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"team": "x"}}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	// Simulate a secret written before labels were projected
+	secret := &corev1.Secret{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "a", Namespace: "default"}, secret))
+	secret.Labels = nil
+	require.NoError(t, c.Update(context.Background(), secret))
+
+	s, err = secretstorage.NewStorage(new(testresource.TestResource), c, "default")
+	require.NoError(t, err)
+	list, err := s.List(context.Background(), &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"team": "x"})})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.TestResourceList).Items, 1)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: "a", Namespace: "default"}, secret))
+	require.NotEmpty(t, secret.Labels, "labels must be backfilled")
+}
+
+func TestFieldLabelConversionFunc(t *testing.T) {
+	conv := secretstorage.FieldLabelConversionFunc(new(testresource.TestResourceWithStatus))
+
+	label, value, err := conv("status.num", "3")
+	require.NoError(t, err)
+	require.Equal(t, "status.num", label)
+	require.Equal(t, "3", value)
+	_, _, err = conv("metadata.name", "test")
+	require.NoError(t, err)
+	_, _, err = conv("field1", "x")
+	require.Error(t, err)
+}
+
+func TestNamespaced(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.NamespacedTestResource), c, "default")
+	require.NoError(t, err)
+	require.True(t, s.NamespaceScoped())
+
+	nsCtx := func(ns string) context.Context {
+		return request.WithNamespace(request.NewContext(), ns)
+	}
+
+	for _, ns := range []string{"ns-a", "ns-b"} {
+		_, err := s.Create(nsCtx(ns), &testresource.NamespacedTestResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: ns},
+			Field1:     ns,
+		}, nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	_, err = s.Create(context.Background(), &testresource.NamespacedTestResource{ObjectMeta: metav1.ObjectMeta{Name: "test"}}, nil, &metav1.CreateOptions{})
+	require.True(t, apierrors.IsBadRequest(err), "expected bad request without namespace, got %v", err)
+
+	obj, err := s.Get(nsCtx("ns-b"), "test", &metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "ns-b", obj.(*testresource.NamespacedTestResource).Field1)
+	require.Equal(t, "ns-b", obj.(*testresource.NamespacedTestResource).Namespace)
+
+	list, err := s.List(nsCtx("ns-a"), &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.NamespacedTestResourceList).Items, 1)
+	require.Equal(t, "ns-a", list.(*testresource.NamespacedTestResourceList).Items[0].Namespace)
+
+	list, err = s.List(context.Background(), &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.NamespacedTestResourceList).Items, 2)
+
+	list, err = s.List(context.Background(), &metainternalversion.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.namespace", "ns-b")})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.NamespacedTestResourceList).Items, 1)
+
+	_, _, err = s.Delete(nsCtx("ns-a"), "test", nil, &metav1.DeleteOptions{})
+	require.NoError(t, err)
+	_, err = s.Get(nsCtx("ns-a"), "test", &metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+	_, err = s.Get(nsCtx("ns-b"), "test", &metav1.GetOptions{})
+	require.NoError(t, err)
+}
+
+func TestDeleteCollection(t *testing.T) {
+	c := buildClient(t)
+	s, err := secretstorage.NewStorage(new(testresource.TestResource), c, "default")
+	require.NoError(t, err)
+
+	for _, ttr := range []*testresource.TestResource{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"cleanup": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"cleanup": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+	} {
+		_, err := s.Create(context.Background(), ttr, nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	selector := &metainternalversion.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{"cleanup": "true"})}
+
+	deleted, err := s.DeleteCollection(context.Background(), nil, &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}, selector)
+	require.NoError(t, err)
+	require.Len(t, deleted.(*testresource.TestResourceList).Items, 2)
+	list, err := s.List(context.Background(), &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.TestResourceList).Items, 3, "dry run must not delete objects")
+
+	deleted, err = s.DeleteCollection(context.Background(), nil, &metav1.DeleteOptions{}, selector)
+	require.NoError(t, err)
+	require.Len(t, deleted.(*testresource.TestResourceList).Items, 2)
+	list, err = s.List(context.Background(), &metainternalversion.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.(*testresource.TestResourceList).Items, 1)
+	require.Equal(t, "c", list.(*testresource.TestResourceList).Items[0].Name)
+}
+
 func testStatusValue(t *testing.T, s rest.Getter, expected int) {
 	t.Helper()
 
@@ -0,0 +1,79 @@
+package testresource
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// +kubebuilder:object:root=true
+
+// NamespacedTestResource implements resource.Object for a namespace-scoped resource
+type NamespacedTestResource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Field1 string `json:"field1"`
+}
+
+// NamespacedTestResource needs to implement the builder resource interface
+var _ resource.Object = &NamespacedTestResource{}
+
+// GetObjectMeta returns the objects meta reference.
+func (o *NamespacedTestResource) GetObjectMeta() *metav1.ObjectMeta {
+	return &o.ObjectMeta
+}
+
+// GetGroupVersionResource returns the GroupVersionResource for this resource.
+// The resource should be the all lowercase and pluralized kind
+func (o *NamespacedTestResource) GetGroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    GroupVersion.Group,
+		Version:  GroupVersion.Version,
+		Resource: "namespacedtestresources",
+	}
+}
+
+// IsStorageVersion returns true if the object is also the internal version -- i.e. is the type defined for the API group or an alias to this object.
+// If false, the resource is expected to implement MultiVersionObject interface.
+func (o *NamespacedTestResource) IsStorageVersion() bool {
+	return true
+}
+
+// NamespaceScoped returns true if the object is namespaced
+func (o *NamespacedTestResource) NamespaceScoped() bool {
+	return true
+}
+
+// New returns a new instance of the resource
+func (o *NamespacedTestResource) New() runtime.Object {
+	return &NamespacedTestResource{}
+}
+
+// NewList return a new list instance of the resource
+func (o *NamespacedTestResource) NewList() runtime.Object {
+	return &NamespacedTestResourceList{}
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedTestResourceList contains a list of NamespacedTestResources
+type NamespacedTestResourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NamespacedTestResource `json:"items"`
+}
+
+// NamespacedTestResourceList needs to implement the builder resource interface
+var _ resource.ObjectList = &NamespacedTestResourceList{}
+
+// GetListMeta returns the list meta reference.
+func (in *NamespacedTestResourceList) GetListMeta() *metav1.ListMeta {
+	return &in.ListMeta
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespacedTestResource{}, &NamespacedTestResourceList{})
+}
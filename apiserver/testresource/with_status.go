@@ -4,6 +4,8 @@
 package testresource
 
 import (
+	"strconv"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -80,6 +82,13 @@ func (s TestResourceWithStatusStatus) SubResourceName() string {
 	return "status"
 }
 
+// SecretStorageSelectableFields returns the status fields usable in field selectors
+func (s *TestResourceWithStatusStatus) SecretStorageSelectableFields() status.SelectableFields {
+	return status.SelectableFields{
+		"status.num": {strconv.Itoa(s.Num)},
+	}
+}
+
 // +kubebuilder:object:root=true
 // TestResourceWithStatusList contains a list of TestResourceWithStatuss
 type TestResourceWithStatusList struct {
@@ -0,0 +1,172 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/controllers/targetref"
+)
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+//+kubebuilder:rbac:groups="appuio.io",resources=teams,verbs=get
+//+kubebuilder:rbac:groups="billing.appuio.io",resources=billingentities,verbs=get
+
+var _ rest.Creater = &invitationPreviewer{}
+var _ rest.Storage = &invitationPreviewer{}
+var _ rest.Scoper = &invitationPreviewer{}
+
+// invitationPreviewer shows invitees what they are invited into before they redeem the invitation.
+// Requests are verified like redeem requests and count towards the same failed attempt limits.
+type invitationPreviewer struct {
+	redeemer *invitationRedeemer
+}
+
+func (ip invitationPreviewer) NamespaceScoped() bool {
+	return false
+}
+
+func (ip invitationPreviewer) New() runtime.Object {
+	return &userv1.InvitationPreview{}
+}
+
+func (ip invitationPreviewer) Destroy() {}
+
+// Create implements previewing invitations, it accepts `InvitationPreview`.
+// The user is identified by the username in the request context.
+// If the user could redeem the invitation with the token, the inviter, note, expiry, and the display names of the organizations, teams, and billing entities the invitation targets are returned in the status.
+// The invitation is not redeemed, other fields of the invitation are not exposed.
+// Invalid requests are rejected like redeem requests.
+func (s *invitationPreviewer) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	ip, ok := obj.(*userv1.InvitationPreview)
+	if !ok {
+		return nil, fmt.Errorf("not an InvitationPreview: %#v", obj)
+	}
+
+	l := klog.FromContext(ctx).WithName("InvitationPreviewer.Create").WithValues("invitation", ip.Name)
+
+	user, ok := userFrom(ctx, s.redeemer.usernamePrefix)
+	if !ok {
+		l.Info("no allowed user found in request context", "usernamePrefix", s.redeemer.usernamePrefix)
+		return nil, s.redeemer.rejected(failureReasonInvalidUser)
+	}
+
+	inv, err := s.redeemer.verify(ctx, l, ip.Name, ip.Token, user)
+	if err != nil {
+		return nil, err
+	}
+
+	inviter, err := s.inviter(ctx, inv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inviter: %w", err)
+	}
+	targets, err := s.previewTargets(ctx, inv.Spec.TargetRefs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get targets: %w", err)
+	}
+
+	ip.Token = ""
+	ip.Status = userv1.InvitationPreviewStatus{
+		Inviter:    inviter,
+		Note:       inv.Spec.Note,
+		ValidUntil: inv.Status.ValidUntil,
+		Targets:    targets,
+	}
+	return ip, nil
+}
+
+// inviter returns the display name of the user owning the invitation, falling back to the username.
+// The owner is the subject of the ClusterRoleBinding created for the creator of the invitation.
+func (s *invitationPreviewer) inviter(ctx context.Context, inv *userv1.Invitation) (string, error) {
+	owner := rbacv1.ClusterRoleBinding{}
+	if err := s.redeemer.client.Get(ctx, client.ObjectKey{Name: roleName(inv.Name)}, &owner); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	for _, subject := range owner.Subjects {
+		if subject.Kind != rbacv1.UserKind || !strings.HasPrefix(subject.Name, s.redeemer.usernamePrefix) {
+			continue
+		}
+		username := strings.TrimPrefix(subject.Name, s.redeemer.usernamePrefix)
+		u := controlv1.User{}
+		err := s.redeemer.client.Get(ctx, client.ObjectKey{Name: username}, &u)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", err
+		}
+		if u.Status.DisplayName != "" {
+			return u.Status.DisplayName, nil
+		}
+		return username, nil
+	}
+	return "", nil
+}
+
+// previewTargets returns the organizations, teams, and billing entities of the targets.
+// Other targets, such as the RoleBindings granting the membership, are not shown.
+func (s *invitationPreviewer) previewTargets(ctx context.Context, targets []userv1.TargetRef) ([]userv1.InvitationPreviewTarget, error) {
+	previews := make([]userv1.InvitationPreviewTarget, 0, len(targets))
+	for _, target := range targets {
+		var preview userv1.InvitationPreviewTarget
+		var err error
+		switch {
+		case target.APIGroup == "appuio.io" && target.Kind == "OrganizationMembers":
+			preview, err = s.previewOrganization(ctx, target)
+		case target.APIGroup == "appuio.io" && target.Kind == "Team":
+			preview, err = s.previewTeam(ctx, target)
+		case targetref.IsBillingEntity(target):
+			preview, err = s.previewBillingEntity(ctx, target)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		preview.Role = target.Role
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
+
+func (s *invitationPreviewer) previewOrganization(ctx context.Context, target userv1.TargetRef) (userv1.InvitationPreviewTarget, error) {
+	preview := userv1.InvitationPreviewTarget{Kind: "Organization", Name: target.Namespace}
+	ns := corev1.Namespace{}
+	if err := s.redeemer.client.Get(ctx, client.ObjectKey{Name: target.Namespace}, &ns); err != nil {
+		return preview, client.IgnoreNotFound(err)
+	}
+	if org := orgv1.NewOrganizationFromNS(&ns); org != nil {
+		preview.DisplayName = org.Spec.DisplayName
+	}
+	return preview, nil
+}
+
+func (s *invitationPreviewer) previewTeam(ctx context.Context, target userv1.TargetRef) (userv1.InvitationPreviewTarget, error) {
+	preview := userv1.InvitationPreviewTarget{Kind: "Team", Name: target.Name, Organization: target.Namespace}
+	team := controlv1.Team{}
+	if err := s.redeemer.client.Get(ctx, client.ObjectKey{Name: target.Name, Namespace: target.Namespace}, &team); err != nil {
+		return preview, client.IgnoreNotFound(err)
+	}
+	preview.DisplayName = team.Spec.DisplayName
+	return preview, nil
+}
+
+func (s *invitationPreviewer) previewBillingEntity(ctx context.Context, target userv1.TargetRef) (userv1.InvitationPreviewTarget, error) {
+	preview := userv1.InvitationPreviewTarget{Kind: "BillingEntity", Name: target.Name}
+	be := billingv1.BillingEntity{}
+	if err := s.redeemer.client.Get(ctx, client.ObjectKey{Name: target.Name}, &be); err != nil {
+		return preview, client.IgnoreNotFound(err)
+	}
+	preview.DisplayName = be.Spec.Name
+	return preview, nil
+}
@@ -0,0 +1,156 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+func TestCreate_Preview_Success(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Spec.Note = "Welcome to the team"
+	inv.Spec.Email = "invitee@example.com"
+	inv.Spec.TargetRefs = []userv1.TargetRef{
+		{APIGroup: "appuio.io", Kind: "OrganizationMembers", Name: "members", Namespace: "acme", Role: "viewer"},
+		{APIGroup: rbacv1.GroupName, Kind: "RoleBinding", Name: "control-api:organization-viewer", Namespace: "acme"},
+		{APIGroup: "appuio.io", Kind: "Team", Name: "dev", Namespace: "acme"},
+		{APIGroup: "billing.appuio.io", Kind: "BillingEntity", Name: "be-1234", Role: "admin"},
+		{APIGroup: "appuio.io", Kind: "Team", Name: "deleted", Namespace: "acme"},
+	}
+
+	c := prepareTest(t, inv,
+		&rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: roleName(inv.Name)},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#inviter"}},
+		},
+		&controlv1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "inviter"},
+			Status:     controlv1.UserStatus{DisplayName: "Ines Inviter"},
+		},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "acme",
+				Labels:      map[string]string{orgv1.TypeKey: orgv1.OrgType},
+				Annotations: map[string]string{orgv1.DisplayNameKey: "Acme Corp."},
+			},
+		},
+		&controlv1.Team{
+			ObjectMeta: metav1.ObjectMeta{Name: "dev", Namespace: "acme"},
+			Spec:       controlv1.TeamSpec{DisplayName: "Developers"},
+		},
+		&billingv1.BillingEntity{
+			ObjectMeta: metav1.ObjectMeta{Name: "be-1234"},
+			Spec:       billingv1.BillingEntitySpec{Name: "Acme Billing"},
+		},
+	)
+	subject := invitationPreviewer{redeemer: &invitationRedeemer{client: c, usernamePrefix: "appuio#"}}
+
+	preview, err := executePreview(t, subject, "appuio#invitee", inv.Status.Token)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.False(t, inv.IsRedeemed(), "previewing must not redeem the invitation")
+
+	assert.Empty(t, preview.Token, "token should not be returned")
+	assert.Equal(t, userv1.InvitationPreviewStatus{
+		Inviter:    "Ines Inviter",
+		Note:       "Welcome to the team",
+		ValidUntil: inv.Status.ValidUntil,
+		Targets: []userv1.InvitationPreviewTarget{
+			{Kind: "Organization", Name: "acme", DisplayName: "Acme Corp.", Role: "viewer"},
+			{Kind: "Team", Name: "dev", Organization: "acme", DisplayName: "Developers"},
+			{Kind: "BillingEntity", Name: "be-1234", DisplayName: "Acme Billing", Role: "admin"},
+			{Kind: "Team", Name: "deleted", Organization: "acme"},
+		},
+	}, preview.Status)
+}
+
+func TestCreate_Preview_InviterWithoutUser(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: roleName(inv.Name)},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#inviter"}},
+	})
+	subject := invitationPreviewer{redeemer: &invitationRedeemer{client: c, usernamePrefix: "appuio#"}}
+
+	preview, err := executePreview(t, subject, "appuio#invitee", inv.Status.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "inviter", preview.Status.Inviter)
+}
+
+func TestCreate_Preview_Rejected(t *testing.T) {
+	expired := redeemableInvitation()
+	expired.Status.ValidUntil = metav1.NewTime(metav1.Now().Add(-1))
+	redeemed := redeemableInvitation()
+	redeemed.Status.Conditions = []metav1.Condition{{Type: userv1.ConditionRedeemed, Status: metav1.ConditionTrue}}
+
+	tests := map[string]struct {
+		inv      *userv1.Invitation
+		username string
+		token    string
+	}{
+		"invalid token":    {inv: redeemableInvitation(), username: "appuio#invitee", token: "wrong"},
+		"invalid user":     {inv: redeemableInvitation(), username: "invitee", token: "token"},
+		"expired":          {inv: expired, username: "appuio#invitee", token: "token"},
+		"already redeemed": {inv: redeemed, username: "appuio#invitee", token: "token"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := prepareTest(t, tc.inv)
+			subject := invitationPreviewer{redeemer: &invitationRedeemer{client: c, usernamePrefix: "appuio#"}}
+
+			_, err := executePreview(t, subject, tc.username, tc.token)
+			require.Error(t, err)
+			if assert.IsType(t, &apierrors.StatusError{}, err) {
+				assert.Equal(t, http.StatusForbidden, int(err.(*apierrors.StatusError).Status().Code))
+			}
+		})
+	}
+}
+
+func TestCreate_Preview_InvalidTokenCountsAsFailedAttempt(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	redeemer := &invitationRedeemer{client: c, usernamePrefix: "appuio#", maxFailedAttempts: 2}
+	subject := invitationPreviewer{redeemer: redeemer}
+
+	for i := 0; i < 2; i++ {
+		_, err := executePreview(t, subject, "appuio#invitee", "wrong")
+		require.Error(t, err)
+	}
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Equal(t, 2, inv.FailedRedeemAttemptsOf("appuio#invitee"), "previews must count towards the failed attempt limit")
+	executeRequest(t, *redeemer, "appuio#invitee", inv.Status.Token, http.StatusForbidden)
+}
+
+func executePreview(t *testing.T, subject invitationPreviewer, username, token string) (*userv1.InvitationPreview, error) {
+	t.Helper()
+
+	reqCtx := request.WithUser(context.Background(), &user.DefaultInfo{Name: username})
+	res, err := subject.Create(reqCtx, &userv1.InvitationPreview{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Token: token,
+	}, nil, &metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	require.IsType(t, &userv1.InvitationPreview{}, res)
+	return res.(*userv1.InvitationPreview), nil
+}
@@ -2,11 +2,13 @@ package user
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"golang.org/x/exp/slices"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,10 +16,12 @@ import (
 	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/pkg/invitationtoken"
 )
 
 //+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations,verbs=get;list;watch
@@ -33,6 +37,15 @@ type invitationRedeemer struct {
 	client client.Client
 
 	usernamePrefix string
+
+	// maxFailedAttempts is the number of failed redeem attempts after which a user is locked out of an invitation.
+	// Zero disables locking.
+	maxFailedAttempts int
+	// maxFailedAttemptsPerInvitation is the number of failed redeem attempts of all users after which an invitation is locked.
+	// Zero disables locking.
+	maxFailedAttemptsPerInvitation int
+	// restrictToEmail is the default for invitations not specifying whether they are restricted to the invited e-mail address.
+	restrictToEmail bool
 }
 
 func (ir invitationRedeemer) NamespaceScoped() bool {
@@ -48,8 +61,12 @@ func (ir invitationRedeemer) Destroy() {}
 // Create implements redeeming invitations, it accepts `InvitationRedeemRequest`.
 // The user is identified by the username in the request context.
 // If the invitation is valid, the invitation is marked as redeemed, the user, and a snapshot of the invitations's targets are stored in the status.
+// Multi-use invitations add the user to the list of redeemers and are only marked as redeemed once the maximum number of redemptions is reached.
 // The snapshot is later used in a controller to add the user to the targets in an idempotent and retryable way.
 // If user or token are invalid, the request is rejected with a 403.
+// Failed attempts are counted per user on the invitation, users are locked out of the invitation after too many failed attempts.
+// The invitation itself is locked after too many failed attempts of all users.
+// If the invitation is restricted to the invited e-mail address, users with a different e-mail address are rejected with a 403 stating the reason.
 func (s *invitationRedeemer) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
 	irr, ok := obj.(*userv1.InvitationRedeemRequest)
 	if !ok {
@@ -61,36 +78,141 @@ func (s *invitationRedeemer) Create(ctx context.Context, obj runtime.Object, cre
 
 	l := klog.FromContext(ctx).WithName("InvitationRedeemer.Create").WithValues("invitation", name)
 
+	user, ok := userFrom(ctx, s.usernamePrefix)
+	if !ok {
+		l.Info("no allowed user found in request context", "usernamePrefix", s.usernamePrefix)
+		return nil, s.rejected(failureReasonInvalidUser)
+	}
+
+	inv, err := s.verify(ctx, l, name, token, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redeem(ctx, l, inv, token, user.GetName()); err != nil {
+		if apierrors.IsForbidden(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update invitation: %w", err)
+	}
+	return irr, nil
+}
+
+// verify returns the invitation if the user is allowed to redeem it with the given token.
+// Otherwise the rejection is counted and a forbidden error is returned.
+// Failed attempts are counted per user on the invitation, the user is locked out of the invitation after too many failed attempts.
+// Other users are not affected, so a single user can't lock the invited user out by guessing tokens.
+// Guessing with many users is stopped by locking the invitation after too many failed attempts of all users.
+func (s *invitationRedeemer) verify(ctx context.Context, l klog.Logger, name, token string, user user.Info) (*userv1.Invitation, error) {
 	inv := &userv1.Invitation{}
 	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, inv); err != nil {
 		return nil, fmt.Errorf("failed to get invitation: %w", err)
 	}
 
-	if inv.Status.Token == "" {
+	if !inv.HasToken() {
 		l.Info("token is empty")
-		return nil, errForbidden()
+		return nil, s.rejected(failureReasonNoToken)
 	}
 	if !inv.Status.ValidUntil.After(time.Now()) {
 		l.Info("invitation is expired")
-		return nil, errForbidden()
+		return nil, s.rejected(failureReasonExpired)
 	}
 	if inv.IsRedeemed() {
 		l.Info("invitation is already redeemed")
-		return nil, errForbidden()
+		return nil, s.rejected(failureReasonAlreadyRedeemed)
 	}
-	if inv.Status.Token != token {
+	if inv.IsRevoked() {
+		l.Info("invitation is revoked")
+		return nil, s.rejected(failureReasonRevoked)
+	}
+	if inv.IsLocked() {
+		l.Info("invitation is locked after too many failed redeem attempts")
+		return nil, s.rejected(failureReasonLocked)
+	}
+	if s.lockedOut(inv, user.GetName()) {
+		l.Info("user has too many failed redeem attempts", "user", user.GetName())
+		return nil, s.rejected(failureReasonLocked)
+	}
+	if !tokenMatches(inv.Status, token) {
 		l.Info("token does not match")
-		return nil, errForbidden()
+		if err := s.recordFailedAttempt(ctx, inv, user.GetName()); err != nil {
+			l.Error(err, "failed to record failed redeem attempt")
+		}
+		return nil, s.rejected(failureReasonInvalidToken)
+	}
+	if s.restrictedToEmail(inv) {
+		allowed, err := s.emailAllowed(ctx, inv, user)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			l.Info("e-mail address of user does not match invitation", "user", user.GetName())
+			redeemFailedCounter.WithLabelValues(failureReasonEmailMismatch).Inc()
+			return nil, apierrors.NewForbidden(userv1.GroupVersion.WithResource("invitations").GroupResource(), name, errEmailMismatch)
+		}
 	}
+	return inv, nil
+}
 
-	user, ok := userFrom(ctx, s.usernamePrefix)
-	if !ok {
-		l.Info("no allowed user found in request context", "usernamePrefix", s.usernamePrefix)
-		return nil, errForbidden()
+// redeem marks the invitation as redeemed by the user and stores a snapshot of the invitation's targets.
+// Multi-use invitations add the user to the redeemers and are marked as redeemed once the maximum number of redemptions is reached.
+// The storage rejects updates of invitations changed since they were read, so concurrent redemptions can't overwrite each other.
+// The redemption is retried on the latest invitation, which is checked again to still be redeemable with the token.
+func (s *invitationRedeemer) redeem(ctx context.Context, l klog.Logger, inv *userv1.Invitation, token, username string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.client.Get(ctx, client.ObjectKeyFromObject(inv), inv); err != nil {
+			return err
+		}
+		switch {
+		case inv.IsRevoked():
+			l.Info("invitation was revoked while redeeming")
+			return s.rejected(failureReasonRevoked)
+		case !tokenMatches(inv.Status, token):
+			l.Info("token was rotated while redeeming")
+			return s.rejected(failureReasonInvalidToken)
+		case inv.IsRedeemed() || inv.RedeemedByUser(username):
+			l.Info("invitation is already redeemed by user or fully redeemed", "user", username)
+			return s.rejected(failureReasonAlreadyRedeemed)
+		}
+
+		if inv.IsMultiUse() {
+			addRedeemer(inv, username)
+		} else {
+			inv.Status.TargetStatuses = newTargetStatuses(inv.Spec.TargetRefs)
+			inv.Status.RedeemedBy = username
+			apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+				Type:    userv1.ConditionRedeemed,
+				Status:  metav1.ConditionTrue,
+				Reason:  userv1.ConditionRedeemed,
+				Message: fmt.Sprintf("Redeemed by %q", username),
+			})
+		}
+		return s.client.Status().Update(ctx, inv)
+	})
+}
+
+// addRedeemer adds the user and a snapshot of the invitation's targets to the redeemers of a multi-use invitation.
+// The invitation is marked as redeemed once the maximum number of redemptions is reached.
+func addRedeemer(inv *userv1.Invitation, username string) {
+	inv.Status.Redeemers = append(inv.Status.Redeemers, userv1.Redeemer{
+		Username:       username,
+		RedeemedAt:     metav1.Now(),
+		TargetStatuses: newTargetStatuses(inv.Spec.TargetRefs),
+	})
+	if len(inv.Status.Redeemers) >= inv.Spec.MaxRedemptions {
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionRedeemed,
+			Status:  metav1.ConditionTrue,
+			Reason:  userv1.ConditionRedeemed,
+			Message: fmt.Sprintf("Redeemed by %d users", len(inv.Status.Redeemers)),
+		})
 	}
+}
 
-	ts := make([]userv1.TargetStatus, len(inv.Spec.TargetRefs))
-	for i, target := range inv.Spec.TargetRefs {
+// newTargetStatuses returns the initial target statuses for the given targets.
+func newTargetStatuses(targets []userv1.TargetRef) []userv1.TargetStatus {
+	ts := make([]userv1.TargetStatus, len(targets))
+	for i, target := range targets {
 		ts[i] = userv1.TargetStatus{
 			TargetRef: target,
 			Condition: metav1.Condition{
@@ -99,21 +221,100 @@ func (s *invitationRedeemer) Create(ctx context.Context, obj runtime.Object, cre
 			},
 		}
 	}
+	return ts
+}
 
-	inv.Status.TargetStatuses = ts
-	inv.Status.RedeemedBy = user.GetName()
-	apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
-		Type:    userv1.ConditionRedeemed,
-		Status:  metav1.ConditionTrue,
-		Reason:  userv1.ConditionRedeemed,
-		Message: fmt.Sprintf("Redeemed by %q", user.GetName()),
+// maxFailedAttemptUsers is the maximum number of users whose failed redeem attempts are tracked on an invitation.
+// It bounds the size of the invitation.
+const maxFailedAttemptUsers = 100
+
+// recordFailedAttempt increments the failed redeem attempts of the user and of all users on the invitation.
+// The counters are persisted in the status, so they are shared between API server replicas and survive restarts.
+// The storage rejects updates of invitations changed since they were read, concurrent attempts are retried on the latest invitation and all of them are counted.
+// The `Locked` condition lists the users locked out of the invitation, or marks the invitation as locked once too many attempts of all users failed.
+func (s *invitationRedeemer) recordFailedAttempt(ctx context.Context, inv *userv1.Invitation, username string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := s.client.Get(ctx, client.ObjectKeyFromObject(inv), inv); err != nil {
+			return err
+		}
+		inv.Status.FailedRedeemAttemptsTotal++
+		i := slices.IndexFunc(inv.Status.FailedRedeemAttempts, func(a userv1.FailedRedeemAttempts) bool { return a.Username == username })
+		if i < 0 {
+			inv.Status.FailedRedeemAttempts = append(pruneFailedRedeemAttempts(inv.Status.FailedRedeemAttempts, maxFailedAttemptUsers-1), userv1.FailedRedeemAttempts{Username: username})
+			i = len(inv.Status.FailedRedeemAttempts) - 1
+		}
+		inv.Status.FailedRedeemAttempts[i].Count++
+		switch {
+		case s.maxFailedAttemptsPerInvitation > 0 && inv.Status.FailedRedeemAttemptsTotal >= s.maxFailedAttemptsPerInvitation:
+			apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+				Type:    userv1.ConditionLocked,
+				Status:  metav1.ConditionTrue,
+				Reason:  userv1.ConditionReasonTooManyFailedAttempts,
+				Message: fmt.Sprintf("Invitation locked after %d failed redeem attempts, it must be resent", inv.Status.FailedRedeemAttemptsTotal),
+			})
+		case s.lockedOut(inv, username):
+			apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+				Type:    userv1.ConditionLocked,
+				Status:  metav1.ConditionTrue,
+				Reason:  userv1.ConditionReasonUsersLockedOut,
+				Message: fmt.Sprintf("Users locked out after %d failed redeem attempts: %s", s.maxFailedAttempts, strings.Join(s.lockedOutUsers(inv), ", ")),
+			})
+		}
+		return s.client.Status().Update(ctx, inv)
 	})
+	if err != nil {
+		return err
+	}
+	if s.maxFailedAttempts > 0 && inv.FailedRedeemAttemptsOf(username) == s.maxFailedAttempts {
+		userLockedOutCounter.Inc()
+	}
+	if s.maxFailedAttemptsPerInvitation > 0 && inv.Status.FailedRedeemAttemptsTotal == s.maxFailedAttemptsPerInvitation {
+		invitationLockedCounter.Inc()
+	}
+	return nil
+}
 
-	if err := s.client.Status().Update(ctx, inv); err != nil {
-		return nil, fmt.Errorf("failed to update invitation: %w", err)
+// pruneFailedRedeemAttempts returns at most max failed attempts, dropping the users with the fewest failed attempts.
+// Users with the most failed attempts are the closest to being locked out and are kept.
+func pruneFailedRedeemAttempts(attempts []userv1.FailedRedeemAttempts, max int) []userv1.FailedRedeemAttempts {
+	if len(attempts) <= max {
+		return attempts
 	}
+	pruned := slices.Clone(attempts)
+	slices.SortStableFunc(pruned, func(a, b userv1.FailedRedeemAttempts) bool { return a.Count > b.Count })
+	return pruned[:max]
+}
 
-	return irr, nil
+// lockedOutUsers returns the users locked out of the invitation.
+func (s *invitationRedeemer) lockedOutUsers(inv *userv1.Invitation) []string {
+	users := []string{}
+	for _, a := range inv.Status.FailedRedeemAttempts {
+		if s.lockedOut(inv, a.Username) {
+			users = append(users, a.Username)
+		}
+	}
+	return users
+}
+
+// lockedOut returns true if the user reached the maximum number of failed redeem attempts on the invitation.
+func (s *invitationRedeemer) lockedOut(inv *userv1.Invitation, username string) bool {
+	return s.maxFailedAttempts > 0 && inv.FailedRedeemAttemptsOf(username) >= s.maxFailedAttempts
+}
+
+// rejected counts the failed redemption and returns a forbidden error.
+// The reason is not exposed to the user to not leak information about the invitation.
+func (s *invitationRedeemer) rejected(reason string) error {
+	redeemFailedCounter.WithLabelValues(reason).Inc()
+	return errForbidden()
+}
+
+// tokenMatches returns true if the token matches the token hash of the invitation.
+// Invitations issued before tokens were hashed store the plaintext token, it is compared in constant time.
+func tokenMatches(status userv1.InvitationStatus, token string) bool {
+	if status.TokenHash != "" {
+		return invitationtoken.Verify(status.TokenHash, token)
+	}
+	return subtle.ConstantTimeCompare([]byte(status.Token), []byte(token)) == 1
 }
 
 // userFrom returns the user from the context if it is a non-serviceaccount user and has the usernamePrefix.
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+//+kubebuilder:rbac:groups="appuio.io",resources=users,verbs=get
+
+const (
+	// emailExtraKey is the key of the e-mail claim in the extra attributes of the authenticated user.
+	emailExtraKey = "email"
+	// emailVerifiedExtraKey is the key of the claim marking the e-mail address as verified in the extra attributes of the authenticated user.
+	emailVerifiedExtraKey = "email_verified"
+)
+
+// errEmailMismatch is returned if the invitation is restricted to an e-mail address not matching the one of the user.
+var errEmailMismatch = errors.New("invitation can only be redeemed by the invited e-mail address")
+
+// restrictedToEmail returns true if the invitation can only be redeemed by the invited e-mail address or allowed domains.
+// Invitations without e-mail address and allowed domains can't be restricted.
+// Multi-use invitations with allowed domains are restricted by default, the domains being their only way to limit who can redeem them.
+func (s *invitationRedeemer) restrictedToEmail(inv *userv1.Invitation) bool {
+	if inv.Spec.Email == "" && len(inv.Spec.AllowedEmailDomains) == 0 {
+		return false
+	}
+	if inv.Spec.RestrictToEmail != nil {
+		return *inv.Spec.RestrictToEmail
+	}
+	if inv.IsMultiUse() && len(inv.Spec.AllowedEmailDomains) > 0 {
+		return true
+	}
+	return s.restrictToEmail
+}
+
+// emailAllowed returns true if the e-mail address of the user matches the invited e-mail address or one of the allowed domains.
+func (s *invitationRedeemer) emailAllowed(ctx context.Context, inv *userv1.Invitation, u user.Info) (bool, error) {
+	email, err := s.userEmail(ctx, u)
+	if err != nil || email == "" {
+		return false, err
+	}
+
+	if inv.Spec.Email != "" && strings.EqualFold(email, inv.Spec.Email) {
+		return true, nil
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false, nil
+	}
+	domain := email[at+1:]
+	for _, allowed := range inv.Spec.AllowedEmailDomains {
+		if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// userEmail returns the e-mail address of the user.
+// The e-mail claim of the authentication is preferred if it is marked as verified, the e-mail address of the corresponding User is used otherwise.
+func (s *invitationRedeemer) userEmail(ctx context.Context, u user.Info) (string, error) {
+	extra := u.GetExtra()
+	if emails, verified := extra[emailExtraKey], extra[emailVerifiedExtraKey]; len(emails) > 0 && emails[0] != "" && len(verified) > 0 && verified[0] == "true" {
+		return emails[0], nil
+	}
+
+	cu := controlv1.User{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: strings.TrimPrefix(u.GetName(), s.usernamePrefix)}, &cu)
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	return cu.Status.Email, nil
+}
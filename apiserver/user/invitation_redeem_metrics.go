@@ -0,0 +1,36 @@
+package user
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	failureReasonNoToken         = "no_token"
+	failureReasonExpired         = "expired"
+	failureReasonAlreadyRedeemed = "already_redeemed"
+	failureReasonRevoked         = "revoked"
+	failureReasonLocked          = "locked"
+	failureReasonInvalidToken    = "invalid_token"
+	failureReasonInvalidUser     = "invalid_user"
+	failureReasonEmailMismatch   = "email_mismatch"
+)
+
+var (
+	redeemFailedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "control_api_invitation_redeem_failed_total",
+		Help: "Total number of failed invitation redemptions by reason",
+	}, []string{"reason"})
+	userLockedOutCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "control_api_invitation_user_locked_out_total",
+		Help: "Total number of users locked out of an invitation after too many failed redeem attempts",
+	})
+	invitationLockedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "control_api_invitation_locked_total",
+		Help: "Total number of invitations locked after too many failed redeem attempts of all users",
+	})
+)
+
+func init() {
+	legacyregistry.Registerer().MustRegister(redeemFailedCounter, userLockedOutCounter, invitationLockedCounter)
+}
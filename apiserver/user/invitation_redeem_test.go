@@ -3,9 +3,11 @@ package user
 import (
 	"context"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -18,7 +20,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
 	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/invitationtoken"
 )
 
 func TestCreate_Redeem_Success(t *testing.T) {
@@ -51,6 +56,27 @@ func TestCreate_Redeem_Success(t *testing.T) {
 	}, inv.Status.TargetStatuses)
 }
 
+func TestCreate_Redeem_HashedToken(t *testing.T) {
+	token, err := invitationtoken.Generate()
+	require.NoError(t, err)
+	hash, err := invitationtoken.Hash(token)
+	require.NoError(t, err)
+
+	inv := redeemableInvitation()
+	inv.Status.Token = ""
+	inv.Status.TokenHash = hash
+
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c}
+
+	executeRequest(t, subject, "redeeming-user", hash, http.StatusForbidden)
+	executeRequest(t, subject, "redeeming-user", "token", http.StatusForbidden)
+	executeRequest(t, subject, "redeeming-user", token, http.StatusOK)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.True(t, inv.IsRedeemed())
+}
+
 func TestConnect_Redeem_Fail_InvalidToken(t *testing.T) {
 	c := prepareTest(t, redeemableInvitation())
 	subject := invitationRedeemer{client: c}
@@ -100,6 +126,297 @@ func TestConnect_Redeem_Fail_AlreadyRedeemed(t *testing.T) {
 	executeRequest(t, subject, "redeeming-user", "token", http.StatusForbidden)
 }
 
+func TestConnect_Redeem_Fail_LockedOutAfterFailedAttempts(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c, maxFailedAttempts: 2}
+
+	failedBefore := testutil.ToFloat64(redeemFailedCounter.WithLabelValues(failureReasonInvalidToken))
+	lockedBefore := testutil.ToFloat64(userLockedOutCounter)
+
+	executeRequest(t, subject, "redeeming-user", "invalid", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Equal(t, 1, inv.FailedRedeemAttemptsOf("redeeming-user"))
+	assert.Nil(t, apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionLocked))
+
+	executeRequest(t, subject, "redeeming-user", "invalid", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Equal(t, []userv1.FailedRedeemAttempts{{Username: "redeeming-user", Count: 2}}, inv.Status.FailedRedeemAttempts)
+	locked := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionLocked)
+	require.NotNil(t, locked)
+	assert.Equal(t, metav1.ConditionTrue, locked.Status)
+	assert.Contains(t, locked.Message, "redeeming-user")
+
+	executeRequest(t, subject, "redeeming-user", "token", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.False(t, inv.IsRedeemed(), "locked out user must not be able to redeem with the correct token")
+
+	assert.Equal(t, failedBefore+2, testutil.ToFloat64(redeemFailedCounter.WithLabelValues(failureReasonInvalidToken)))
+	assert.Equal(t, lockedBefore+1, testutil.ToFloat64(userLockedOutCounter))
+}
+
+func TestConnect_Redeem_LockOutOnlyAffectsUser(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c, maxFailedAttempts: 2}
+
+	executeRequest(t, subject, "attacker", "invalid", http.StatusForbidden)
+	executeRequest(t, subject, "attacker", "invalid", http.StatusForbidden)
+	executeRequest(t, subject, "attacker", "token", http.StatusForbidden)
+
+	executeRequest(t, subject, "redeeming-user", "token", http.StatusOK)
+}
+
+func TestConnect_Redeem_LockOutPersisted(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Status.FailedRedeemAttempts = []userv1.FailedRedeemAttempts{{Username: "redeeming-user", Count: 2}}
+	c := prepareTest(t, inv)
+
+	// A new redeemer, such as another API server replica, sees the failed attempts
+	subject := invitationRedeemer{client: c, maxFailedAttempts: 2}
+	executeRequest(t, subject, "redeeming-user", "token", http.StatusForbidden)
+}
+
+func TestConnect_Redeem_Fail_InvitationLockedAfterFailedAttempts(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c, maxFailedAttempts: 2, maxFailedAttemptsPerInvitation: 3}
+
+	lockedBefore := testutil.ToFloat64(invitationLockedCounter)
+
+	executeRequest(t, subject, "attacker-1", "invalid", http.StatusForbidden)
+	executeRequest(t, subject, "attacker-2", "invalid", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.False(t, inv.IsLocked())
+
+	executeRequest(t, subject, "attacker-3", "invalid", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Equal(t, 3, inv.Status.FailedRedeemAttemptsTotal)
+	assert.True(t, inv.IsLocked(), "failed attempts of all users must lock the invitation")
+
+	executeRequest(t, subject, "redeeming-user", "token", http.StatusForbidden)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.False(t, inv.IsRedeemed(), "locked invitation must not be redeemable with the correct token")
+	assert.Equal(t, 3, inv.Status.FailedRedeemAttemptsTotal, "attempts on a locked invitation must not be counted")
+	assert.Equal(t, lockedBefore+1, testutil.ToFloat64(invitationLockedCounter))
+}
+
+func TestConnect_Redeem_Fail_Concurrent(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c, maxFailedAttempts: 10}
+
+	const attempts = 5
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := subject.Create(request.WithUser(context.Background(), &user.DefaultInfo{Name: "attacker"}), &userv1.InvitationRedeemRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: inv.Name},
+				Token:      "invalid",
+			}, nil, &metav1.CreateOptions{})
+			assert.True(t, apierrors.IsForbidden(err), "expected forbidden, got %v", err)
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Equal(t, attempts, inv.FailedRedeemAttemptsOf("attacker"), "concurrent failed attempts must not overwrite each other")
+	assert.Equal(t, attempts, inv.Status.FailedRedeemAttemptsTotal)
+}
+
+func Test_pruneFailedRedeemAttempts(t *testing.T) {
+	attempts := []userv1.FailedRedeemAttempts{
+		{Username: "a", Count: 1},
+		{Username: "b", Count: 3},
+		{Username: "c", Count: 2},
+	}
+
+	assert.Equal(t, attempts, pruneFailedRedeemAttempts(attempts, 3))
+	assert.Equal(t, []userv1.FailedRedeemAttempts{
+		{Username: "b", Count: 3},
+		{Username: "c", Count: 2},
+	}, pruneFailedRedeemAttempts(attempts, 2), "users with the fewest failed attempts must be dropped")
+	assert.Equal(t, "a", attempts[0].Username, "input must not be modified")
+}
+
+func TestCreate_Redeem_MultiUse(t *testing.T) {
+	target := userv1.TargetRef{
+		APIGroup:  "appuio.io",
+		Kind:      "Team",
+		Name:      "team",
+		Namespace: "team-namespace",
+	}
+
+	inv := redeemableInvitation()
+	inv.Spec.MaxRedemptions = 2
+	inv.Spec.TargetRefs = []userv1.TargetRef{target}
+
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c}
+
+	executeRequest(t, subject, "user-1", inv.Status.Token, http.StatusOK)
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.False(t, inv.IsRedeemed(), "invitation should not be redeemed before reaching the maximum")
+	assert.Empty(t, inv.Status.RedeemedBy)
+
+	executeRequest(t, subject, "user-1", inv.Status.Token, http.StatusForbidden)
+	executeRequest(t, subject, "user-2", inv.Status.Token, http.StatusOK)
+	executeRequest(t, subject, "user-3", inv.Status.Token, http.StatusForbidden)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.True(t, inv.IsRedeemed())
+	require.Len(t, inv.Status.Redeemers, 2)
+	for i, username := range []string{"user-1", "user-2"} {
+		assert.Equal(t, username, inv.Status.Redeemers[i].Username)
+		assert.Equal(t, []userv1.TargetStatus{{
+			TargetRef: target,
+			Condition: metav1.Condition{Type: userv1.ConditionRedeemed, Status: metav1.ConditionUnknown},
+		}}, inv.Status.Redeemers[i].TargetStatuses)
+	}
+}
+
+func TestCreate_Redeem_MultiUse_Concurrent(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Spec.MaxRedemptions = 3
+
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c}
+
+	users := []string{"user-1", "user-2", "user-3", "user-4", "user-5"}
+	errs := make([]error, len(users))
+	var wg sync.WaitGroup
+	for i, username := range users {
+		wg.Add(1)
+		go func(i int, username string) {
+			defer wg.Done()
+			_, errs[i] = subject.Create(request.WithUser(context.Background(), &user.DefaultInfo{Name: username}), &userv1.InvitationRedeemRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: inv.Name},
+				Token:      "token",
+			}, nil, &metav1.CreateOptions{})
+		}(i, username)
+	}
+	wg.Wait()
+
+	redeemed := []string{}
+	for i, err := range errs {
+		if err == nil {
+			redeemed = append(redeemed, users[i])
+			continue
+		}
+		assert.True(t, apierrors.IsForbidden(err), "redemptions exceeding the maximum must be rejected, got %v", err)
+	}
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.True(t, inv.IsRedeemed())
+	assert.Len(t, redeemed, 3, "exactly the maximum number of redemptions must succeed")
+	redeemers := []string{}
+	for _, r := range inv.Status.Redeemers {
+		redeemers = append(redeemers, r.Username)
+	}
+	assert.ElementsMatch(t, redeemed, redeemers, "successful redemptions must not overwrite each other")
+}
+
+func TestConnect_Redeem_MultiUse_AllowedDomains(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Spec.MaxRedemptions = 10
+	inv.Spec.AllowedEmailDomains = []string{"example.com"}
+
+	c := prepareTest(t, inv)
+	subject := invitationRedeemer{client: c}
+
+	executeRequestAs(t, subject, &user.DefaultInfo{Name: "other", Extra: map[string][]string{"email": {"other@example.net"}, "email_verified": {"true"}}}, "token", http.StatusForbidden)
+	executeRequestAs(t, subject, &user.DefaultInfo{Name: "student", Extra: map[string][]string{"email": {"student@example.com"}, "email_verified": {"true"}}}, "token", http.StatusOK)
+}
+
+func TestConnect_Redeem_RestrictToEmail(t *testing.T) {
+	withEmail := func(name, email string) user.Info {
+		return &user.DefaultInfo{Name: name, Extra: map[string][]string{"email": {email}, "email_verified": {"true"}}}
+	}
+	withUnverifiedEmail := func(name, email string) user.Info {
+		return &user.DefaultInfo{Name: name, Extra: map[string][]string{"email": {email}, "email_verified": {"false"}}}
+	}
+	boolPtr := func(b bool) *bool { return &b }
+	appuioUser := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "status-user"},
+		Status:     controlv1.UserStatus{Email: "Invited@Example.com"},
+	}
+
+	tests := map[string]struct {
+		restrictToEmail bool
+		override        *bool
+		allowedDomains  []string
+		user            user.Info
+		expectedStatus  int
+	}{
+		"matching email claim": {
+			restrictToEmail: true,
+			user:            withEmail("appuio#redeeming-user", "INVITED@example.com"),
+			expectedStatus:  http.StatusOK,
+		},
+		"matching unverified email claim": {
+			restrictToEmail: true,
+			user:            withUnverifiedEmail("appuio#redeeming-user", "invited@example.com"),
+			expectedStatus:  http.StatusForbidden,
+		},
+		"unverified email claim falls back to user status email": {
+			restrictToEmail: true,
+			user:            withUnverifiedEmail("appuio#status-user", "other@example.net"),
+			expectedStatus:  http.StatusOK,
+		},
+		"matching user status email": {
+			restrictToEmail: true,
+			user:            &user.DefaultInfo{Name: "appuio#status-user"},
+			expectedStatus:  http.StatusOK,
+		},
+		"allowed domain": {
+			restrictToEmail: true,
+			allowedDomains:  []string{"example.org"},
+			user:            withEmail("appuio#redeeming-user", "colleague@example.org"),
+			expectedStatus:  http.StatusOK,
+		},
+		"mismatching email": {
+			restrictToEmail: true,
+			allowedDomains:  []string{"example.org"},
+			user:            withEmail("appuio#redeeming-user", "other@example.net"),
+			expectedStatus:  http.StatusForbidden,
+		},
+		"unknown email": {
+			restrictToEmail: true,
+			user:            &user.DefaultInfo{Name: "appuio#unknown"},
+			expectedStatus:  http.StatusForbidden,
+		},
+		"restriction disabled": {
+			user:           withEmail("appuio#redeeming-user", "other@example.net"),
+			expectedStatus: http.StatusOK,
+		},
+		"restriction disabled by invitation": {
+			restrictToEmail: true,
+			override:        boolPtr(false),
+			user:            withEmail("appuio#redeeming-user", "other@example.net"),
+			expectedStatus:  http.StatusOK,
+		},
+		"restriction enabled by invitation": {
+			override:       boolPtr(true),
+			user:           withEmail("appuio#redeeming-user", "other@example.net"),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			inv := redeemableInvitation()
+			inv.Spec.Email = "invited@example.com"
+			inv.Spec.RestrictToEmail = tc.override
+			inv.Spec.AllowedEmailDomains = tc.allowedDomains
+			c := prepareTest(t, inv, appuioUser.DeepCopy())
+			subject := invitationRedeemer{client: c, usernamePrefix: "appuio#", restrictToEmail: tc.restrictToEmail}
+
+			executeRequestAs(t, subject, tc.user, "token", tc.expectedStatus)
+		})
+	}
+}
+
 func redeemableInvitation() *userv1.Invitation {
 	return &userv1.Invitation{
 		ObjectMeta: metav1.ObjectMeta{
@@ -114,10 +431,13 @@ func redeemableInvitation() *userv1.Invitation {
 
 func executeRequest(t *testing.T, subject invitationRedeemer, username, token string, expectedHTTPStatus int) {
 	t.Helper()
+	executeRequestAs(t, subject, &user.DefaultInfo{Name: username}, token, expectedHTTPStatus)
+}
 
-	reqCtx := request.WithUser(context.Background(), &user.DefaultInfo{
-		Name: username,
-	})
+func executeRequestAs(t *testing.T, subject invitationRedeemer, u user.Info, token string, expectedHTTPStatus int) {
+	t.Helper()
+
+	reqCtx := request.WithUser(context.Background(), u)
 	h, err := subject.Create(reqCtx, &userv1.InvitationRedeemRequest{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "subject",
@@ -142,6 +462,8 @@ func prepareTest(t *testing.T, initObjs ...client.Object) client.WithWatch {
 	scheme := runtime.NewScheme()
 	require.NoError(t, clientgoscheme.AddToScheme(scheme))
 	require.NoError(t, userv1.AddToScheme(scheme))
+	require.NoError(t, controlv1.AddToScheme(scheme))
+	require.NoError(t, billingv1.AddToScheme(scheme))
 
 	return fake.NewClientBuilder().
 		WithScheme(scheme).
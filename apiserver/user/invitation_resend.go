@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/apiserver/authwrapper"
+)
+
+var _ authwrapper.SubResourceStorage = &invitationResender{}
+
+// invitationResender implements the `invitations/resend` subresource.
+type invitationResender struct {
+	client  client.Client
+	handoff tokenHandoff
+
+	// validFor is the validity of expired invitations resent without requesting a validity.
+	validFor time.Duration
+	// maxValidFor is the maximum validity a resend can request.
+	// Zero allows any validity.
+	maxValidFor time.Duration
+}
+
+func (r invitationResender) NamespaceScoped() bool {
+	return false
+}
+
+func (r invitationResender) New() runtime.Object {
+	return &userv1.InvitationResendRequest{}
+}
+
+func (r invitationResender) Destroy() {}
+
+// Create resends the invitation with the given name, it accepts `InvitationResendRequest`.
+// Only the hash of the token is stored, so a new token is issued and returned in the response. The previous token is invalidated.
+// The `EmailSent` condition is reset so the invitation email is sent again with the new token, reminder and expiry notification are reset as well.
+// If requested, the validity is extended. Expired invitations are valid again for their requested validity.
+// Issuing a new token resets the failed redeem attempts and unlocks the invitation, users locked out of the invitation can try again.
+// Redeemed and revoked invitations can't be resent.
+func (r *invitationResender) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	rr, ok := obj.(*userv1.InvitationResendRequest)
+	if !ok {
+		return nil, fmt.Errorf("not an InvitationResendRequest: %#v", obj)
+	}
+	if rr.ValidFor != nil && rr.ValidFor.Duration <= 0 {
+		return nil, apierrors.NewBadRequest("validFor must be positive")
+	}
+	if rr.ValidFor != nil && r.maxValidFor > 0 && rr.ValidFor.Duration > r.maxValidFor {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("validFor must not exceed %s", r.maxValidFor))
+	}
+
+	token, hash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		inv := &userv1.Invitation{}
+		if err := r.client.Get(ctx, client.ObjectKey{Name: name}, inv); err != nil {
+			return err
+		}
+		if inv.IsRedeemed() {
+			return apierrors.NewBadRequest("invitation has already been redeemed")
+		}
+		if inv.IsRevoked() {
+			return apierrors.NewBadRequest("invitation has been revoked")
+		}
+
+		now := time.Now()
+		switch {
+		case rr.ValidFor != nil:
+			inv.Status.ValidUntil = metav1.NewTime(now.Add(rr.ValidFor.Duration))
+		case !inv.Status.ValidUntil.After(now):
+			inv.Status.ValidUntil = metav1.NewTime(invitationValidUntil(inv.Spec, now, r.validFor, r.maxValidFor))
+		}
+		inv.Status.Token = ""
+		inv.Status.TokenHash = hash
+		inv.Status.FailedRedeemAttempts = nil
+		inv.Status.FailedRedeemAttemptsTotal = 0
+		apimeta.RemoveStatusCondition(&inv.Status.Conditions, userv1.ConditionLocked)
+		apimeta.RemoveStatusCondition(&inv.Status.Conditions, userv1.ConditionEmailSent)
+		apimeta.RemoveStatusCondition(&inv.Status.Conditions, userv1.ConditionReminderSent)
+		apimeta.RemoveStatusCondition(&inv.Status.Conditions, userv1.ConditionExpiryNotificationSent)
+		inv.Status.ExpiryNotificationRecipients = nil
+
+		// The token is handed off before the hash is persisted, the email is only sent once the hash changed.
+		if inv.Spec.Email != "" && len(opts.DryRun) == 0 {
+			if err := r.handoff.Put(ctx, inv.Name, token); err != nil {
+				return err
+			}
+		}
+		return r.client.Status().Update(ctx, inv, &client.SubResourceUpdateOptions{UpdateOptions: client.UpdateOptions{DryRun: opts.DryRun}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.DryRun) == 0 {
+		rr.Token = token
+	}
+	return rr, nil
+}
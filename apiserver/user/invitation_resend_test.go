@@ -0,0 +1,112 @@
+package user
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+func TestInvitationResender_Create(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Spec.Email = "subject@example.com"
+	validUntil := inv.Status.ValidUntil
+	inv.Status.FailedRedeemAttempts = []userv1.FailedRedeemAttempts{{Username: "user", Count: 5}}
+	inv.Status.FailedRedeemAttemptsTotal = 5
+	for _, cond := range []string{userv1.ConditionEmailSent, userv1.ConditionReminderSent, userv1.ConditionLocked} {
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:   cond,
+			Status: metav1.ConditionTrue,
+		})
+	}
+	c := prepareTest(t, inv)
+	handoff := fakeHandoff{}
+	subject := invitationResender{client: c, handoff: handoff}
+
+	res, err := subject.Create(context.Background(), inv.Name, &userv1.InvitationResendRequest{}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	token := res.(*userv1.InvitationResendRequest).Token
+	require.NotEmpty(t, token, "the new token must be returned")
+	assert.Equal(t, token, handoff[inv.Name], "the new token must be handed off for the invitation email")
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.Empty(t, inv.Status.Token, "the token must not be stored")
+	assert.True(t, invitationtoken.Verify(inv.Status.TokenHash, token))
+	assert.Nil(t, apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionEmailSent))
+	assert.Nil(t, apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionReminderSent))
+	assert.Nil(t, apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionLocked))
+	assert.Empty(t, inv.Status.FailedRedeemAttempts)
+	assert.Zero(t, inv.Status.FailedRedeemAttemptsTotal)
+	assert.WithinDuration(t, validUntil.Time, inv.Status.ValidUntil.Time, time.Second, "validity must be kept if not requested")
+}
+
+func TestInvitationResender_Create_Extend(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	handoff := fakeHandoff{}
+	subject := invitationResender{client: c, handoff: handoff}
+
+	_, err := subject.Create(context.Background(), inv.Name, &userv1.InvitationResendRequest{
+		ValidFor: &metav1.Duration{Duration: 72 * time.Hour},
+	}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, handoff, "tokens of invitations without e-mail must not be handed off")
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), inv.Status.ValidUntil.Time, time.Minute)
+}
+
+func TestInvitationResender_Create_Expired(t *testing.T) {
+	inv := redeemableInvitation()
+	inv.Status.ValidUntil = metav1.NewTime(time.Now().Add(-time.Hour))
+	c := prepareTest(t, inv)
+	subject := invitationResender{client: c, handoff: fakeHandoff{}, validFor: 24 * time.Hour}
+
+	_, err := subject.Create(context.Background(), inv.Name, &userv1.InvitationResendRequest{}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), inv.Status.ValidUntil.Time, time.Minute, "expired invitations must be valid again")
+}
+
+func TestInvitationResender_Create_Rejected(t *testing.T) {
+	redeemed := redeemableInvitation()
+	redeemed.Name = "redeemed"
+	apimeta.SetStatusCondition(&redeemed.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+	revoked := redeemableInvitation()
+	revoked.Name = "revoked"
+	apimeta.SetStatusCondition(&revoked.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRevoked,
+		Status: metav1.ConditionTrue,
+	})
+	c := prepareTest(t, redeemed, revoked)
+	subject := invitationResender{client: c, handoff: fakeHandoff{}}
+
+	for _, name := range []string{"redeemed", "revoked"} {
+		_, err := subject.Create(context.Background(), name, &userv1.InvitationResendRequest{}, nil, &metav1.CreateOptions{})
+		assert.True(t, apierrors.IsBadRequest(err), "%s: expected bad request, got %v", name, err)
+	}
+
+	_, err := subject.Create(context.Background(), "subject", &userv1.InvitationResendRequest{
+		ValidFor: &metav1.Duration{Duration: -time.Hour},
+	}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request for negative validity, got %v", err)
+
+	subject.maxValidFor = 24 * time.Hour
+	_, err = subject.Create(context.Background(), "subject", &userv1.InvitationResendRequest{
+		ValidFor: &metav1.Duration{Duration: 48 * time.Hour},
+	}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request for validity exceeding the maximum, got %v", err)
+}
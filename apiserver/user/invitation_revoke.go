@@ -0,0 +1,78 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/apiserver/authwrapper"
+)
+
+var _ authwrapper.SubResourceStorage = &invitationRevoker{}
+
+// invitationRevoker implements the `invitations/revoke` subresource.
+type invitationRevoker struct {
+	client client.Client
+}
+
+func (r invitationRevoker) NamespaceScoped() bool {
+	return false
+}
+
+func (r invitationRevoker) New() runtime.Object {
+	return &userv1.InvitationRevokeRequest{}
+}
+
+func (r invitationRevoker) Destroy() {}
+
+// Create revokes the invitation with the given name, it accepts `InvitationRevokeRequest`.
+// The invitation is marked with the `Revoked` condition and can no longer be redeemed.
+// Revoking an already revoked invitation is a no-op, revoking a redeemed invitation is rejected.
+func (r *invitationRevoker) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	rr, ok := obj.(*userv1.InvitationRevokeRequest)
+	if !ok {
+		return nil, fmt.Errorf("not an InvitationRevokeRequest: %#v", obj)
+	}
+
+	message := "Revoked"
+	if u, ok := request.UserFrom(ctx); ok {
+		message = fmt.Sprintf("Revoked by %q", u.GetName())
+	}
+	if rr.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, rr.Reason)
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		inv := &userv1.Invitation{}
+		if err := r.client.Get(ctx, client.ObjectKey{Name: name}, inv); err != nil {
+			return err
+		}
+		if inv.IsRevoked() {
+			return nil
+		}
+		if inv.IsRedeemed() {
+			return apierrors.NewBadRequest("invitation has already been redeemed")
+		}
+
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionRevoked,
+			Status:  metav1.ConditionTrue,
+			Reason:  userv1.ConditionRevoked,
+			Message: message,
+		})
+		return r.client.Status().Update(ctx, inv, &client.SubResourceUpdateOptions{UpdateOptions: client.UpdateOptions{DryRun: opts.DryRun}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+)
+
+func TestInvitationRevoker_Create(t *testing.T) {
+	inv := redeemableInvitation()
+	c := prepareTest(t, inv)
+	subject := invitationRevoker{client: c}
+
+	ctx := request.WithUser(context.Background(), &user.DefaultInfo{Name: "revoking-user"})
+	_, err := subject.Create(ctx, inv.Name, &userv1.InvitationRevokeRequest{Reason: "wrong address"}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(inv), inv))
+	assert.True(t, inv.IsRevoked())
+	cond := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionRevoked)
+	require.NotNil(t, cond)
+	assert.Equal(t, `Revoked by "revoking-user": wrong address`, cond.Message)
+
+	_, err = subject.Create(ctx, inv.Name, &userv1.InvitationRevokeRequest{}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "revoking a revoked invitation should be a no-op")
+
+	executeRequest(t, invitationRedeemer{client: c}, "redeeming-user", "token", http.StatusForbidden)
+}
+
+func TestInvitationRevoker_Create_Redeemed(t *testing.T) {
+	inv := redeemableInvitation()
+	apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+	c := prepareTest(t, inv)
+	subject := invitationRevoker{client: c}
+
+	_, err := subject.Create(context.Background(), inv.Name, &userv1.InvitationRevokeRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+}
+
+func TestInvitationRevoker_Create_NotFound(t *testing.T) {
+	c := prepareTest(t)
+	subject := invitationRevoker{client: c}
+
+	_, err := subject.Create(context.Background(), "missing", &userv1.InvitationRevokeRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+}
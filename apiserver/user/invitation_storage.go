@@ -1,6 +1,12 @@
 package user
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -10,37 +16,122 @@ import (
 	"sigs.k8s.io/apiserver-runtime/pkg/util/loopback"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
 	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
 	"github.com/appuio/control-api/apiserver/authwrapper"
 	"github.com/appuio/control-api/apiserver/secretstorage"
+	"github.com/appuio/control-api/pkg/invitationtoken"
 )
 
-// NewInvitationRedeemStorage creates a new REST storage for InvitationRedeemRequest objects
-func NewInvitationRedeemStorage(usernamePrefix string) restbuilder.ResourceHandlerProvider {
+// NewInvitationRedeemStorages creates new REST storages for InvitationRedeemRequest and InvitationPreview objects.
+// Both storages verify the token the same way and share the failed attempt tracking, so previews can't be used to guess tokens.
+// Users are locked out of an invitation after maxFailedAttempts failed attempts on it.
+// Invitations are locked after maxFailedAttemptsPerInvitation failed attempts of all users.
+// Zero disables the respective lock.
+// If restrictToEmail is set, invitations can only be redeemed and previewed by the invited e-mail address unless the invitation overrides it.
+func NewInvitationRedeemStorages(usernamePrefix string, maxFailedAttempts, maxFailedAttemptsPerInvitation int, restrictToEmail bool) (redeem, preview restbuilder.ResourceHandlerProvider) {
+	var stor *invitationRedeemer
+	redeemer := func() (*invitationRedeemer, error) {
+		if stor != nil {
+			return stor, nil
+		}
+		c, err := buildClient()
+		if err != nil {
+			return nil, err
+		}
+
+		stor = &invitationRedeemer{
+			client:                         c,
+			usernamePrefix:                 usernamePrefix,
+			maxFailedAttempts:              maxFailedAttempts,
+			maxFailedAttemptsPerInvitation: maxFailedAttemptsPerInvitation,
+			restrictToEmail:                restrictToEmail,
+		}
+		return stor, nil
+	}
+
+	redeem = func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		r, err := redeemer()
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	preview = func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		r, err := redeemer()
+		if err != nil {
+			return nil, err
+		}
+		return &invitationPreviewer{redeemer: r}, nil
+	}
+	return redeem, preview
+}
+
+// NewInvitationRevokeStorage creates a new REST storage for the `invitations/revoke` subresource.
+// Requests are authorized with the `revoke` verb on the invitation.
+func NewInvitationRevokeStorage() restbuilder.ResourceHandlerProvider {
 	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
 		c, err := buildClient()
 		if err != nil {
 			return nil, err
 		}
 
-		stor := &invitationRedeemer{
-			client:         c,
-			usernamePrefix: usernamePrefix,
+		return authwrapper.NewAuthorizedSubResourceStorage(&invitationRevoker{client: c}, invitationRBACID, "revoke", loopback.GetAuthorizer()), nil
+	}
+}
+
+// NewInvitationResendStorage creates a new REST storage for the `invitations/resend` subresource.
+// Requests are authorized with the `resend` verb on the invitation.
+// The new token is handed off through secrets in the token hand-off namespace.
+// Expired invitations are valid for validFor again, requested validities are bounded by maxValidFor, zero allows any validity.
+func NewInvitationResendStorage(tokenHandoffNS string, validFor, maxValidFor time.Duration) restbuilder.ResourceHandlerProvider {
+	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		c, err := buildClient()
+		if err != nil {
+			return nil, err
 		}
 
-		return stor, nil
+		return authwrapper.NewAuthorizedSubResourceStorage(&invitationResender{
+			client:      c,
+			handoff:     invitationtoken.Handoff{Client: c, Namespace: tokenHandoffNS},
+			validFor:    validFor,
+			maxValidFor: maxValidFor,
+		}, invitationRBACID, "resend", loopback.GetAuthorizer()), nil
 	}
 }
 
+// invitationRBACID is the resource used to authorize requests to invitations
+var invitationRBACID = metav1.GroupVersionResource{
+	Group:    "rbac.appuio.io",
+	Version:  "v1",
+	Resource: (&userv1.Invitation{}).GetGroupVersionResource().Resource,
+}
+
 // NewInvitationStorage returns a new storage provider with RBAC authentication for BillingEntities
-func NewInvitationStorage(backingNS string) restbuilder.ResourceHandlerProvider {
+// The token of a new invitation is only returned to its creator and handed off through secrets in the token hand-off namespace
+// to the controller sending the invitation email. Only the hash of the token is stored.
+// Invitations are valid for validFor unless they request a validity, requested validities are bounded by maxValidFor.
+// If an encryption keys file or an encryption keys secret given as `<namespace>/<name>` is set, invitations are encrypted at rest.
+// The encryption keys secret must not be in the backing namespace, access to the encrypted secrets must not give access to the keys.
+func NewInvitationStorage(backingNS, encryptionKeysFile, encryptionKeysSecret, tokenHandoffNS string, validFor, maxValidFor time.Duration) restbuilder.ResourceHandlerProvider {
 	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
 		c, err := buildClient()
 		if err != nil {
 			return nil, err
 		}
 
-		stor, err := secretstorage.NewStorage(&userv1.Invitation{}, c, backingNS)
+		enc, err := loadEncryption(c, backingNS, encryptionKeysFile, encryptionKeysSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		var stor secretstorage.ScopedStandardStorage
+		if enc != nil {
+			stor, err = secretstorage.NewEncryptedStorage(&userv1.Invitation{}, c, backingNS, enc)
+		} else {
+			stor, err = secretstorage.NewStorage(&userv1.Invitation{}, c, backingNS)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -49,12 +140,14 @@ func NewInvitationStorage(backingNS string) restbuilder.ResourceHandlerProvider
 			ScopedStandardStorage: stor,
 			client:                c,
 		}
+		stor = &invitationTokenIssuer{
+			ScopedStandardStorage: stor,
+			handoff:               invitationtoken.Handoff{Client: c, Namespace: tokenHandoffNS},
+			validFor:              validFor,
+			maxValidFor:           maxValidFor,
+		}
 
-		astor, err := authwrapper.NewAuthorizedStorage(stor, metav1.GroupVersionResource{
-			Group:    "rbac.appuio.io",
-			Version:  "v1",
-			Resource: (&userv1.Invitation{}).GetGroupVersionResource().Resource,
-		}, loopback.GetAuthorizer())
+		astor, err := authwrapper.NewAuthorizedStorage(stor, invitationRBACID, loopback.GetAuthorizer())
 		if err != nil {
 			return nil, err
 		}
@@ -63,6 +156,35 @@ func NewInvitationStorage(backingNS string) restbuilder.ResourceHandlerProvider
 	}
 }
 
+// loadEncryption loads the encryption keys from the given file or secret.
+// The secret is given as `<namespace>/<name>` and must not be in the backing namespace.
+// Returns nil if neither is set.
+func loadEncryption(c client.Reader, backingNS, keysFile, keysSecret string) (*secretstorage.Encryption, error) {
+	var keys []secretstorage.Key
+	var err error
+	switch {
+	case keysFile != "" && keysSecret != "":
+		return nil, errors.New("only one of encryption keys file and encryption keys secret can be set")
+	case keysFile != "":
+		keys, err = secretstorage.LoadKeysFromFile(keysFile)
+	case keysSecret != "":
+		ns, name, ok := strings.Cut(keysSecret, "/")
+		if !ok || ns == "" || name == "" {
+			return nil, fmt.Errorf("encryption keys secret %q must be given as <namespace>/<name>", keysSecret)
+		}
+		if ns == backingNS {
+			return nil, fmt.Errorf("encryption keys secret %q must not be in the backing namespace %q", keysSecret, backingNS)
+		}
+		keys, err = secretstorage.LoadKeysFromSecret(context.Background(), c, client.ObjectKey{Namespace: ns, Name: name})
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secretstorage.NewEncryption(keys...)
+}
+
 func buildClient() (client.WithWatch, error) {
 	c, err := client.NewWithWatch(loopback.GetLoopbackMasterClientConfig(), client.Options{})
 	if err != nil {
@@ -75,6 +197,12 @@ func buildClient() (client.WithWatch, error) {
 	if err = rbacv1.AddToScheme(c.Scheme()); err != nil {
 		return nil, err
 	}
+	if err = controlv1.AddToScheme(c.Scheme()); err != nil {
+		return nil, err
+	}
+	if err = billingv1.AddToScheme(c.Scheme()); err != nil {
+		return nil, err
+	}
 
 	return c, nil
 }
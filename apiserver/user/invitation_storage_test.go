@@ -0,0 +1,83 @@
+package user
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/apiserver/secretstorage"
+)
+
+func Test_loadEncryption_Secret(t *testing.T) {
+	keys := []byte("k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	c := prepareTest(t,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "keys-ns"},
+			Data:       map[string][]byte{secretstorage.KeysSecretDataKey: keys},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "invitations"},
+			Data:       map[string][]byte{secretstorage.KeysSecretDataKey: keys},
+		},
+	)
+
+	enc, err := loadEncryption(c, "invitations", "", "keys-ns/keys")
+	require.NoError(t, err)
+	assert.NotNil(t, enc)
+
+	_, err = loadEncryption(c, "invitations", "", "invitations/keys")
+	assert.ErrorContains(t, err, "must not be in the backing namespace")
+
+	_, err = loadEncryption(c, "invitations", "", "keys")
+	assert.ErrorContains(t, err, "<namespace>/<name>")
+
+	enc, err = loadEncryption(c, "invitations", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, enc)
+}
+
+func Test_InvitationStorage_RedeemedByFieldSelector(t *testing.T) {
+	c := prepareTest(t)
+	stor, err := secretstorage.NewStorage(&userv1.Invitation{}, c, "invitations")
+	require.NoError(t, err)
+
+	for _, inv := range []*userv1.Invitation{
+		{ObjectMeta: metav1.ObjectMeta{Name: "single"}, Status: userv1.InvitationStatus{RedeemedBy: "alice"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "multi"}, Status: userv1.InvitationStatus{Redeemers: []userv1.Redeemer{{Username: "bob"}, {Username: "carol"}}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "pending"}},
+	} {
+		_, err := stor.Create(context.Background(), inv.DeepCopy(), nil, &metav1.CreateOptions{})
+		require.NoError(t, err)
+		_, _, err = stor.Update(
+			request.WithRequestInfo(request.NewContext(), &request.RequestInfo{Subresource: "status"}),
+			inv.Name, rest.DefaultUpdatedObjectInfo(inv), nil, nil, false, &metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	listNames := func(sel fields.Selector) []string {
+		t.Helper()
+		list, err := stor.List(context.Background(), &metainternalversion.ListOptions{FieldSelector: sel})
+		require.NoError(t, err)
+		names := []string{}
+		for _, inv := range list.(*userv1.InvitationList).Items {
+			names = append(names, inv.Name)
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{"single"}, listNames(fields.OneTermEqualSelector("status.redeemedBy", "alice")))
+	assert.ElementsMatch(t, []string{"multi"}, listNames(fields.OneTermEqualSelector("status.redeemedBy", "carol")))
+	assert.ElementsMatch(t, []string{"single", "pending"}, listNames(fields.OneTermNotEqualSelector("status.redeemedBy", "bob")))
+	assert.ElementsMatch(t, []string{"pending"}, listNames(fields.OneTermEqualSelector("status.redeemedBy", "")))
+}
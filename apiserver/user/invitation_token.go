@@ -0,0 +1,163 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/apiserver/secretstorage"
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+// invitationTokenIssuer is a wrapper around the Invitation storage issuing the token of new invitations.
+// Only the hash of the token is persisted. The plaintext token is returned in the response to the creation of the invitation
+// and handed off to the controller sending the invitation email.
+// The token can't be changed through updates, it can only be rotated through the `invitations/resend` subresource.
+type invitationTokenIssuer struct {
+	secretstorage.ScopedStandardStorage
+	handoff tokenHandoff
+
+	// validFor is the validity of invitations not requesting a validity.
+	validFor time.Duration
+	// maxValidFor bounds the validity requested by invitations.
+	// Zero allows any validity.
+	maxValidFor time.Duration
+}
+
+// tokenHandoff passes the plaintext token of an invitation to the controller sending the invitation email.
+type tokenHandoff interface {
+	Put(ctx context.Context, invitation, token string) error
+}
+
+var _ tokenHandoff = invitationtoken.Handoff{}
+
+// Create passes the object to the wrapped storage and issues the token of the created invitation.
+// The returned invitation contains the plaintext token, it is never returned again.
+func (s *invitationTokenIssuer) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	createdObj, err := s.ScopedStandardStorage.Create(ctx, obj, createValidation, opts)
+	if err != nil || len(opts.DryRun) > 0 {
+		return createdObj, err
+	}
+	inv, ok := createdObj.(*userv1.Invitation)
+	if !ok {
+		return createdObj, fmt.Errorf("not an Invitation: %#v", createdObj)
+	}
+
+	issued, err := s.issue(ctx, inv)
+	if err != nil {
+		_, _, rollbackErr := s.ScopedStandardStorage.Delete(ctx, inv.Name, nil, &metav1.DeleteOptions{})
+		return createdObj, multierr.Append(err, rollbackErr)
+	}
+	return issued, nil
+}
+
+// issue generates the token of the invitation and persists its hash and the validity of the invitation.
+// The token is handed off before the hash is persisted, the controller sending the invitation email only acts on invitations with a hash.
+func (s *invitationTokenIssuer) issue(ctx context.Context, inv *userv1.Invitation) (*userv1.Invitation, error) {
+	token, hash, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+	if inv.Spec.Email != "" {
+		if err := s.handoff.Put(ctx, inv.Name, token); err != nil {
+			return nil, err
+		}
+	}
+
+	ri, ok := request.RequestInfoFrom(ctx)
+	if !ok {
+		return nil, errors.New("no RequestInfo found in the context")
+	}
+	statusInfo := *ri
+	statusInfo.Subresource = "status"
+
+	inv = inv.DeepCopy()
+	inv.Status.TokenHash = hash
+	inv.Status.ValidUntil = metav1.NewTime(invitationValidUntil(inv.Spec, time.Now(), s.validFor, s.maxValidFor))
+	updated, _, err := s.ScopedStandardStorage.Update(request.WithRequestInfo(ctx, &statusInfo), inv.Name, rest.DefaultUpdatedObjectInfo(inv), nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue token: %w", err)
+	}
+	issued := updated.(*userv1.Invitation).DeepCopy()
+	issued.Status.Token = token
+	return issued, nil
+}
+
+// Update passes the update to the wrapped storage and rejects changes to the token.
+func (s *invitationTokenIssuer) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo,
+	createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc,
+	forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	return s.ScopedStandardStorage.Update(ctx, name, rest.WrapUpdatedObjectInfo(objInfo, rejectTokenChanges), createValidation, updateValidation, forceAllowCreate, options)
+}
+
+// rejectTokenChanges rejects updates setting the plaintext token or changing the token hash.
+// The hash can only be changed by the API server itself, which rotates tokens through the `invitations/resend` subresource.
+// The plaintext token of invitations created before tokens were hashed can be kept or removed.
+func rejectTokenChanges(ctx context.Context, newObj, oldObj runtime.Object) (runtime.Object, error) {
+	newInv, ok := newObj.(*userv1.Invitation)
+	if !ok {
+		return newObj, nil
+	}
+	oldInv, ok := oldObj.(*userv1.Invitation)
+	if !ok || oldInv == nil {
+		return newObj, nil
+	}
+
+	var errs field.ErrorList
+	if newInv.Status.Token != "" && newInv.Status.Token != oldInv.Status.Token {
+		errs = append(errs, field.Forbidden(field.NewPath("status", "token"), "the token is never stored"))
+	}
+	if newInv.Status.TokenHash != oldInv.Status.TokenHash && !isAPIServer(ctx) {
+		errs = append(errs, field.Forbidden(field.NewPath("status", "tokenHash"), "the token can only be rotated through the invitations/resend subresource"))
+	}
+	if len(errs) > 0 {
+		return nil, apierrors.NewInvalid(userv1.GroupVersion.WithKind("Invitation").GroupKind(), newInv.Name, errs)
+	}
+	return newObj, nil
+}
+
+// isAPIServer returns true if the request is made by the API server itself through its loopback client.
+func isAPIServer(ctx context.Context) bool {
+	u, ok := request.UserFrom(ctx)
+	return ok && u.GetName() == user.APIServerUser
+}
+
+// generateInvitationToken returns a new token and its hash.
+func generateInvitationToken() (token, hash string, err error) {
+	token, err = invitationtoken.Generate()
+	if err != nil {
+		return "", "", err
+	}
+	hash, err = invitationtoken.Hash(token)
+	if err != nil {
+		return "", "", err
+	}
+	return token, hash, nil
+}
+
+// invitationValidUntil returns the expiry of an invitation whose token is issued now.
+// The validity requested in the spec is honored up to maxValidFor.
+func invitationValidUntil(spec userv1.InvitationSpec, now time.Time, validFor, maxValidFor time.Duration) time.Time {
+	validUntil := now.Add(validFor)
+	if spec.ValidFor != nil {
+		validUntil = now.Add(spec.ValidFor.Duration)
+	}
+	if spec.ValidUntil != nil {
+		validUntil = spec.ValidUntil.Time
+	}
+	if max := now.Add(maxValidFor); maxValidFor > 0 && validUntil.After(max) {
+		validUntil = max
+	}
+	return validUntil
+}
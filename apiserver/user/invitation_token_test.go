@@ -0,0 +1,215 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+func Test_invitationTokenIssuer_Create(t *testing.T) {
+	ctrl, store := newStore(t)
+	defer ctrl.Finish()
+
+	inv := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{Name: "inv"},
+		Spec:       userv1.InvitationSpec{Email: "subject@example.com"},
+	}
+	store.EXPECT().
+		Create(gomock.Any(), inv, gomock.Any(), gomock.Any()).
+		Return(inv, nil)
+	var persisted *userv1.Invitation
+	store.EXPECT().
+		Update(gomock.Any(), "inv", gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, _ rest.ValidateObjectFunc, _ rest.ValidateObjectUpdateFunc, _ bool, _ *metav1.UpdateOptions) (runtime.Object, bool, error) {
+			ri, _ := request.RequestInfoFrom(ctx)
+			assert.Equal(t, "status", ri.Subresource, "token must be issued through the status subresource")
+			obj, err := objInfo.UpdatedObject(ctx, inv)
+			if err != nil {
+				return nil, false, err
+			}
+			persisted = obj.(*userv1.Invitation)
+			return persisted, false, nil
+		})
+
+	handoff := fakeHandoff{}
+	subject := &invitationTokenIssuer{
+		ScopedStandardStorage: clusterScopedStorage{store},
+		handoff:               handoff,
+		validFor:              time.Hour,
+	}
+
+	obj, err := subject.Create(ctxWithInfo("create", "inv", "owner"), inv, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	token := obj.(*userv1.Invitation).Status.Token
+	require.NotEmpty(t, token, "the token must be returned to the creator")
+	assert.Equal(t, token, handoff["inv"], "the token must be handed off for the invitation email")
+	assert.Empty(t, persisted.Status.Token, "the token must not be stored")
+	assert.True(t, invitationtoken.Verify(persisted.Status.TokenHash, token))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), persisted.Status.ValidUntil.Time, time.Minute)
+}
+
+func Test_invitationTokenIssuer_Create_Rollback(t *testing.T) {
+	ctrl, store := newStore(t)
+	defer ctrl.Finish()
+
+	inv := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{Name: "inv"},
+		Spec:       userv1.InvitationSpec{Email: "subject@example.com"},
+	}
+	store.EXPECT().
+		Create(gomock.Any(), inv, gomock.Any(), gomock.Any()).
+		Return(inv, nil)
+	store.EXPECT().
+		Delete(gomock.Any(), "inv", gomock.Any(), gomock.Any()).
+		Return(inv, true, nil)
+
+	subject := &invitationTokenIssuer{
+		ScopedStandardStorage: clusterScopedStorage{store},
+		handoff:               failingHandoff{},
+	}
+
+	_, err := subject.Create(ctxWithInfo("create", "inv", "owner"), inv, nil, &metav1.CreateOptions{})
+	require.Error(t, err)
+}
+
+func Test_rejectTokenChanges(t *testing.T) {
+	old := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{Name: "inv"},
+		Status:     userv1.InvitationStatus{TokenHash: "hash"},
+	}
+	legacy := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{Name: "inv"},
+		Status:     userv1.InvitationStatus{Token: "legacy"},
+	}
+	userCtx := request.WithUser(context.Background(), &user.DefaultInfo{Name: "admin"})
+	apiServerCtx := request.WithUser(context.Background(), &user.DefaultInfo{Name: user.APIServerUser})
+
+	tests := map[string]struct {
+		ctx      context.Context
+		old      *userv1.Invitation
+		status   userv1.InvitationStatus
+		rejected bool
+	}{
+		"unchanged": {
+			ctx:    userCtx,
+			old:    old,
+			status: userv1.InvitationStatus{TokenHash: "hash"},
+		},
+		"set token": {
+			ctx:      userCtx,
+			old:      old,
+			status:   userv1.InvitationStatus{Token: "token", TokenHash: "hash"},
+			rejected: true,
+		},
+		"change hash": {
+			ctx:      userCtx,
+			old:      old,
+			status:   userv1.InvitationStatus{TokenHash: "other"},
+			rejected: true,
+		},
+		"remove hash": {
+			ctx:      userCtx,
+			old:      old,
+			status:   userv1.InvitationStatus{},
+			rejected: true,
+		},
+		"change hash by API server": {
+			ctx:    apiServerCtx,
+			old:    old,
+			status: userv1.InvitationStatus{TokenHash: "other"},
+		},
+		"set token by API server": {
+			ctx:      apiServerCtx,
+			old:      old,
+			status:   userv1.InvitationStatus{Token: "token", TokenHash: "hash"},
+			rejected: true,
+		},
+		"keep legacy token": {
+			ctx:    userCtx,
+			old:    legacy,
+			status: userv1.InvitationStatus{Token: "legacy"},
+		},
+		"change legacy token": {
+			ctx:      userCtx,
+			old:      legacy,
+			status:   userv1.InvitationStatus{Token: "changed"},
+			rejected: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			updated := tc.old.DeepCopy()
+			updated.Status = tc.status
+			_, err := rejectTokenChanges(tc.ctx, updated, tc.old)
+			if tc.rejected {
+				assert.True(t, apierrors.IsInvalid(err), "expected invalid, got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_invitationValidUntil(t *testing.T) {
+	now := time.Now()
+	validUntil := metav1.NewTime(now.Add(48 * time.Hour))
+	farValidUntil := metav1.NewTime(now.Add(1000 * time.Hour))
+
+	tests := map[string]struct {
+		spec     userv1.InvitationSpec
+		expected time.Time
+	}{
+		"default": {
+			expected: now.Add(time.Hour),
+		},
+		"validFor": {
+			spec:     userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: 10 * time.Minute}},
+			expected: now.Add(10 * time.Minute),
+		},
+		"validFor bounded by maximum": {
+			spec:     userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: 1000 * time.Hour}},
+			expected: now.Add(100 * time.Hour),
+		},
+		"validUntil": {
+			spec:     userv1.InvitationSpec{ValidUntil: &validUntil},
+			expected: validUntil.Time,
+		},
+		"validUntil bounded by maximum": {
+			spec:     userv1.InvitationSpec{ValidUntil: &farValidUntil},
+			expected: now.Add(100 * time.Hour),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, invitationValidUntil(tc.spec, now, time.Hour, 100*time.Hour))
+		})
+	}
+}
+
+// fakeHandoff records handed off tokens by invitation name.
+type fakeHandoff map[string]string
+
+func (h fakeHandoff) Put(_ context.Context, invitation, token string) error {
+	h[invitation] = token
+	return nil
+}
+
+type failingHandoff struct{}
+
+func (failingHandoff) Put(context.Context, string, string) error {
+	return errors.New("failed")
+}
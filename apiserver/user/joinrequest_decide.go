@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/apiserver/authwrapper"
+)
+
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+
+var _ authwrapper.SubResourceStorage = &joinRequestApprover{}
+var _ authwrapper.SubResourceStorage = &joinRequestRejecter{}
+
+// joinRequestApprover implements the `joinrequests/approve` subresource.
+type joinRequestApprover struct {
+	client client.Client
+}
+
+func (r joinRequestApprover) NamespaceScoped() bool {
+	return true
+}
+
+func (r joinRequestApprover) New() runtime.Object {
+	return &userv1.JoinRequestApproveRequest{}
+}
+
+func (r joinRequestApprover) Destroy() {}
+
+// Create approves the join request with the given name, it accepts `JoinRequestApproveRequest`.
+// The join request is marked with a true `Approved` condition, a controller then adds the requester to the organization.
+// Approving an approved join request is a no-op, approving a rejected join request is rejected.
+func (r *joinRequestApprover) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	ar, ok := obj.(*userv1.JoinRequestApproveRequest)
+	if !ok {
+		return nil, fmt.Errorf("not a JoinRequestApproveRequest: %#v", obj)
+	}
+
+	message := "Approved"
+	if u, ok := request.UserFrom(ctx); ok {
+		message = fmt.Sprintf("Approved by %q", u.GetName())
+	}
+
+	if err := decideJoinRequest(ctx, r.client, name, metav1.ConditionTrue, message, opts); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// joinRequestRejecter implements the `joinrequests/reject` subresource.
+type joinRequestRejecter struct {
+	client client.Client
+}
+
+func (r joinRequestRejecter) NamespaceScoped() bool {
+	return true
+}
+
+func (r joinRequestRejecter) New() runtime.Object {
+	return &userv1.JoinRequestRejectRequest{}
+}
+
+func (r joinRequestRejecter) Destroy() {}
+
+// Create rejects the join request with the given name, it accepts `JoinRequestRejectRequest`.
+// The join request is marked with a false `Approved` condition.
+// Rejecting a rejected join request is a no-op, rejecting an approved join request is rejected.
+func (r *joinRequestRejecter) Create(ctx context.Context, name string, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	rr, ok := obj.(*userv1.JoinRequestRejectRequest)
+	if !ok {
+		return nil, fmt.Errorf("not a JoinRequestRejectRequest: %#v", obj)
+	}
+
+	message := "Rejected"
+	if u, ok := request.UserFrom(ctx); ok {
+		message = fmt.Sprintf("Rejected by %q", u.GetName())
+	}
+	if rr.Reason != "" {
+		message = fmt.Sprintf("%s: %s", message, rr.Reason)
+	}
+
+	if err := decideJoinRequest(ctx, r.client, name, metav1.ConditionFalse, message, opts); err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+// decideJoinRequest sets the `Approved` condition of the join request in the namespace of the request.
+// Repeating a decision is a no-op, changing a decision is rejected.
+func decideJoinRequest(ctx context.Context, c client.Client, name string, decision metav1.ConditionStatus, message string, opts *metav1.CreateOptions) error {
+	reason := userv1.ConditionApproved
+	if decision == metav1.ConditionFalse {
+		reason = userv1.ConditionReasonRejected
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		jr := &userv1.JoinRequest{}
+		if err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: request.NamespaceValue(ctx)}, jr); err != nil {
+			return err
+		}
+		if cond := apimeta.FindStatusCondition(jr.Status.Conditions, userv1.ConditionApproved); cond != nil {
+			if cond.Status == decision {
+				return nil
+			}
+			if jr.IsApproved() {
+				return apierrors.NewBadRequest("join request has already been approved")
+			}
+			return apierrors.NewBadRequest("join request has already been rejected")
+		}
+
+		apimeta.SetStatusCondition(&jr.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionApproved,
+			Status:  decision,
+			Reason:  reason,
+			Message: message,
+		})
+		return c.Status().Update(ctx, jr, &client.SubResourceUpdateOptions{UpdateOptions: client.UpdateOptions{DryRun: opts.DryRun}})
+	})
+}
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+)
+
+func TestJoinRequestApprover_Create(t *testing.T) {
+	jr := pendingJoinRequest()
+	c := prepareTest(t, jr)
+	subject := joinRequestApprover{client: c}
+
+	ctx := request.WithUser(request.WithNamespace(context.Background(), jr.Namespace), &user.DefaultInfo{Name: "admin"})
+	_, err := subject.Create(ctx, jr.Name, &userv1.JoinRequestApproveRequest{}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(jr), jr))
+	assert.True(t, jr.IsApproved())
+	cond := apimeta.FindStatusCondition(jr.Status.Conditions, userv1.ConditionApproved)
+	require.NotNil(t, cond)
+	assert.Equal(t, `Approved by "admin"`, cond.Message)
+
+	_, err = subject.Create(ctx, jr.Name, &userv1.JoinRequestApproveRequest{}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "approving an approved join request should be a no-op")
+
+	_, err = (&joinRequestRejecter{client: c}).Create(ctx, jr.Name, &userv1.JoinRequestRejectRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+}
+
+func TestJoinRequestRejecter_Create(t *testing.T) {
+	jr := pendingJoinRequest()
+	c := prepareTest(t, jr)
+	subject := joinRequestRejecter{client: c}
+
+	ctx := request.WithUser(request.WithNamespace(context.Background(), jr.Namespace), &user.DefaultInfo{Name: "admin"})
+	_, err := subject.Create(ctx, jr.Name, &userv1.JoinRequestRejectRequest{Reason: "unknown user"}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(jr), jr))
+	assert.True(t, jr.IsRejected())
+	cond := apimeta.FindStatusCondition(jr.Status.Conditions, userv1.ConditionApproved)
+	require.NotNil(t, cond)
+	assert.Equal(t, userv1.ConditionReasonRejected, cond.Reason)
+	assert.Equal(t, `Rejected by "admin": unknown user`, cond.Message)
+
+	_, err = subject.Create(ctx, jr.Name, &userv1.JoinRequestRejectRequest{}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "rejecting a rejected join request should be a no-op")
+
+	_, err = (&joinRequestApprover{client: c}).Create(ctx, jr.Name, &userv1.JoinRequestApproveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsBadRequest(err), "expected bad request, got %v", err)
+}
+
+func TestJoinRequestApprover_Create_NotFound(t *testing.T) {
+	c := prepareTest(t, pendingJoinRequest())
+	subject := joinRequestApprover{client: c}
+
+	_, err := subject.Create(request.WithNamespace(context.Background(), "other-org"), "join", &userv1.JoinRequestApproveRequest{}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "expected not found, got %v", err)
+}
+
+func pendingJoinRequest() *userv1.JoinRequest {
+	return &userv1.JoinRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "join",
+			Namespace: "acme-corp",
+		},
+		Status: userv1.JoinRequestStatus{
+			Requester: "appuio#requester",
+		},
+	}
+}
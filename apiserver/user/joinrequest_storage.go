@@ -0,0 +1,280 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	genericregistry "k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/klog/v2"
+	restbuilder "sigs.k8s.io/apiserver-runtime/pkg/builder/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/util/loopback"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/apiserver/authwrapper"
+	"github.com/appuio/control-api/apiserver/secretstorage"
+	"github.com/appuio/control-api/controllers/targetref"
+)
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;delete;patch;update;edit
+// +kubebuilder:rbac:groups=rbac.appuio.io;user.appuio.io,resources=joinrequests,verbs=get;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+// +kubebuilder:rbac:groups="appuio.io",resources=organizationmembers,verbs=get
+
+// joinRequestRBACID is the resource used to authorize requests to join requests
+var joinRequestRBACID = metav1.GroupVersionResource{
+	Group:    "rbac.appuio.io",
+	Version:  "v1",
+	Resource: (&userv1.JoinRequest{}).GetGroupVersionResource().Resource,
+}
+
+// NewJoinRequestStorage returns a new storage provider with RBAC authentication for JoinRequests.
+// Join requests are stored as secrets in the backing namespace.
+func NewJoinRequestStorage(backingNS, usernamePrefix string) restbuilder.ResourceHandlerProvider {
+	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		c, err := buildClient()
+		if err != nil {
+			return nil, err
+		}
+
+		stor, err := secretstorage.NewStorage(&userv1.JoinRequest{}, c, backingNS)
+		if err != nil {
+			return nil, err
+		}
+
+		return authwrapper.NewAuthorizedStorage(&joinRequestStorage{
+			ScopedStandardStorage: stor,
+			client:                c,
+			usernamePrefix:        usernamePrefix,
+		}, joinRequestRBACID, loopback.GetAuthorizer())
+	}
+}
+
+// NewJoinRequestApproveStorage creates a new REST storage for the `joinrequests/approve` subresource.
+// Requests are authorized with the `approve` verb on the join request.
+func NewJoinRequestApproveStorage() restbuilder.ResourceHandlerProvider {
+	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		c, err := buildClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return authwrapper.NewAuthorizedSubResourceStorage(&joinRequestApprover{client: c}, joinRequestRBACID, "approve", loopback.GetAuthorizer()), nil
+	}
+}
+
+// NewJoinRequestRejectStorage creates a new REST storage for the `joinrequests/reject` subresource.
+// Requests are authorized with the `reject` verb on the join request.
+func NewJoinRequestRejectStorage() restbuilder.ResourceHandlerProvider {
+	return func(s *runtime.Scheme, g genericregistry.RESTOptionsGetter) (rest.Storage, error) {
+		c, err := buildClient()
+		if err != nil {
+			return nil, err
+		}
+
+		return authwrapper.NewAuthorizedSubResourceStorage(&joinRequestRejecter{client: c}, joinRequestRBACID, "reject", loopback.GetAuthorizer()), nil
+	}
+}
+
+// joinRequestStorage is a wrapper around the JoinRequest storage.
+// It records the requesting user and creates a Role and RoleBinding allowing the requester to see and withdraw the join request.
+type joinRequestStorage struct {
+	secretstorage.ScopedStandardStorage
+	client client.Client
+
+	usernamePrefix string
+}
+
+// Create validates the join request, passes it to the wrapped storage, and records the requesting user in the status.
+// Only users can request to join an organization they are not yet a member of, and only one undecided join request per user and organization is allowed.
+// A Role and RoleBinding are created in the organization namespace allowing the requester to get and delete the join request.
+func (s *joinRequestStorage) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, opts *metav1.CreateOptions) (runtime.Object, error) {
+	jr, ok := obj.(*userv1.JoinRequest)
+	if !ok {
+		return nil, fmt.Errorf("not a JoinRequest: %#v", obj)
+	}
+
+	user, ok := userFrom(ctx, s.usernamePrefix)
+	if !ok {
+		return nil, apierrors.NewForbidden(userv1.GroupVersion.WithResource("joinrequests").GroupResource(), jr.Name, errors.New("only users can request to join an organization"))
+	}
+	if err := s.validateOrganization(ctx, request.NamespaceValue(ctx), strings.TrimPrefix(user.GetName(), s.usernamePrefix)); err != nil {
+		return nil, err
+	}
+	if err := s.validateNoPendingRequest(ctx, jr.Name, user.GetName()); err != nil {
+		return nil, err
+	}
+
+	created, err := s.ScopedStandardStorage.Create(ctx, jr, createValidation, opts)
+	if err != nil {
+		return created, err
+	}
+	jr = created.(*userv1.JoinRequest)
+
+	rollback := func() error {
+		_, _, err := s.ScopedStandardStorage.Delete(ctx, jr.Name, nil, &metav1.DeleteOptions{DryRun: opts.DryRun})
+		return err
+	}
+
+	jr.Status.Requester = user.GetName()
+	if len(opts.DryRun) == 0 {
+		updated, err := s.updateStatus(ctx, jr)
+		if err != nil {
+			return created, multierr.Append(err, rollback())
+		}
+		jr = updated
+	}
+
+	role, rolebinding := requesterRBAC(jr.Namespace, jr.Name, user.GetName())
+	if err := s.client.Create(ctx, role, &client.CreateOptions{DryRun: opts.DryRun}); err != nil {
+		return jr, multierr.Append(err, rollback())
+	}
+	if err := s.client.Create(ctx, rolebinding, &client.CreateOptions{DryRun: opts.DryRun}); err != nil {
+		roleRollbackErr := s.client.Delete(ctx, role, &client.DeleteOptions{DryRun: opts.DryRun})
+		return jr, multierr.Combine(err, rollback(), roleRollbackErr)
+	}
+
+	return jr, nil
+}
+
+// validateOrganization returns an error if the namespace is not an organization or the user is already a member of it.
+// Missing namespaces and namespaces which are not organizations return the same error, so join requests can't be used to discover namespaces.
+func (s *joinRequestStorage) validateOrganization(ctx context.Context, namespace, username string) error {
+	notFound := apierrors.NewNotFound(orgv1.GroupVersion.WithResource("organizations").GroupResource(), namespace)
+	ns := corev1.Namespace{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: namespace}, &ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return notFound
+		}
+		return err
+	}
+	if orgv1.NewOrganizationFromNS(&ns) == nil {
+		return notFound
+	}
+
+	members, err := targetref.GetTarget(ctx, s.client, userv1.TargetRef{
+		APIGroup:  controlv1.GroupVersion.Group,
+		Kind:      "OrganizationMembers",
+		Namespace: namespace,
+		Name:      "members",
+	})
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	a, err := targetref.NewUserAccessor(members)
+	if err != nil {
+		return err
+	}
+	if a.HasUser(s.usernamePrefix, username) {
+		return apierrors.NewBadRequest(fmt.Sprintf("user %q is already a member of organization %q", username, namespace))
+	}
+	return nil
+}
+
+// validateNoPendingRequest returns a Conflict error if the user already has an undecided join request for the organization of the request.
+func (s *joinRequestStorage) validateNoPendingRequest(ctx context.Context, name, user string) error {
+	list, err := s.ScopedStandardStorage.List(ctx, &metainternalversion.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("status.requester", user),
+	})
+	if err != nil {
+		return err
+	}
+	for _, jr := range list.(*userv1.JoinRequestList).Items {
+		if !jr.IsDecided() {
+			return apierrors.NewConflict(userv1.GroupVersion.WithResource("joinrequests").GroupResource(), name, fmt.Errorf("join request %q is still pending", jr.Name))
+		}
+	}
+	return nil
+}
+
+// updateStatus updates the status of the join request through the status subresource of the wrapped storage.
+func (s *joinRequestStorage) updateStatus(ctx context.Context, jr *userv1.JoinRequest) (*userv1.JoinRequest, error) {
+	ri, ok := request.RequestInfoFrom(ctx)
+	if !ok {
+		return nil, errors.New("no RequestInfo found in the context")
+	}
+	statusInfo := *ri
+	statusInfo.Subresource = "status"
+	updated, _, err := s.ScopedStandardStorage.Update(request.WithRequestInfo(ctx, &statusInfo), jr.Name, rest.DefaultUpdatedObjectInfo(jr), nil, nil, false, &metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record requester: %w", err)
+	}
+	return updated.(*userv1.JoinRequest), nil
+}
+
+// Delete passes the object to the wrapped storage and deletes the Role and RoleBinding of the requester.
+func (s *joinRequestStorage) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, opts *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	deletedObj, im, err := s.ScopedStandardStorage.Delete(ctx, name, deleteValidation, opts)
+	if err != nil {
+		return deletedObj, im, err
+	}
+
+	role, rolebinding := requesterRBAC(request.NamespaceValue(ctx), name, "")
+	err1 := s.client.Delete(ctx, role, &client.DeleteOptions{DryRun: opts.DryRun})
+	err2 := s.client.Delete(ctx, rolebinding, &client.DeleteOptions{DryRun: opts.DryRun})
+	if err := multierr.Combine(client.IgnoreNotFound(err1), client.IgnoreNotFound(err2)); err != nil {
+		klog.FromContext(ctx).Error(err, "failed to clean up RBAC resources")
+	}
+
+	return deletedObj, im, nil
+}
+
+func (s *joinRequestStorage) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// requesterRBAC returns the Role and RoleBinding allowing the requester to get and delete the join request.
+func requesterRBAC(namespace, name, username string) (*rbacv1.Role, *rbacv1.RoleBinding) {
+	rolename := joinRequestRoleName(name)
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rolename,
+			Namespace: namespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups:     []string{"rbac.appuio.io", "user.appuio.io"},
+				Resources:     []string{"joinrequests"},
+				Verbs:         []string{"get", "delete"},
+				ResourceNames: []string{name},
+			},
+		},
+	}
+	rolebinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rolename,
+			Namespace: namespace,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:     "User",
+				APIGroup: "rbac.authorization.k8s.io",
+				Name:     username,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "Role",
+			APIGroup: "rbac.authorization.k8s.io",
+			Name:     rolename,
+		},
+	}
+	return role, rolebinding
+}
+
+func joinRequestRoleName(objName string) string {
+	return shortenedRBACName("joinrequests-", objName, "-requester")
+}
@@ -0,0 +1,187 @@
+package user
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/apiserver/secretstorage"
+)
+
+const joinRequestUsernamePrefix = "appuio#"
+
+func TestJoinRequestStorage_Create(t *testing.T) {
+	c := prepareTest(t, joinRequestOrganization()...)
+	subject := newJoinRequestStorage(t, c)
+
+	ctx := joinRequestCtx("acme-corp", joinRequestUsernamePrefix+"requester")
+	obj, err := subject.Create(ctx, &userv1.JoinRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "join"},
+		Spec:       userv1.JoinRequestSpec{Message: "let me in"},
+		Status:     userv1.JoinRequestStatus{Requester: "someone-else"},
+	}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+	jr := obj.(*userv1.JoinRequest)
+	assert.Equal(t, joinRequestUsernamePrefix+"requester", jr.Status.Requester)
+
+	stored, err := subject.Get(ctx, "join", &metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, joinRequestUsernamePrefix+"requester", stored.(*userv1.JoinRequest).Status.Requester, "requester should be persisted")
+	assert.Equal(t, "let me in", stored.(*userv1.JoinRequest).Spec.Message)
+
+	key := client.ObjectKey{Name: "joinrequests-join-requester", Namespace: "acme-corp"}
+	var role rbacv1.Role
+	require.NoError(t, c.Get(context.Background(), key, &role))
+	assert.Equal(t, []string{"join"}, role.Rules[0].ResourceNames)
+	var rb rbacv1.RoleBinding
+	require.NoError(t, c.Get(context.Background(), key, &rb))
+	assert.Equal(t, []rbacv1.Subject{{APIGroup: "rbac.authorization.k8s.io", Kind: "User", Name: joinRequestUsernamePrefix + "requester"}}, rb.Subjects)
+
+	_, _, err = subject.Delete(ctx, "join", nil, &metav1.DeleteOptions{})
+	require.NoError(t, err)
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), key, &role)))
+	assert.True(t, apierrors.IsNotFound(c.Get(context.Background(), key, &rb)))
+}
+
+func TestJoinRequestStorage_Create_Invalid(t *testing.T) {
+	tcs := map[string]struct {
+		namespace string
+		username  string
+		errCheck  func(error) bool
+	}{
+		"no organization": {
+			namespace: "default",
+			username:  joinRequestUsernamePrefix + "requester",
+			errCheck:  apierrors.IsNotFound,
+		},
+		"missing namespace": {
+			namespace: "missing",
+			username:  joinRequestUsernamePrefix + "requester",
+			errCheck:  apierrors.IsNotFound,
+		},
+		"already a member": {
+			namespace: "acme-corp",
+			username:  joinRequestUsernamePrefix + "member",
+			errCheck:  apierrors.IsBadRequest,
+		},
+		"not a user": {
+			namespace: "acme-corp",
+			username:  "system:serviceaccount:default:robot",
+			errCheck:  apierrors.IsForbidden,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			objs := append(joinRequestOrganization(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+			c := prepareTest(t, objs...)
+			subject := newJoinRequestStorage(t, c)
+
+			_, err := subject.Create(joinRequestCtx(tc.namespace, tc.username), &userv1.JoinRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "join"},
+			}, nil, &metav1.CreateOptions{})
+			assert.True(t, tc.errCheck(err), "unexpected error %v", err)
+
+			var roles rbacv1.RoleList
+			require.NoError(t, c.List(context.Background(), &roles))
+			assert.Empty(t, roles.Items)
+		})
+	}
+}
+
+func TestJoinRequestStorage_Create_NotFoundIndistinguishable(t *testing.T) {
+	objs := append(joinRequestOrganization(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}})
+	c := prepareTest(t, objs...)
+	subject := newJoinRequestStorage(t, c)
+
+	_, errNoOrg := subject.Create(joinRequestCtx("kube-system", joinRequestUsernamePrefix+"requester"), &userv1.JoinRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "join"},
+	}, nil, &metav1.CreateOptions{})
+	_, errMissing := subject.Create(joinRequestCtx("kube-system-missing", joinRequestUsernamePrefix+"requester"), &userv1.JoinRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "join"},
+	}, nil, &metav1.CreateOptions{})
+
+	require.Error(t, errNoOrg)
+	require.Error(t, errMissing)
+	assert.Equal(t,
+		strings.ReplaceAll(errNoOrg.Error(), "kube-system", "<ns>"),
+		strings.ReplaceAll(errMissing.Error(), "kube-system-missing", "<ns>"),
+		"missing namespaces and namespaces which are not organizations must not be distinguishable")
+}
+
+func TestJoinRequestStorage_Create_Pending(t *testing.T) {
+	c := prepareTest(t, joinRequestOrganization()...)
+	subject := newJoinRequestStorage(t, c)
+	ctx := joinRequestCtx("acme-corp", joinRequestUsernamePrefix+"requester")
+
+	_, err := subject.Create(ctx, &userv1.JoinRequest{ObjectMeta: metav1.ObjectMeta{Name: "join"}}, nil, &metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	_, err = subject.Create(ctx, &userv1.JoinRequest{ObjectMeta: metav1.ObjectMeta{Name: "join-again"}}, nil, &metav1.CreateOptions{})
+	assert.True(t, apierrors.IsConflict(err), "expected conflict for a second pending join request, got %v", err)
+
+	_, err = subject.Create(joinRequestCtx("acme-corp", joinRequestUsernamePrefix+"other"), &userv1.JoinRequest{ObjectMeta: metav1.ObjectMeta{Name: "join-other"}}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "other users must not be affected")
+
+	obj, err := subject.Get(ctx, "join", &metav1.GetOptions{})
+	require.NoError(t, err)
+	jr := obj.(*userv1.JoinRequest)
+	apimeta.SetStatusCondition(&jr.Status.Conditions, metav1.Condition{Type: userv1.ConditionApproved, Status: metav1.ConditionFalse, Reason: userv1.ConditionReasonRejected})
+	_, err = subject.updateStatus(ctx, jr)
+	require.NoError(t, err)
+
+	_, err = subject.Create(ctx, &userv1.JoinRequest{ObjectMeta: metav1.ObjectMeta{Name: "join-again"}}, nil, &metav1.CreateOptions{})
+	assert.NoError(t, err, "decided join requests must not block new join requests")
+}
+
+func newJoinRequestStorage(t *testing.T, c client.WithWatch) *joinRequestStorage {
+	stor, err := secretstorage.NewStorage(&userv1.JoinRequest{}, c, "default")
+	require.NoError(t, err)
+	return &joinRequestStorage{
+		ScopedStandardStorage: stor,
+		client:                c,
+		usernamePrefix:        joinRequestUsernamePrefix,
+	}
+}
+
+func joinRequestCtx(namespace, username string) context.Context {
+	ctx := request.WithNamespace(request.NewContext(), namespace)
+	ctx = request.WithRequestInfo(ctx, &request.RequestInfo{
+		APIGroup:   userv1.GroupVersion.Group,
+		APIVersion: userv1.GroupVersion.Version,
+		Resource:   "joinrequests",
+		Namespace:  namespace,
+	})
+	return request.WithUser(ctx, &user.DefaultInfo{Name: username})
+}
+
+func joinRequestOrganization() []client.Object {
+	return []client.Object{
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "acme-corp",
+				Labels: map[string]string{orgv1.TypeKey: orgv1.OrgType},
+			},
+		},
+		&controlv1.OrganizationMembers{
+			ObjectMeta: metav1.ObjectMeta{Name: "members", Namespace: "acme-corp"},
+			Spec: controlv1.OrganizationMembersSpec{
+				UserRefs: []controlv1.OrganizationMemberRef{{Name: "member"}},
+			},
+		},
+	}
+}
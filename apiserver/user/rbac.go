@@ -22,7 +22,7 @@ import (
 )
 
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;delete;patch;update;edit
-// +kubebuilder:rbac:groups=rbac.appuio.io;user.appuio.io,resources=invitations,verbs=get;edit;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.appuio.io;user.appuio.io,resources=invitations,verbs=get;edit;update;patch;delete;revoke;resend
 
 // rbacCreatorIsOwner is a wrapper around the Invitation storage that creates a ClusterRole and ClusterRoleBinding
 // to make the creator of the Invitation the owner of the Invitation.
@@ -60,7 +60,7 @@ func (c *rbacCreatorIsOwner) Create(ctx context.Context, obj runtime.Object, cre
 			{
 				APIGroups:     []string{"rbac.appuio.io", "user.appuio.io"},
 				Resources:     []string{"invitations"},
-				Verbs:         []string{"get", "edit", "update", "patch", "delete"},
+				Verbs:         []string{"get", "edit", "update", "patch", "delete", "revoke", "resend"},
 				ResourceNames: []string{objName},
 			},
 		},
@@ -135,9 +135,12 @@ func (s *rbacCreatorIsOwner) DeleteCollection(ctx context.Context, deleteValidat
 }
 
 func roleName(objName string) string {
-	prefix := "invitations-"
-	suffix := "-owner"
+	return shortenedRBACName("invitations-", objName, "-owner")
+}
 
+// shortenedRBACName returns the name of a Role or RoleBinding for the object with the given name.
+// Names exceeding 63 characters are shortened and suffixed with a hash of the object name to stay unique.
+func shortenedRBACName(prefix, objName, suffix string) string {
 	if len(prefix)+len(suffix)+len(objName) <= 63 {
 		return fmt.Sprintf("%s%s%s", prefix, objName, suffix)
 	}
@@ -0,0 +1,36 @@
+package user
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+)
+
+// NewSubResourceRegisterer returns a helper type to register an action subresource for a resource.
+// The request is the body of requests to the subresource, it is added to the scheme of the API server.
+//
+//	builder.APIServer.
+//		WithResourceAndHandler(&Resource{}, storage).
+//		WithResourceAndHandler(NewSubResourceRegisterer(&Resource{}, "action", &ActionRequest{}), actionStorage).
+func NewSubResourceRegisterer(o resource.Object, subResource string, request runtime.Object) resource.Object {
+	return subResourceRegisterer{o, subResource, request}
+}
+
+type subResourceRegisterer struct {
+	resource.Object
+	subResource string
+	request     runtime.Object
+}
+
+func (o subResourceRegisterer) GetGroupVersionResource() schema.GroupVersionResource {
+	gvr := o.Object.GetGroupVersionResource()
+	gvr.Resource = fmt.Sprintf("%s/%s", gvr.Resource, o.subResource)
+	return gvr
+}
+
+// New returns the request type, registering it with the scheme.
+func (o subResourceRegisterer) New() runtime.Object {
+	return o.request.DeepCopyObject()
+}
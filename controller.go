@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"text/template"
 	"time"
@@ -16,6 +17,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -29,7 +31,12 @@ import (
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	controlv1 "github.com/appuio/control-api/apis/v1"
 	"github.com/appuio/control-api/controllers/saleorder"
+	"github.com/appuio/control-api/controllers/targetref"
 	"github.com/appuio/control-api/mailsenders"
+	"github.com/appuio/control-api/pkg/idp"
+	"github.com/appuio/control-api/pkg/invitationtoken"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/orgadmins"
 
 	"github.com/appuio/control-api/controllers"
 	"github.com/appuio/control-api/webhooks"
@@ -47,6 +54,34 @@ Unsure what to do next? Accept this invitation using the link above, login to on
 
 If you have any problems or questions, please email us at support@appuio.ch.
 
+All the best
+Your APPUiO Cloud Team`
+	defaultInvitationReminderEmailTemplate = `Hello developer of great software, Kubernetes engineer or fellow human,
+
+Your invitation to APPUiO Cloud expires on {{.Object.Status.ValidUntil.Format "2006-01-02 15:04 MST"}}. Follow the link in the invitation e-mail to accept this invitation before it expires. You can find the invitation at https://portal.dev/invitations/{{.Object.ObjectMeta.Name}}.
+
+If you have any problems or questions, please email us at support@appuio.ch.
+
+All the best
+Your APPUiO Cloud Team`
+	defaultInvitationExpiredEmailTemplate = `Good time of day!
+
+The invitation {{.Object.ObjectMeta.Name}}{{with .Object.Spec.Email}} for {{.}}{{end}} you created on APPUiO Cloud expired on {{.Object.Status.ValidUntil.Format "2006-01-02 15:04 MST"}} without being accepted.
+{{with .Object.Spec.Note}}
+Note: {{.}}
+{{end}}
+If you still want to invite them, please create a new invitation at https://portal.appuio.cloud.
+
+All the best
+Your APPUiO Cloud Team`
+	defaultJoinRequestEmailTemplate = `Good time of day!
+
+{{.Object.Status.Requester}} asked to join your organization {{.Object.ObjectMeta.Namespace}} on APPUiO Cloud.
+{{with .Object.Spec.Message}}
+Message: {{.}}
+{{end}}
+Please approve or reject the request at https://portal.appuio.cloud/organizations/{{.Object.ObjectMeta.Namespace}}/joinrequests.
+
 All the best
 Your APPUiO Cloud Team`
 	defaultBillingEntityEmailTemplate = `Good time of day!
@@ -59,6 +94,9 @@ All the best
 Your APPUiO Cloud Team`
 )
 
+// idpBackends are additional identity provider backends selectable with `--idp-backend`, registered by files with build tags.
+var idpBackends = map[string]func() idp.Client{}
+
 // ControllerCommand creates a new command allowing to start the controller
 func ControllerCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -78,20 +116,35 @@ func ControllerCommand() *cobra.Command {
 	probeAddr := cmd.Flags().String("health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	usernamePrefix := cmd.Flags().String("username-prefix", "", "Prefix prepended to username claims. Usually the same as \"--oidc-username-prefix\" of the Kubernetes API server")
 	rolePrefix := cmd.Flags().String("role-prefix", "control-api:user:", "Prefix prepended to generated cluster roles and bindings to prevent name collisions.")
-	memberRoles := cmd.Flags().StringSlice("member-roles", []string{}, "ClusterRoles to assign to every organization member for its namespace")
+	orgRoles := &organizationRoleFlags{}
+	orgRoles.addFlags(cmd)
+	invValidity := &invitationValidityFlags{}
+	invValidity.addFlags(cmd)
+	invHandoff := &invitationTokenHandoffFlags{}
+	invHandoff.addFlags(cmd)
+	teamRoles := cmd.Flags().StringSlice("team-roles", []string{}, "ClusterRoles to assign to every team member for the namespace of the team's organization")
+	invTargetKindsConfig := cmd.Flags().String("invitation-target-kinds-config", "", "Path to a YAML file listing additional kinds users can be invited into. Each entry has the fields group, version, kind, usersPath, nameField, and usernamePrefix. The controller must be granted access to the kinds separately.")
 	webhookCertDir := cmd.Flags().String("webhook-cert-dir", "", "Directory holding TLS certificate and key for the webhook server. If left empty, {TempDir}/k8s-webhook-server/serving-certs is used")
 	webhookPort := cmd.Flags().Int("webhook-port", 9443, "The port on which the admission webhooks are served")
 
 	beRefreshInterval := cmd.Flags().Duration("billing-entity-refresh-interval", 5*time.Minute, "The interval at which the billing entity cache is refreshed")
 	beRefreshJitter := cmd.Flags().Duration("billing-entity-refresh-jitter", time.Minute, "The jitter added to the interval at which the billing entity cache is refreshed")
 
-	invTokenValidFor := cmd.Flags().Duration("invitation-valid-for", 30*24*time.Hour, "The duration an invitation token is valid for")
 	redeemedInvitationTTL := cmd.Flags().Duration("redeemed-invitation-ttl", 30*24*time.Hour, "The duration for which a redeemed invitation is kept before deleting it")
 
 	invEmailBackend := cmd.Flags().String("email-backend", "stdout", "Backend to use for sending invitation mails (one of stdout, mailgun)")
 	invEmailSender := cmd.Flags().String("email-sender", "noreply@appuio.cloud", "Sender address for invitation mails")
 	invEmailSubject := cmd.Flags().String("email-subject", "You have been invited to APPUiO Cloud", "Subject for invitation mails")
 	emailBodyTemplate := cmd.Flags().String("email-body-template", defaultInvitationEmailTemplate, "Body for invitation mails")
+	invReminderBefore := cmd.Flags().Duration("invitation-reminder-before", 3*24*time.Hour, "Send a reminder e-mail this long before an invitation expires. Zero disables reminders.")
+	invReminderEmailSubject := cmd.Flags().String("invitation-reminder-email-subject", "Your invitation to APPUiO Cloud expires soon", "Subject for invitation reminder mails")
+	invReminderEmailBodyTemplate := cmd.Flags().String("invitation-reminder-email-body-template", defaultInvitationReminderEmailTemplate, "Body for invitation reminder mails")
+	invNotifyExpired := cmd.Flags().Bool("invitation-notify-expired", true, "Notify the inviter if an invitation expires without being redeemed")
+	invExpiredEmailSubject := cmd.Flags().String("invitation-expired-email-subject", "Your APPUiO Cloud invitation expired", "Subject for mails notifying the inviter about an expired invitation")
+	invExpiredEmailBodyTemplate := cmd.Flags().String("invitation-expired-email-body-template", defaultInvitationExpiredEmailTemplate, "Body for mails notifying the inviter about an expired invitation")
+	joinRequestEmailSubject := cmd.Flags().String("join-request-email-subject", "A user asked to join your organization on APPUiO Cloud", "Subject for mails notifying organization admins about join requests")
+	joinRequestEmailBodyTemplate := cmd.Flags().String("join-request-email-body-template", defaultJoinRequestEmailTemplate, "Body for mails notifying organization admins about join requests")
+	invExpiryNotificationGracePeriod := cmd.Flags().Duration("invitation-expiry-notification-grace-period", 24*time.Hour, "Maximum duration an expired invitation is kept until the inviter was notified about the expiry")
 	invEmailBaseRetryDelay := cmd.Flags().Duration("email-base-retry-interval", 15*time.Second, "Retry interval for sending e-mail messages. There is also an exponential back-off applied by the controller.")
 
 	invEmailMailgunToken := cmd.Flags().String("mailgun-token", "CHANGEME", "Token used to access Mailgun API")
@@ -114,6 +167,13 @@ func ControllerCommand() *cobra.Command {
 	cmd.Flags().StringVar(&oc.Admin, "sale-order-odoo16-account", "Admin", "Odoo Account name to use for sale orders")
 	cmd.Flags().StringVar(&oc.Password, "sale-order-odoo16-password", "superSecret1238", "Odoo Account password to use for sale orders")
 
+	idpBackend := cmd.Flags().String("idp-backend", "none", "Identity provider to sync users, teams and organization members to. Valid values are `none` and `keycloak`. Users and teams lose the sync finalizer if `none`.")
+	idpKeycloakURL := cmd.Flags().String("idp-keycloak-url", "http://localhost:8080", "Base URL of the Keycloak server")
+	idpKeycloakRealm := cmd.Flags().String("idp-keycloak-realm", "appuio", "Keycloak realm to sync users and groups to")
+	idpKeycloakLoginRealm := cmd.Flags().String("idp-keycloak-login-realm", "master", "Keycloak realm used to authenticate the admin user")
+	idpKeycloakUsername := cmd.Flags().String("idp-keycloak-username", "admin", "Username of the Keycloak admin user")
+	idpKeycloakPassword := cmd.Flags().String("idp-keycloak-password", "", "Password of the Keycloak admin user")
+
 	cmd.Run = func(*cobra.Command, []string) {
 		scheme := runtime.NewScheme()
 		setupLog := ctrl.Log.WithName("setup")
@@ -138,6 +198,26 @@ func ControllerCommand() *cobra.Command {
 			setupLog.Error(err, "Failed to parse email body template for billing entity e-mails")
 			os.Exit(1)
 		}
+		rt, err := template.New("emailBody").Funcs(sprig.FuncMap()).Parse(*invReminderEmailBodyTemplate)
+		if err != nil {
+			setupLog.Error(err, "Failed to parse email body template for invitation reminders")
+			os.Exit(1)
+		}
+		et, err := template.New("emailBody").Funcs(sprig.FuncMap()).Parse(*invExpiredEmailBodyTemplate)
+		if err != nil {
+			setupLog.Error(err, "Failed to parse email body template for expired invitations")
+			os.Exit(1)
+		}
+		jt, err := template.New("emailBody").Funcs(sprig.FuncMap()).Parse(*joinRequestEmailBodyTemplate)
+		if err != nil {
+			setupLog.Error(err, "Failed to parse email body template for join requests")
+			os.Exit(1)
+		}
+		invTargetKinds, err := targetref.LoadRegistry(*invTargetKindsConfig)
+		if err != nil {
+			setupLog.Error(err, "Failed to load invitation target kinds")
+			os.Exit(1)
+		}
 		invitationBodyRenderer := &mailsenders.Renderer{Template: bt}
 		billingEntityBodyRenderer := &mailsenders.Renderer{Template: bet}
 
@@ -184,21 +264,77 @@ func ControllerCommand() *cobra.Command {
 			}
 		}
 
+		newInvitationMailSender := func(subject string, body *mailsenders.Renderer) mailsenders.MailSender {
+			if *invEmailBackend == "mailgun" {
+				b := mailsenders.NewMailgunSender(
+					*invEmailMailgunDomain,
+					*invEmailMailgunToken,
+					*invEmailMailgunUrl,
+					*invEmailSender,
+					body,
+					subject,
+					*invEmailMailgunTestMode,
+				)
+				return &b
+			}
+			return &mailsenders.StdoutSender{
+				Subject: subject,
+				Body:    body,
+			}
+		}
+		invReminderMailSender := newInvitationMailSender(*invReminderEmailSubject, &mailsenders.Renderer{Template: rt})
+		var invExpiredMailSender mailsenders.MailSender
+		if *invNotifyExpired {
+			invExpiredMailSender = newInvitationMailSender(*invExpiredEmailSubject, &mailsenders.Renderer{Template: et})
+		} else {
+			*invExpiryNotificationGracePeriod = 0
+		}
+
+		joinRequestMailSender := newInvitationMailSender(*joinRequestEmailSubject, &mailsenders.Renderer{Template: jt})
+
+		var idpClient idp.Client
+		switch *idpBackend {
+		case "none":
+		case "keycloak":
+			kc := idp.NewKeycloakClient(*idpKeycloakURL, *idpKeycloakRealm, *idpKeycloakUsername, *idpKeycloakPassword)
+			kc.LoginRealm = *idpKeycloakLoginRealm
+			idpClient = kc
+		default:
+			newClient, ok := idpBackends[*idpBackend]
+			if !ok {
+				setupLog.Error(fmt.Errorf("unknown identity provider backend %q", *idpBackend), "unable to setup identity provider")
+				os.Exit(1)
+			}
+			idpClient = newClient()
+		}
+
 		mgr, err := setupManager(
 			*usernamePrefix,
 			*rolePrefix,
-			*memberRoles,
+			orgRoles.memberRoles,
+			orgRoles.allowedMemberRoles,
+			*teamRoles,
+			orgRoles.rolePrefix,
+			orgadmins.AdminRolesOrDefault(orgRoles.adminRoles, orgRoles.rolePrefix),
+			invTargetKinds,
 			*beRefreshInterval,
 			*beRefreshJitter,
-			*invTokenValidFor,
+			invValidity.maxValidFor,
+			invHandoff.namespace,
 			*redeemedInvitationTTL,
 			*invEmailBaseRetryDelay,
 			invMailSender,
+			*invReminderBefore,
+			invReminderMailSender,
+			invExpiredMailSender,
+			*invExpiryNotificationGracePeriod,
+			joinRequestMailSender,
 			*saleOrderStorage,
 			*saleOrderClientReference,
 			*saleOrderInternalNote,
 			*saleOrderCompatMode,
 			oc,
+			idpClient,
 			ctrl.Options{
 				Scheme:                 scheme,
 				MetricsBindAddress:     *metricsAddr,
@@ -258,17 +394,29 @@ func setupManager(
 	usernamePrefix,
 	rolePrefix string,
 	memberRoles []string,
+	allowedMemberRoles []string,
+	teamRoles []string,
+	organizationRolePrefix string,
+	organizationAdminRoles []string,
+	invTargetKinds *targetref.Registry,
 	beRefreshInterval,
 	beRefreshJitter,
-	invTokenValidFor time.Duration,
+	invMaxValidFor time.Duration,
+	invTokenHandoffNS string,
 	redeemedInvitationTTL time.Duration,
 	invEmailBaseRetryDelay time.Duration,
 	mailSender mailsenders.MailSender,
+	invReminderBefore time.Duration,
+	reminderMailSender mailsenders.MailSender,
+	expiredMailSender mailsenders.MailSender,
+	invExpiryNotificationGracePeriod time.Duration,
+	joinRequestMailSender mailsenders.MailSender,
 	saleOrderStorage string,
 	saleOrderClientReference string,
 	saleOrderInternalNote string,
 	saleOrderCompatMode bool,
 	odooCredentials saleorder.Odoo16Credentials,
+	idpClient idp.Client,
 	opt ctrl.Options,
 ) (ctrl.Manager, error) {
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), opt)
@@ -300,18 +448,28 @@ func setupManager(
 	if err = ur.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
-	if len(memberRoles) > 0 {
-		omr := &controllers.OrganizationMembersReconciler{
-			Client:   mgr.GetClient(),
-			Scheme:   mgr.GetScheme(),
-			Recorder: mgr.GetEventRecorderFor("organization-members-controller"),
+	omr := &controllers.OrganizationMembersReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("organization-members-controller"),
 
-			UserPrefix:  usernamePrefix,
-			MemberRoles: memberRoles,
-		}
-		if err = omr.SetupWithManager(mgr); err != nil {
-			return nil, err
-		}
+		UserPrefix:   usernamePrefix,
+		MemberRoles:  memberRoles,
+		AllowedRoles: allowedMemberRoles,
+	}
+	if err = omr.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+	tr := &controllers.TeamReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("team-controller"),
+
+		UserPrefix: usernamePrefix,
+		TeamRoles:  teamRoles,
+	}
+	if err = tr.SetupWithManager(mgr); err != nil {
+		return nil, err
 	}
 	dor := &controllers.DefaultOrganizationReconciler{
 		Client:   mgr.GetClient(),
@@ -332,22 +490,15 @@ func setupManager(
 	if err = obenc.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
-	invtoc := &controllers.InvitationTokenReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("invitation-token-controller"),
-
-		TokenValidFor: invTokenValidFor,
-	}
-	if err = invtoc.SetupWithManager(mgr); err != nil {
-		return nil, err
-	}
 	invred := &controllers.InvitationRedeemReconciler{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("invitation-redeem-controller"),
 
-		UsernamePrefix: usernamePrefix,
+		UsernamePrefix:         usernamePrefix,
+		OrganizationRolePrefix: organizationRolePrefix,
+		MemberRoles:            memberRoles,
+		TargetKinds:            invTargetKinds,
 	}
 	if err = invred.SetupWithManager(mgr); err != nil {
 		return nil, err
@@ -357,21 +508,81 @@ func setupManager(
 		Scheme:                mgr.GetScheme(),
 		Recorder:              mgr.GetEventRecorderFor("invitation-cleanup-controller"),
 		RedeemedInvitationTTL: redeemedInvitationTTL,
+
+		ExpiryNotificationGracePeriod: invExpiryNotificationGracePeriod,
 	}
 	if err = invclean.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
 
+	// The hand-off secrets are read without cache, the controller must not cache all secrets of the cluster.
+	handoffClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		return nil, err
+	}
 	invmail := controllers.NewInvitationEmailReconciler(
 		mgr.GetClient(),
 		mgr.GetEventRecorderFor("invitation-email-controller"),
 		mgr.GetScheme(),
 		mailSender,
 		invEmailBaseRetryDelay,
+		invitationtoken.Handoff{Client: handoffClient, Namespace: invTokenHandoffNS},
 	)
 	if err = invmail.SetupWithManager(mgr); err != nil {
 		return nil, err
 	}
+	invremind := controllers.NewInvitationReminderReconciler(
+		mgr.GetClient(),
+		mgr.GetEventRecorderFor("invitation-reminder-controller"),
+		mgr.GetScheme(),
+		reminderMailSender,
+		invReminderBefore,
+		expiredMailSender,
+		usernamePrefix,
+		invEmailBaseRetryDelay,
+	)
+	if err = invremind.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+
+	jrc := &controllers.JoinRequestReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("join-request-controller"),
+
+		UsernamePrefix: usernamePrefix,
+	}
+	if err = jrc.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+	jrmail := controllers.NewJoinRequestEmailReconciler(
+		mgr.GetClient(),
+		mgr.GetEventRecorderFor("join-request-email-controller"),
+		mgr.GetScheme(),
+		joinRequestMailSender,
+		usernamePrefix,
+		orgadmins.Resolver{
+			AdminRoles: organizationAdminRoles,
+			UserPrefix: usernamePrefix,
+			MemberRoles: memberroles.Resolver{
+				DefaultRoles: memberRoles,
+				AllowedRoles: allowedMemberRoles,
+			},
+		},
+		invEmailBaseRetryDelay,
+	)
+	if err = jrmail.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
+
+	upr := &controllers.UsageProfileReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("usage-profile-controller"),
+	}
+	if err = upr.SetupWithManager(mgr); err != nil {
+		return nil, err
+	}
 
 	if saleOrderStorage == "odoo16" {
 		storage, err := saleorder.NewOdoo16Storage(&odooCredentials, &saleorder.Odoo16Options{
@@ -393,14 +604,81 @@ func setupManager(
 		}
 	}
 
+	if idpClient != nil {
+		ius := &controllers.IdPUserSyncReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("idp-user-sync-controller"),
+			IdP:      idpClient,
+		}
+		if err = ius.SetupWithManager(mgr); err != nil {
+			return nil, err
+		}
+		its := &controllers.IdPTeamSyncReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("idp-team-sync-controller"),
+			IdP:      idpClient,
+		}
+		if err = its.SetupWithManager(mgr); err != nil {
+			return nil, err
+		}
+		ioms := &controllers.IdPOrganizationMembersSyncReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("idp-organizationmembers-sync-controller"),
+			IdP:      idpClient,
+		}
+		if err = ioms.SetupWithManager(mgr); err != nil {
+			return nil, err
+		}
+	} else {
+		for name, obj := range map[string]client.Object{"user": &controlv1.User{}, "team": &controlv1.Team{}} {
+			fc := &controllers.IdPSyncFinalizerCleanupReconciler{
+				Client: mgr.GetClient(),
+				Name:   "idp-sync-finalizer-cleanup-" + name,
+				Object: obj,
+			}
+			if err = fc.SetupWithManager(mgr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	metrics.Registry.MustRegister(invmail.GetMetrics())
+	metrics.Registry.MustRegister(invremind.GetMetrics())
+	metrics.Registry.MustRegister(jrmail.GetMetrics())
 
 	mgr.GetWebhookServer().Register("/validate-appuio-io-v1-user", &webhook.Admission{
 		Handler: &webhooks.UserValidator{},
 	})
 	mgr.GetWebhookServer().Register("/validate-user-appuio-io-v1-invitation", &webhook.Admission{
 		Handler: &webhooks.InvitationValidator{
-			UsernamePrefix: usernamePrefix,
+			UsernamePrefix:         usernamePrefix,
+			OrganizationRolePrefix: organizationRolePrefix,
+			MemberRoles:            memberRoles,
+			AllowedMemberRoles:     allowedMemberRoles,
+			MaxValidFor:            invMaxValidFor,
+			TargetKinds:            invTargetKinds,
+		},
+	})
+	mgr.GetWebhookServer().Register("/validate-namespace-quota", &webhook.Admission{
+		Handler: &webhooks.NamespaceQuotaValidator{},
+	})
+	mgr.GetWebhookServer().Register("/validate-appuio-io-v1-organizationmembers-admins", &webhook.Admission{
+		Handler: &webhooks.OrganizationAdminValidator{
+			AdminRoles:         organizationAdminRoles,
+			UserPrefix:         usernamePrefix,
+			MemberRoles:        memberRoles,
+			AllowedMemberRoles: allowedMemberRoles,
+		},
+	})
+	mgr.GetWebhookServer().Register("/validate-rbac-v1-rolebinding-organization-admins", &webhook.Admission{
+		Handler: &webhooks.OrganizationAdminValidator{
+			AdminRoles:         organizationAdminRoles,
+			UserPrefix:         usernamePrefix,
+			MemberRoles:        memberRoles,
+			AllowedMemberRoles: allowedMemberRoles,
 		},
 	})
 
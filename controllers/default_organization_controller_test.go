@@ -26,7 +26,7 @@ var testMemberships1 = controlv1.OrganizationMembers{
 		Namespace: "foo-gmbh",
 	},
 	Spec: controlv1.OrganizationMembersSpec{
-		UserRefs: []controlv1.UserRef{
+		UserRefs: []controlv1.OrganizationMemberRef{
 			{Name: "u1"},
 			{Name: "u2"},
 			{Name: "u3"},
@@ -47,7 +47,7 @@ var testMemberships2 = controlv1.OrganizationMembers{
 		Namespace: "bar-gmbh",
 	},
 	Spec: controlv1.OrganizationMembersSpec{
-		UserRefs: []controlv1.UserRef{
+		UserRefs: []controlv1.OrganizationMemberRef{
 			{Name: "u1"},
 		},
 	},
@@ -173,7 +173,7 @@ func Test_DefaultOrganizationReconciler_Reconcile_Error(t *testing.T) {
 			Namespace: "foo-gmbh",
 		},
 		Spec: controlv1.OrganizationMembersSpec{
-			UserRefs: []controlv1.UserRef{
+			UserRefs: []controlv1.OrganizationMemberRef{
 				{Name: "u1"},
 				{Name: "u2"},
 				{Name: "u3"},
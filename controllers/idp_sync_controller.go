@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/idp"
+)
+
+// IdPSyncFinalizer is set on users and teams synced to the identity provider.
+// It ensures the user or group is removed from the identity provider when the object is deleted.
+const IdPSyncFinalizer = "appuio.io/idp-sync"
+
+// IdPUserSyncReconciler syncs User resources to the identity provider
+type IdPUserSyncReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	IdP idp.Client
+}
+
+//+kubebuilder:rbac:groups=appuio.io,resources=users,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=users/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=users/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile creates or updates the user in the identity provider and records its ID in the status
+func (r *IdPUserSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	user := controlv1.User{}
+	if err := r.Get(ctx, req.NamespacedName, &user); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !user.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&user, IdPSyncFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if user.Status.ID != "" {
+			if err := r.IdP.DeleteUser(ctx, user.Status.ID); err != nil && !errors.Is(err, idp.ErrNotFound) {
+				r.Recorder.Event(&user, "Warning", "IdPSyncFailed", "Failed to delete user in identity provider")
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(&user, IdPSyncFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &user)
+	}
+
+	if controllerutil.AddFinalizer(&user, IdPSyncFinalizer) {
+		if err := r.Update(ctx, &user); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	desired := idp.User{
+		Username:    user.Name,
+		Email:       user.Status.Email,
+		DisplayName: user.Status.DisplayName,
+	}
+	existing, err := r.IdP.GetUser(ctx, user.Name)
+	switch {
+	case errors.Is(err, idp.ErrNotFound):
+		existing, err = r.IdP.CreateUser(ctx, desired)
+	case err == nil:
+		desired.ID = existing.ID
+		if desired != existing {
+			existing, err = r.IdP.UpdateUser(ctx, desired)
+		}
+	}
+	if err != nil {
+		r.Recorder.Event(&user, "Warning", "IdPSyncFailed", "Failed to sync user to identity provider")
+		return ctrl.Result{}, err
+	}
+
+	if user.Status.ID == existing.ID {
+		return ctrl.Result{}, nil
+	}
+	user.Status.ID = existing.ID
+	return ctrl.Result{}, r.Status().Update(ctx, &user)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdPUserSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("idp-user-sync").
+		For(&controlv1.User{}).
+		Complete(r)
+}
+
+// IdPTeamSyncReconciler syncs Team resources to groups in the identity provider.
+// Teams are synced as subgroups of the group of the organization.
+type IdPTeamSyncReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	IdP idp.Client
+}
+
+//+kubebuilder:rbac:groups=appuio.io,resources=teams,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=teams/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=teams/finalizers,verbs=update
+
+// Reconcile creates the group of the team in the identity provider, sets its members and records the group ID in the status
+func (r *IdPTeamSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	team := controlv1.Team{}
+	if err := r.Get(ctx, req.NamespacedName, &team); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !team.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&team, IdPSyncFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if team.Status.GroupRef.ID != "" {
+			if err := r.IdP.DeleteGroup(ctx, team.Status.GroupRef.ID); err != nil && !errors.Is(err, idp.ErrNotFound) {
+				r.Recorder.Event(&team, "Warning", "IdPSyncFailed", "Failed to delete group in identity provider")
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(&team, IdPSyncFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &team)
+	}
+
+	if controllerutil.AddFinalizer(&team, IdPSyncFinalizer) {
+		if err := r.Update(ctx, &team); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	group, err := r.syncGroup(ctx, team)
+	if err != nil {
+		r.Recorder.Event(&team, "Warning", "IdPSyncFailed", "Failed to sync team to identity provider")
+		return ctrl.Result{}, err
+	}
+
+	if team.Status.GroupRef.ID == group.ID {
+		return ctrl.Result{}, nil
+	}
+	team.Status.GroupRef.ID = group.ID
+	return ctrl.Result{}, r.Status().Update(ctx, &team)
+}
+
+func (r *IdPTeamSyncReconciler) syncGroup(ctx context.Context, team controlv1.Team) (idp.Group, error) {
+	orgGroup, err := r.IdP.CreateGroup(ctx, idp.Group{Name: team.Namespace})
+	if err != nil {
+		return idp.Group{}, fmt.Errorf("failed to create organization group: %w", err)
+	}
+	group, err := r.IdP.CreateGroup(ctx, idp.Group{Name: team.Name, ParentID: orgGroup.ID})
+	if err != nil {
+		return idp.Group{}, fmt.Errorf("failed to create team group: %w", err)
+	}
+	return group, r.IdP.SetGroupMembers(ctx, group.ID, userRefNames(team.Status.ResolvedUserRefs))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdPTeamSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("idp-team-sync").
+		For(&controlv1.Team{}).
+		Complete(r)
+}
+
+// IdPOrganizationMembersSyncReconciler syncs OrganizationMembers resources to groups in the identity provider
+type IdPOrganizationMembersSyncReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	IdP idp.Client
+}
+
+//+kubebuilder:rbac:groups=appuio.io,resources=organizationmembers,verbs=get;list;watch
+
+// Reconcile creates the group of the organization in the identity provider and sets its members
+func (r *IdPOrganizationMembersSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	memb := controlv1.OrganizationMembers{}
+	if err := r.Get(ctx, req.NamespacedName, &memb); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !memb.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	group, err := r.IdP.CreateGroup(ctx, idp.Group{Name: memb.Namespace})
+	if err == nil {
+		err = r.IdP.SetGroupMembers(ctx, group.ID, userRefNames(memb.Status.ResolvedUserRefs))
+	}
+	if err != nil {
+		r.Recorder.Event(&memb, "Warning", "IdPSyncFailed", "Failed to sync organization members to identity provider")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdPOrganizationMembersSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("idp-organizationmembers-sync").
+		For(&controlv1.OrganizationMembers{}).
+		Complete(r)
+}
+
+// IdPSyncFinalizerCleanupReconciler removes the IdPSyncFinalizer if no identity provider is configured.
+// Without it, users and teams synced before the identity provider was disabled could never be deleted.
+// Users and groups left in the identity provider are not removed.
+type IdPSyncFinalizerCleanupReconciler struct {
+	client.Client
+
+	// Name is the name of the controller, it must be unique per manager.
+	Name string
+	// Object is the type of the objects to remove the finalizer from.
+	Object client.Object
+}
+
+// Reconcile removes the IdPSyncFinalizer from the object
+func (r *IdPSyncFinalizerCleanupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	obj := r.Object.DeepCopyObject().(client.Object)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !controllerutil.RemoveFinalizer(obj, IdPSyncFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	log.Info("Removing identity provider sync finalizer, no identity provider configured")
+	return ctrl.Result{}, r.Update(ctx, obj)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IdPSyncFinalizerCleanupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(r.Name).
+		For(r.Object).
+		Complete(r)
+}
+
+func userRefNames(refs []controlv1.UserRef) []string {
+	names := make([]string, len(refs))
+	for i, ur := range refs {
+		names[i] = ur.Name
+	}
+	return names
+}
@@ -0,0 +1,180 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/pkg/idp"
+)
+
+func Test_IdPUserSyncReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	user := controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "jdoe",
+		},
+		Status: controlv1.UserStatus{
+			Email:       "jdoe@example.com",
+			DisplayName: "John Doe",
+		},
+	}
+	c := prepareTest(t, &user)
+	fakeIdP := idp.NewFakeClient()
+
+	subject := &IdPUserSyncReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+		IdP:      fakeIdP,
+	}
+
+	_, err := subject.Reconcile(ctx, requestFor(&user))
+	require.NoError(t, err)
+
+	idpUser, err := fakeIdP.GetUser(ctx, "jdoe")
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", idpUser.Email)
+	assert.Equal(t, "John Doe", idpUser.DisplayName)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&user), &user))
+	assert.Equal(t, idpUser.ID, user.Status.ID)
+	assert.Contains(t, user.Finalizers, IdPSyncFinalizer)
+
+	t.Run("update", func(t *testing.T) {
+		user.Status.Email = "john@example.com"
+		require.NoError(t, c.Status().Update(ctx, &user))
+
+		_, err := subject.Reconcile(ctx, requestFor(&user))
+		require.NoError(t, err)
+
+		updated, err := fakeIdP.GetUser(ctx, "jdoe")
+		require.NoError(t, err)
+		assert.Equal(t, idpUser.ID, updated.ID)
+		assert.Equal(t, "john@example.com", updated.Email)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, c.Delete(ctx, &user))
+
+		_, err := subject.Reconcile(ctx, requestFor(&user))
+		require.NoError(t, err)
+
+		_, err = fakeIdP.GetUser(ctx, "jdoe")
+		assert.ErrorIs(t, err, idp.ErrNotFound)
+		err = c.Get(ctx, client.ObjectKeyFromObject(&user), &user)
+		assert.True(t, apierrors.IsNotFound(err), "user should be deleted once the finalizer is removed")
+	})
+}
+
+func Test_IdPTeamSyncReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	team := controlv1.Team{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "team",
+			Namespace: "foo-gmbh",
+		},
+		Status: controlv1.TeamStatus{
+			ResolvedUserRefs: []controlv1.UserRef{{Name: "u1"}, {Name: "u2"}},
+		},
+	}
+	c := prepareTest(t, &team)
+	fakeIdP := idp.NewFakeClient()
+	for _, u := range []string{"u1", "u2"} {
+		_, err := fakeIdP.CreateUser(ctx, idp.User{Username: u})
+		require.NoError(t, err)
+	}
+
+	subject := &IdPTeamSyncReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+		IdP:      fakeIdP,
+	}
+
+	_, err := subject.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: team.Name, Namespace: team.Namespace}})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&team), &team))
+	require.NotEmpty(t, team.Status.GroupRef.ID)
+	group, err := fakeIdP.GetGroup(team.Status.GroupRef.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "team", group.Name)
+	orgGroup, err := fakeIdP.GetGroup(group.ParentID)
+	require.NoError(t, err)
+	assert.Equal(t, "foo-gmbh", orgGroup.Name)
+	assert.Equal(t, []string{"u1", "u2"}, fakeIdP.GroupMembers(group.ID))
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, c.Delete(ctx, &team))
+
+		_, err := subject.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: team.Name, Namespace: team.Namespace}})
+		require.NoError(t, err)
+
+		_, err = fakeIdP.GetGroup(group.ID)
+		assert.ErrorIs(t, err, idp.ErrNotFound)
+		_, err = fakeIdP.GetGroup(orgGroup.ID)
+		assert.NoError(t, err, "organization group must not be deleted")
+	})
+}
+
+func Test_IdPOrganizationMembersSyncReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	c := prepareTest(t, &testMemb)
+	fakeIdP := idp.NewFakeClient()
+	for _, u := range []string{"u1", "u2", "u3"} {
+		_, err := fakeIdP.CreateUser(ctx, idp.User{Username: u})
+		require.NoError(t, err)
+	}
+
+	_, err := (&IdPOrganizationMembersSyncReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+		IdP:      fakeIdP,
+	}).Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: testMemb.Name, Namespace: testMemb.Namespace}})
+	require.NoError(t, err)
+
+	group, err := fakeIdP.CreateGroup(ctx, idp.Group{Name: testMemb.Namespace})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u1", "u2", "u3"}, fakeIdP.GroupMembers(group.ID))
+}
+
+func Test_IdPSyncFinalizerCleanupReconciler_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	user := controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "jdoe",
+			Finalizers: []string{IdPSyncFinalizer, "other"},
+		},
+	}
+	team := controlv1.Team{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "developers",
+			Namespace:  "acme",
+			Finalizers: []string{IdPSyncFinalizer},
+		},
+	}
+	c := prepareTest(t, &user, &team)
+	require.NoError(t, c.Delete(ctx, &team))
+
+	_, err := (&IdPSyncFinalizerCleanupReconciler{Client: c, Object: &controlv1.User{}}).Reconcile(ctx, requestFor(&user))
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&user), &user))
+	assert.Equal(t, []string{"other"}, user.Finalizers)
+
+	_, err = (&IdPSyncFinalizerCleanupReconciler{Client: c, Object: &controlv1.Team{}}).Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: team.Name, Namespace: team.Namespace}})
+	require.NoError(t, err)
+	err = c.Get(ctx, client.ObjectKeyFromObject(&team), &team)
+	assert.True(t, apierrors.IsNotFound(err), "team should be deleted once the finalizer is removed")
+}
@@ -23,6 +23,9 @@ type InvitationCleanupReconciler struct {
 	Scheme   *runtime.Scheme
 
 	RedeemedInvitationTTL time.Duration
+	// ExpiryNotificationGracePeriod is the duration an expired invitation is kept until the inviter was notified about the expiry.
+	// Zero deletes expired invitations immediately.
+	ExpiryNotificationGracePeriod time.Duration
 }
 
 //+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations,verbs=get;list;watch;delete
@@ -45,7 +48,7 @@ func (r *InvitationCleanupReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, nil
 	}
 
-	if inv.Status.Token == "" {
+	if !inv.HasToken() {
 		// Invitation is not yet valid
 		return ctrl.Result{}, nil
 	}
@@ -53,15 +56,18 @@ func (r *InvitationCleanupReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 	if inv.IsRedeemed() {
 		cond := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionRedeemed)
-		ttlExpirationTime := cond.LastTransitionTime.Add(r.RedeemedInvitationTTL)
-		if ttlExpirationTime.Before(now.Time) {
-			log.V(1).Info("Redeemed Invitation TTL expired - deleting", "ttlExpirationTime", ttlExpirationTime)
-			return ctrl.Result{}, r.Delete(ctx, &inv)
-		}
-		return ctrl.Result{RequeueAfter: ttlExpirationTime.Sub(now.Add(-time.Minute))}, nil
+		return r.deleteAfterTTL(ctx, &inv, cond.LastTransitionTime.Time, now)
 	}
 
 	if inv.Status.ValidUntil.Before(&now) {
+		if len(inv.Status.Redeemers) > 0 {
+			// Partially redeemed multi-use invitations are kept for the redeemed TTL after they expire
+			return r.deleteAfterTTL(ctx, &inv, inv.Status.ValidUntil.Time, now)
+		}
+		if deadline := inv.Status.ValidUntil.Add(r.ExpiryNotificationGracePeriod); !inv.IsRevoked() && !expiryNotified(&inv) && deadline.After(now.Time) {
+			log.V(1).Info("Invitation expired - waiting for expiry notification", "deadline", deadline)
+			return ctrl.Result{RequeueAfter: deadline.Sub(now.Add(-time.Minute))}, nil
+		}
 		log.V(1).Info("Invitation expired - deleting")
 		return ctrl.Result{}, r.Delete(ctx, &inv)
 	}
@@ -69,6 +75,16 @@ func (r *InvitationCleanupReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	return ctrl.Result{RequeueAfter: inv.Status.ValidUntil.Sub(now.Add(-time.Minute))}, nil
 }
 
+// deleteAfterTTL deletes the redeemed invitation if the redeemed TTL starting at the given time expired, it requeues the invitation otherwise.
+func (r *InvitationCleanupReconciler) deleteAfterTTL(ctx context.Context, inv *userv1.Invitation, redeemedAt time.Time, now metav1.Time) (ctrl.Result, error) {
+	ttlExpirationTime := redeemedAt.Add(r.RedeemedInvitationTTL)
+	if ttlExpirationTime.Before(now.Time) {
+		log.FromContext(ctx).V(1).Info("Redeemed Invitation TTL expired - deleting", "ttlExpirationTime", ttlExpirationTime)
+		return ctrl.Result{}, r.Delete(ctx, inv)
+	}
+	return ctrl.Result{RequeueAfter: ttlExpirationTime.Sub(now.Add(-time.Minute))}, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *InvitationCleanupReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -109,6 +109,87 @@ func Test_InvitationCleanupReconciler_Reconcile_Expired_Success(t *testing.T) {
 	require.ErrorContains(t, c.Get(ctx, types.NamespacedName{Name: subject.Name}, &subject), "not found")
 }
 
+func Test_InvitationCleanupReconciler_Reconcile_PartiallyRedeemedAndExpired_Success(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ttl           time.Duration
+		expectDeleted bool
+	}{
+		"within TTL":  {ttl: time.Hour},
+		"TTL expired": {ttl: -time.Hour, expectDeleted: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			subject := userv1.Invitation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "subject",
+				},
+				Spec: userv1.InvitationSpec{
+					MaxRedemptions: 3,
+				},
+			}
+			subject.Status.Token = uuid.New().String()
+			subject.Status.ValidUntil = metav1.NewTime(time.Now().Add(-time.Minute))
+			subject.Status.Redeemers = []userv1.Redeemer{{Username: "user"}}
+
+			c := prepareTest(t, &subject)
+
+			_, err := (&InvitationCleanupReconciler{
+				Client:                c,
+				Scheme:                c.Scheme(),
+				Recorder:              record.NewFakeRecorder(3),
+				RedeemedInvitationTTL: tc.ttl,
+			}).Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name: subject.Name,
+				},
+			})
+			require.NoError(t, err)
+
+			err = c.Get(ctx, types.NamespacedName{Name: subject.Name}, &subject)
+			if tc.expectDeleted {
+				require.ErrorContains(t, err, "not found")
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_InvitationCleanupReconciler_Reconcile_Expired_WaitForExpiryNotification(t *testing.T) {
+	ctx := context.Background()
+
+	subject := userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+	}
+	subject.Status.Token = uuid.New().String()
+	subject.Status.ValidUntil = metav1.NewTime(time.Now().Add(-time.Minute))
+
+	c := prepareTest(t, &subject)
+
+	r := &InvitationCleanupReconciler{
+		Client:                        c,
+		Scheme:                        c.Scheme(),
+		Recorder:                      record.NewFakeRecorder(3),
+		RedeemedInvitationTTL:         time.Hour,
+		ExpiryNotificationGracePeriod: time.Hour,
+	}
+	_, err := r.Reconcile(ctx, requestFor(&subject))
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: subject.Name}, &subject), "invitation should be kept until the inviter was notified")
+
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionExpiryNotificationSent,
+		Status: metav1.ConditionTrue,
+	})
+	require.NoError(t, c.Status().Update(ctx, &subject))
+	_, err = r.Reconcile(ctx, requestFor(&subject))
+	require.NoError(t, err)
+	require.ErrorContains(t, c.Get(ctx, types.NamespacedName{Name: subject.Name}, &subject), "not found")
+}
+
 func Test_InvitationCleanupReconciler_Reconcile_Valid_Success(t *testing.T) {
 	ctx := context.Background()
 
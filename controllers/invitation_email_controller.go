@@ -7,6 +7,7 @@ import (
 
 	"go.uber.org/multierr"
 	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,6 +22,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/pkg/invitationtoken"
 )
 
 // InvitationEmailReconciler reconciles invitations and sends invitation emails if appropriate
@@ -32,18 +34,21 @@ type InvitationEmailReconciler struct {
 
 	MailSender     mailsenders.MailSender
 	BaseRetryDelay time.Duration
+	// TokenHandoff holds the tokens issued by the API server until the invitation email is sent.
+	TokenHandoff invitationtoken.Handoff
 
 	failureCounter prometheus.Counter
 	successCounter prometheus.Counter
 }
 
-func NewInvitationEmailReconciler(client client.Client, eventRecorder record.EventRecorder, scheme *runtime.Scheme, mailSender mailsenders.MailSender, baseRetryDelay time.Duration) InvitationEmailReconciler {
+func NewInvitationEmailReconciler(client client.Client, eventRecorder record.EventRecorder, scheme *runtime.Scheme, mailSender mailsenders.MailSender, baseRetryDelay time.Duration, tokenHandoff invitationtoken.Handoff) InvitationEmailReconciler {
 	return InvitationEmailReconciler{
 		Client:         client,
 		Recorder:       eventRecorder,
 		Scheme:         scheme,
 		MailSender:     mailSender,
 		BaseRetryDelay: baseRetryDelay,
+		TokenHandoff:   tokenHandoff,
 		failureCounter: newFailureCounter("control_api_invitation_emails"),
 		successCounter: newSuccessCounter("control_api_invitation_emails"),
 	}
@@ -61,41 +66,69 @@ func (r *InvitationEmailReconciler) GetMetrics() prometheus.Collector {
 //+kubebuilder:rbac:groups="user.appuio.io",resources=invitations,verbs=get;list;watch
 //+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups="user.appuio.io",resources=invitations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;delete
 
 // Reconcile reacts to redeemed invitations and sends invitation emails to the user if needed.
+// The token is taken from the hand-off of the API server, which is removed once the email is sent or can no longer be sent.
 func (r *InvitationEmailReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.V(1).WithValues("request", req).Info("Reconciling")
 
 	inv := userv1.Invitation{}
 	if err := r.Get(ctx, req.NamespacedName, &inv); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.TokenHandoff.Delete(ctx, req.Name)
+		}
+		return ctrl.Result{}, err
 	}
 
 	if !inv.ObjectMeta.DeletionTimestamp.IsZero() {
 		return ctrl.Result{}, nil
 	}
 
-	if inv.Status.Token == "" || inv.Spec.Email == "" {
+	if !inv.HasToken() || inv.Spec.Email == "" {
 		return ctrl.Result{}, nil
 	}
 
-	if apimeta.IsStatusConditionTrue(inv.Status.Conditions, userv1.ConditionEmailSent) {
-		return ctrl.Result{}, nil
+	if inv.IsRevoked() || inv.IsRedeemed() || apimeta.IsStatusConditionTrue(inv.Status.Conditions, userv1.ConditionEmailSent) {
+		return ctrl.Result{}, r.TokenHandoff.Delete(ctx, inv.Name)
+	}
+
+	token, err := r.token(ctx, inv)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if token == "" {
+		if cond := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionEmailSent); cond != nil && cond.Reason == userv1.ConditionReasonTokenUnavailable {
+			return ctrl.Result{}, nil
+		}
+		log.V(0).Info("Token of the invitation is not available, the invitation must be resent to issue a new token")
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionEmailSent,
+			Status:  metav1.ConditionFalse,
+			Reason:  userv1.ConditionReasonTokenUnavailable,
+			Message: "The token of the invitation is no longer available, resend the invitation to issue a new token.",
+		})
+		return ctrl.Result{}, r.Client.Status().Update(ctx, &inv)
 	}
 
+	// The token is only passed to the email, it must never be stored in the invitation.
+	mailInv := inv.DeepCopy()
+	mailInv.Status.Token = token
 	email := inv.Spec.Email
-	id, err := r.MailSender.Send(ctx, email, inv)
+	id, err := r.MailSender.Send(ctx, email, *mailInv)
 	if err != nil {
 		log.V(0).Error(err, "Error in e-mail backend")
 		r.failureCounter.Add(1)
-		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+		cond := metav1.Condition{
 			Type:    userv1.ConditionEmailSent,
 			Status:  metav1.ConditionFalse,
 			Reason:  userv1.ConditionReasonSendFailed,
 			Message: err.Error(),
-		})
-		return ctrl.Result{}, multierr.Append(err, r.Client.Status().Update(ctx, &inv))
+		}
+		return ctrl.Result{}, multierr.Append(err, updateStatus(ctx, r.Client, &inv, func(inv *userv1.Invitation) {
+			apimeta.SetStatusCondition(&inv.Status.Conditions, cond)
+		}))
 	}
 	r.successCounter.Add(1)
 
@@ -103,13 +136,34 @@ func (r *InvitationEmailReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	if id != "" {
 		message = fmt.Sprintf("Message ID: %s", id)
 	}
-	apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
-		Type:    userv1.ConditionEmailSent,
-		Status:  metav1.ConditionTrue,
-		Message: message,
+	err = updateStatus(ctx, r.Client, &inv, func(inv *userv1.Invitation) {
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionEmailSent,
+			Status:  metav1.ConditionTrue,
+			Message: message,
+		})
 	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.TokenHandoff.Delete(ctx, inv.Name)
+}
 
-	return ctrl.Result{}, r.Client.Status().Update(ctx, &inv)
+// token returns the token handed off for the invitation.
+// Invitations created before tokens were hashed still store their token.
+// Returns an empty token if the hand-off is missing or belongs to a previous token of the invitation.
+func (r *InvitationEmailReconciler) token(ctx context.Context, inv userv1.Invitation) (string, error) {
+	if inv.Status.TokenHash == "" {
+		return inv.Status.Token, nil
+	}
+	token, err := r.TokenHandoff.Get(ctx, inv.Name)
+	if err != nil || token == "" {
+		return "", err
+	}
+	if !invitationtoken.Verify(inv.Status.TokenHash, token) {
+		return "", nil
+	}
+	return token, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -117,15 +171,15 @@ func (r *InvitationEmailReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&userv1.Invitation{}).
 		WithOptions(controller.Options{
-			RateLimiter: r.rateLimiter(),
+			RateLimiter: emailRateLimiter(r.BaseRetryDelay),
 		}).
 		Complete(r)
 }
 
-func (r *InvitationEmailReconciler) rateLimiter() workqueue.RateLimiter {
+func emailRateLimiter(baseRetryDelay time.Duration) workqueue.RateLimiter {
 	// This is the default rate limiter for controllers with higher baseDelay if the reconciliation fails.
 	return workqueue.NewMaxOfRateLimiter(
-		workqueue.NewItemExponentialFailureRateLimiter(r.BaseRetryDelay, 5*time.Minute),
+		workqueue.NewItemExponentialFailureRateLimiter(baseRetryDelay, 5*time.Minute),
 		// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
 		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
 	)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -17,6 +18,7 @@ import (
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/pkg/invitationtoken"
 )
 
 type FailingSender struct{}
@@ -30,6 +32,22 @@ func (s *SenderWithConstantId) Send(context.Context, string, any) (string, error
 	return "ID10", nil
 }
 
+type recordingSender struct {
+	sent       []userv1.Invitation
+	recipients []string
+	// failFor are the recipients sending fails for
+	failFor map[string]bool
+}
+
+func (s *recordingSender) Send(_ context.Context, recipient string, obj any) (string, error) {
+	if s.failFor[recipient] {
+		return "", errors.New("Err0r")
+	}
+	s.sent = append(s.sent, obj.(userv1.Invitation))
+	s.recipients = append(s.recipients, recipient)
+	return "", nil
+}
+
 func Test_InvitationEmailReconciler_Reconcile_Success(t *testing.T) {
 	ctx := context.Background()
 
@@ -145,6 +163,82 @@ func Test_InvitationEmailReconciler_Reconcile_NoEmail_Success(t *testing.T) {
 	require.Nil(t, apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionEmailSent))
 }
 
+func Test_InvitationEmailReconciler_Reconcile_HandedOffToken(t *testing.T) {
+	ctx := context.Background()
+
+	token, err := invitationtoken.Generate()
+	require.NoError(t, err)
+	hash, err := invitationtoken.Hash(token)
+	require.NoError(t, err)
+
+	subject := baseInvitation()
+	subject.Status.Token = ""
+	subject.Status.TokenHash = hash
+	c := prepareTest(t, subject)
+
+	sender := &recordingSender{}
+	r := invitationEmailReconciler(c)
+	r.MailSender = sender
+	require.NoError(t, r.TokenHandoff.Put(ctx, subject.Name, token))
+
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, token, sender.sent[0].Status.Token)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionEmailSent))
+	assert.Empty(t, subject.Status.Token, "token must not be stored")
+	handedOff, err := r.TokenHandoff.Get(ctx, subject.Name)
+	require.NoError(t, err)
+	assert.Empty(t, handedOff, "hand-off must be removed once the email is sent")
+}
+
+func Test_InvitationEmailReconciler_Reconcile_TokenUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	hash, err := invitationtoken.Hash("current-token")
+	require.NoError(t, err)
+
+	subject := baseInvitation()
+	subject.Status.Token = ""
+	subject.Status.TokenHash = hash
+	c := prepareTest(t, subject)
+
+	sender := &recordingSender{}
+	r := invitationEmailReconciler(c)
+	r.MailSender = sender
+	require.NoError(t, r.TokenHandoff.Put(ctx, subject.Name, "previous-token"))
+
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, sender.sent, "previous tokens must not be sent")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.Equal(t, hash, subject.Status.TokenHash, "token must not be rotated")
+	condition := apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionEmailSent)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, userv1.ConditionReasonTokenUnavailable, condition.Reason)
+}
+
+func Test_InvitationEmailReconciler_Reconcile_DeletedRemovesHandoff(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseInvitation()
+	c := prepareTest(t)
+
+	r := invitationEmailReconciler(c)
+	require.NoError(t, r.TokenHandoff.Put(ctx, subject.Name, "token"))
+
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	handedOff, err := r.TokenHandoff.Get(ctx, subject.Name)
+	require.NoError(t, err)
+	assert.Empty(t, handedOff, "hand-off of deleted invitations must be removed")
+}
+
 func invitationEmailReconciler(c client.WithWatch) *InvitationEmailReconciler {
 	r := NewInvitationEmailReconciler(
 		c,
@@ -152,6 +246,7 @@ func invitationEmailReconciler(c client.WithWatch) *InvitationEmailReconciler {
 		c.Scheme(),
 		&SenderWithConstantId{},
 		time.Minute,
+		invitationtoken.Handoff{Client: c, Namespace: "invitation-tokens"},
 	)
 	return &r
 }
@@ -163,6 +258,7 @@ func invitationEmailReconcilerWithFailingSender(c client.WithWatch) *InvitationE
 		c.Scheme(),
 		&FailingSender{},
 		time.Minute,
+		invitationtoken.Handoff{Client: c, Namespace: "invitation-tokens"},
 	)
 	return &r
 }
@@ -21,6 +21,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
 	"github.com/appuio/control-api/controllers/targetref"
 )
 
@@ -32,6 +33,14 @@ type InvitationRedeemReconciler struct {
 	Scheme   *runtime.Scheme
 
 	UsernamePrefix string
+	// OrganizationRolePrefix is the prefix of the ClusterRoles granting the roles of role-scoped targets
+	OrganizationRolePrefix string
+	// MemberRoles are the ClusterRoles bound to organization members without roles.
+	// They are kept when a role-scoped target adds a role to an existing member.
+	MemberRoles []string
+	// TargetKinds holds the generic kinds supported as targets in addition to the built-in kinds.
+	// Only built-in kinds are supported if nil.
+	TargetKinds *targetref.Registry
 }
 
 //+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations,verbs=get;list;watch
@@ -56,11 +65,11 @@ func (r *InvitationRedeemReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, nil
 	}
 
-	if !inv.IsRedeemed() {
+	if !inv.IsRedeemed() && len(inv.Status.Redeemers) == 0 {
 		return ctrl.Result{}, nil
 	}
 
-	if inv.Status.RedeemedBy == "" {
+	if inv.Status.RedeemedBy == "" && len(inv.Status.Redeemers) == 0 {
 		return ctrl.Result{}, errors.New("redeemed invitation has no user")
 	}
 
@@ -68,35 +77,61 @@ func (r *InvitationRedeemReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{}, err
 	}
 
-	var errors []error
+	var errs []error
 	statusHasChanged := false
-	for i := range inv.Status.TargetStatuses {
-		if inv.Status.TargetStatuses[i].Condition.Status == metav1.ConditionTrue {
+	if inv.Status.RedeemedBy != "" {
+		changed, err := r.addUserToTargets(ctx, inv.Status.RedeemedBy, inv.Status.TargetStatuses)
+		statusHasChanged = statusHasChanged || changed
+		errs = append(errs, err)
+	}
+	for i := range inv.Status.Redeemers {
+		changed, err := r.addUserToTargets(ctx, inv.Status.Redeemers[i].Username, inv.Status.Redeemers[i].TargetStatuses)
+		statusHasChanged = statusHasChanged || changed
+		errs = append(errs, err)
+	}
+
+	if statusHasChanged {
+		// The update is rejected if users redeemed the invitation in the meantime, they are added on the next reconciliation.
+		// Adding users to targets is idempotent.
+		err := r.Client.Status().Update(ctx, &inv)
+		return ctrl.Result{}, multierr.Append(err, multierr.Combine(errs...))
+	}
+	return ctrl.Result{}, multierr.Combine(errs...)
+}
+
+// addUserToTargets adds the redeeming user to all targets not yet successfully reconciled and updates the target statuses in place.
+// It returns true if any target status was changed.
+func (r *InvitationRedeemReconciler) addUserToTargets(ctx context.Context, redeemedBy string, statuses []userv1.TargetStatus) (bool, error) {
+	var errs []error
+	statusHasChanged := false
+	for i := range statuses {
+		if statuses[i].Condition.Status == metav1.ConditionTrue {
 			continue
 		}
 
 		statusHasChanged = true
-		username := strings.TrimPrefix(inv.Status.RedeemedBy, r.UsernamePrefix)
-		err := addUserToTarget(ctx, r.Client, username, r.UsernamePrefix, inv.Status.TargetStatuses[i].TargetRef)
+		username := strings.TrimPrefix(redeemedBy, r.UsernamePrefix)
+		target := statuses[i].TargetRef
+		var err error
+		if targetref.HasOrganizationRole(target) {
+			err = addMemberWithRole(ctx, r.Client, username, targetref.OrganizationRole(target, r.OrganizationRolePrefix), r.MemberRoles, target)
+		} else {
+			err = addUserToTarget(ctx, r.Client, r.TargetKinds, username, r.UsernamePrefix, target)
+		}
 		if err != nil {
-			errors = append(errors, err)
-			inv.Status.TargetStatuses[i].Condition.LastTransitionTime = metav1.Now()
-			inv.Status.TargetStatuses[i].Condition.Message = err.Error()
-			inv.Status.TargetStatuses[i].Condition.Reason = metav1.StatusFailure
-			inv.Status.TargetStatuses[i].Condition.Status = metav1.ConditionFalse
+			errs = append(errs, err)
+			statuses[i].Condition.LastTransitionTime = metav1.Now()
+			statuses[i].Condition.Message = err.Error()
+			statuses[i].Condition.Reason = metav1.StatusFailure
+			statuses[i].Condition.Status = metav1.ConditionFalse
 			continue
 		}
-		inv.Status.TargetStatuses[i].Condition.LastTransitionTime = metav1.Now()
-		inv.Status.TargetStatuses[i].Condition.Message = ""
-		inv.Status.TargetStatuses[i].Condition.Reason = metav1.StatusSuccess
-		inv.Status.TargetStatuses[i].Condition.Status = metav1.ConditionTrue
-	}
-
-	if statusHasChanged {
-		err := r.Client.Status().Update(ctx, &inv)
-		return ctrl.Result{}, multierr.Append(err, multierr.Combine(errors...))
+		statuses[i].Condition.LastTransitionTime = metav1.Now()
+		statuses[i].Condition.Message = ""
+		statuses[i].Condition.Reason = metav1.StatusSuccess
+		statuses[i].Condition.Status = metav1.ConditionTrue
 	}
-	return ctrl.Result{}, multierr.Combine(errors...)
+	return statusHasChanged, multierr.Combine(errs...)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -108,13 +143,13 @@ func (r *InvitationRedeemReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func addUserToTarget(ctx context.Context, c client.Client, user, prefix string, target userv1.TargetRef) error {
-	o, err := targetref.GetTarget(ctx, c, target)
+func addUserToTarget(ctx context.Context, c client.Client, kinds *targetref.Registry, user, prefix string, target userv1.TargetRef) error {
+	o, err := kinds.GetTarget(ctx, c, target)
 	if err != nil {
 		return err
 	}
 
-	a, err := targetref.NewUserAccessor(o)
+	a, err := kinds.NewUserAccessor(o)
 	if err != nil {
 		return err
 	}
@@ -127,6 +162,32 @@ func addUserToTarget(ctx context.Context, c client.Client, user, prefix string,
 	return nil
 }
 
+// addMemberWithRole adds the user to the OrganizationMembers of the target with the given role.
+// The OrganizationMembers controller binds the role, members added with a role are not bound to the default member roles.
+// Existing members keep their roles, members without roles keep the default member roles.
+func addMemberWithRole(ctx context.Context, c client.Client, user, role string, defaultRoles []string, target userv1.TargetRef) error {
+	memb := &controlv1.OrganizationMembers{}
+	if err := c.Get(ctx, client.ObjectKey{Name: target.Name, Namespace: target.Namespace}, memb); err != nil {
+		return err
+	}
+
+	for i := range memb.Spec.UserRefs {
+		mr := &memb.Spec.UserRefs[i]
+		if mr.Name != user {
+			continue
+		}
+		roles := mr.RolesOrDefault(defaultRoles)
+		if isInSlice(roles, role) {
+			return nil
+		}
+		mr.Roles = append(append([]string{}, roles...), role)
+		return c.Update(ctx, memb)
+	}
+
+	memb.Spec.UserRefs = append(memb.Spec.UserRefs, controlv1.OrganizationMemberRef{Name: user, Roles: []string{role}})
+	return c.Update(ctx, memb)
+}
+
 func (r *InvitationRedeemReconciler) createRedeemerRole(ctx context.Context, inv *userv1.Invitation) error {
 	rolename := invRedeemRoleName(inv.Name)
 
@@ -148,40 +209,67 @@ func (r *InvitationRedeemReconciler) createRedeemerRole(ctx context.Context, inv
 		ObjectMeta: metav1.ObjectMeta{
 			Name: rolename,
 		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:     "User",
-				APIGroup: "rbac.authorization.k8s.io",
-				Name:     inv.Status.RedeemedBy,
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
+	}
+
+	if err := controllerutil.SetControllerReference(inv, role, r.Scheme); err != nil {
+		return fmt.Errorf("failed setting controller reference for %T/%s: %w", role, role.GetName(), err)
+	}
+	if err := r.Create(ctx, role); client.IgnoreAlreadyExists(err) != nil {
+		return fmt.Errorf("failed creating %T/%s: %w", role, role.GetName(), err)
+	} else if apierrors.IsAlreadyExists(err) {
+		log.FromContext(ctx).Error(err, "object already exists while redeeming invitation", "invitation", inv.Name)
+	}
+
+	// Multi-use invitations gain redeemers over time, the subjects are kept in sync with them.
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, rolebinding, func() error {
+		rolebinding.Subjects = redeemerSubjects(inv)
+		rolebinding.RoleRef = rbacv1.RoleRef{
 			Kind:     "ClusterRole",
 			APIGroup: "rbac.authorization.k8s.io",
 			Name:     rolename,
-		},
+		}
+		return controllerutil.SetControllerReference(inv, rolebinding, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed reconciling %T/%s: %w", rolebinding, rolebinding.GetName(), err)
 	}
 
-	for _, o := range [...]client.Object{role, rolebinding} {
-		if err := controllerutil.SetControllerReference(inv, o, r.Scheme); err != nil {
-			return fmt.Errorf("failed setting controller reference for %T/%s: %w", o, o.GetName(), err)
-		}
+	return nil
+}
 
-		if err := r.Create(ctx, o); client.IgnoreAlreadyExists(err) != nil {
-			if apierrors.IsAlreadyExists(err) {
-				log.FromContext(ctx).Error(err, "object already exists while redeeming invitation", "invitation", inv.Name)
-			} else {
-				return fmt.Errorf("failed creating %T/%s: %w", o, o.GetName(), err)
-			}
-		}
+// redeemerSubjects returns the RBAC subjects of all users who redeemed the invitation.
+func redeemerSubjects(inv *userv1.Invitation) []rbacv1.Subject {
+	users := make([]string, 0, len(inv.Status.Redeemers)+1)
+	if inv.Status.RedeemedBy != "" {
+		users = append(users, inv.Status.RedeemedBy)
+	}
+	for _, r := range inv.Status.Redeemers {
+		users = append(users, r.Username)
 	}
 
-	return nil
+	subjects := make([]rbacv1.Subject, len(users))
+	for i, u := range users {
+		subjects[i] = rbacv1.Subject{
+			Kind:     "User",
+			APIGroup: "rbac.authorization.k8s.io",
+			Name:     u,
+		}
+	}
+	return subjects
 }
 
 func invRedeemRoleName(objName string) string {
+	return invRoleName(objName, "-redeemer")
+}
+
+// invOwnerRoleName returns the name of the ClusterRole and ClusterRoleBinding granting the creator of the invitation access to it.
+// The apiserver creates them when the invitation is created.
+func invOwnerRoleName(objName string) string {
+	return invRoleName(objName, "-owner")
+}
+
+func invRoleName(objName, suffix string) string {
 	prefix := "invitations-"
-	suffix := "-redeemer"
 
 	if len(prefix)+len(suffix)+len(objName) <= 63 {
 		return fmt.Sprintf("%s%s%s", prefix, objName, suffix)
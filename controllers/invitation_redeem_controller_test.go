@@ -9,12 +9,14 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	controlv1 "github.com/appuio/control-api/apis/v1"
 	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/controllers/targetref"
 )
 
 func Test_InvitationRedeemReconciler_Reconcile_Success(t *testing.T) {
@@ -41,7 +43,7 @@ func Test_InvitationRedeemReconciler_Reconcile_Success(t *testing.T) {
 			Namespace: "example-organization-01",
 		},
 		Spec: controlv1.OrganizationMembersSpec{
-			UserRefs: []controlv1.UserRef{
+			UserRefs: []controlv1.OrganizationMemberRef{
 				{
 					Name: redeemedBy,
 				},
@@ -108,7 +110,7 @@ func Test_InvitationRedeemReconciler_Reconcile_Success(t *testing.T) {
 	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(team), team))
 	assert.Equal(t, []controlv1.UserRef{{Name: redeemedBy}}, team.Spec.UserRefs)
 	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(orgMem), orgMem))
-	assert.Equal(t, []controlv1.UserRef{{Name: redeemedBy}}, orgMem.Spec.UserRefs)
+	assert.Equal(t, []controlv1.OrganizationMemberRef{{Name: redeemedBy}}, orgMem.Spec.UserRefs)
 	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(rb), rb))
 	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: redeemedBy}}, rb.Subjects)
 	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(crb), crb))
@@ -227,6 +229,293 @@ func Test_InvitationRedeemReconciler_Reconcile_Fail_TargetNotFound(t *testing.T)
 	assert.Equal(t, metav1.ConditionFalse, subject.Status.TargetStatuses[0].Condition.Status)
 }
 
+func Test_InvitationRedeemReconciler_Reconcile_Role(t *testing.T) {
+	const redeemedBy = "example-user-01"
+	ctx := context.Background()
+
+	orgMem := &controlv1.OrganizationMembers{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "appuio.io/v1",
+			Kind:       "OrganizationMembers",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "members",
+			Namespace: "example-organization-01",
+		},
+	}
+	viewerRB := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "control-api:organization-viewer",
+			Namespace: "example-organization-01",
+		},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#other-user"}},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "control-api:organization-viewer"},
+	}
+
+	viewerTarget := targetRefFromObject(orgMem)
+	viewerTarget.Role = "viewer"
+	adminTarget := targetRefFromObject(orgMem)
+	adminTarget.Role = "admin"
+
+	subject := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Status: userv1.InvitationStatus{
+			RedeemedBy: redeemedBy,
+			TargetStatuses: []userv1.TargetStatus{
+				{TargetRef: viewerTarget},
+				{TargetRef: adminTarget},
+			},
+		},
+	}
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+
+	c := prepareTest(t, orgMem, viewerRB, subject)
+
+	r := invitationRedeemReconciler(c)
+	r.UsernamePrefix = "appuio#"
+	r.OrganizationRolePrefix = "control-api:organization-"
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	for _, targetStatus := range subject.Status.TargetStatuses {
+		assert.Equal(t, metav1.ConditionTrue, targetStatus.Condition.Status)
+	}
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(orgMem), orgMem))
+	assert.Equal(t, []controlv1.OrganizationMemberRef{
+		{Name: redeemedBy, Roles: []string{"control-api:organization-viewer", "control-api:organization-admin"}},
+	}, orgMem.Spec.UserRefs, "roles must be granted through the organization members, without the default member roles")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(viewerRB), viewerRB))
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#other-user"},
+	}, viewerRB.Subjects, "organization role bindings must not be changed")
+
+	rbs := &rbacv1.RoleBindingList{}
+	require.NoError(t, c.List(ctx, rbs, client.InNamespace("example-organization-01")))
+	assert.Len(t, rbs.Items, 1, "no role bindings must be created")
+}
+
+func Test_InvitationRedeemReconciler_Reconcile_Role_ExistingMember(t *testing.T) {
+	const redeemedBy = "example-user-01"
+	ctx := context.Background()
+
+	orgMem := &controlv1.OrganizationMembers{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "members",
+			Namespace: "example-organization-01",
+		},
+		Spec: controlv1.OrganizationMembersSpec{
+			UserRefs: []controlv1.OrganizationMemberRef{{Name: "other-user"}, {Name: redeemedBy}},
+		},
+	}
+	target := userv1.TargetRef{APIGroup: "appuio.io", Kind: "OrganizationMembers", Name: "members", Namespace: "example-organization-01", Role: "admin"}
+
+	subject := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Status: userv1.InvitationStatus{
+			RedeemedBy:     redeemedBy,
+			TargetStatuses: []userv1.TargetStatus{{TargetRef: target}},
+		},
+	}
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+
+	c := prepareTest(t, orgMem, subject)
+
+	r := invitationRedeemReconciler(c)
+	r.UsernamePrefix = "appuio#"
+	r.OrganizationRolePrefix = "control-api:organization-"
+	r.MemberRoles = []string{"control-api:organization-viewer"}
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(orgMem), orgMem))
+	assert.Equal(t, []controlv1.OrganizationMemberRef{
+		{Name: "other-user"},
+		{Name: redeemedBy, Roles: []string{"control-api:organization-viewer", "control-api:organization-admin"}},
+	}, orgMem.Spec.UserRefs, "existing members must keep the default member roles")
+}
+
+func Test_InvitationRedeemReconciler_Reconcile_BillingEntity(t *testing.T) {
+	const redeemedBy = "example-user-01"
+	ctx := context.Background()
+
+	viewers := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "billingentities-be-1234-viewer"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "billingentities-be-1234-viewer"},
+	}
+	admins := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "billingentities-be-1234-admin"},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "billingentities-be-1234-admin"},
+	}
+
+	subject := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Status: userv1.InvitationStatus{
+			RedeemedBy: redeemedBy,
+			TargetStatuses: []userv1.TargetStatus{
+				{TargetRef: userv1.TargetRef{APIGroup: "billing.appuio.io", Kind: "BillingEntity", Name: "be-1234", Role: "admin"}},
+			},
+		},
+	}
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+
+	c := prepareTest(t, viewers, admins, subject)
+
+	r := invitationRedeemReconciler(c)
+	r.UsernamePrefix = "appuio#"
+	r.OrganizationRolePrefix = "control-api:organization-"
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.Equal(t, metav1.ConditionTrue, subject.Status.TargetStatuses[0].Condition.Status)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(admins), admins))
+	assert.Equal(t, []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#" + redeemedBy}}, admins.Subjects)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(viewers), viewers))
+	assert.Empty(t, viewers.Subjects)
+
+	rbs := &rbacv1.RoleBindingList{}
+	require.NoError(t, c.List(ctx, rbs))
+	assert.Empty(t, rbs.Items, "billing entity roles must not create organization role bindings")
+}
+
+func Test_InvitationRedeemReconciler_Reconcile_GenericTarget(t *testing.T) {
+	const redeemedBy = "example-user-01"
+	ctx := context.Background()
+
+	kinds, err := targetref.NewRegistry(targetref.GenericKind{
+		Group:          "projects.example.com",
+		Version:        "v1",
+		Kind:           "Project",
+		UsersPath:      "spec.members",
+		NameField:      "name",
+		UsernamePrefix: true,
+	})
+	require.NoError(t, err)
+
+	project := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"members": []interface{}{}},
+	}}
+	project.SetAPIVersion("projects.example.com/v1")
+	project.SetKind("Project")
+	project.SetName("project")
+	project.SetNamespace("example-organization-01")
+
+	subject := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Status: userv1.InvitationStatus{
+			RedeemedBy: redeemedBy,
+			TargetStatuses: []userv1.TargetStatus{
+				{TargetRef: userv1.TargetRef{APIGroup: "projects.example.com", Kind: "Project", Name: "project", Namespace: "example-organization-01"}},
+			},
+		},
+	}
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+
+	c := prepareTest(t, project, subject)
+
+	r := invitationRedeemReconciler(c)
+	r.UsernamePrefix = "appuio#"
+	r.TargetKinds = kinds
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.Equal(t, metav1.ConditionTrue, subject.Status.TargetStatuses[0].Condition.Status)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(project), project))
+	members, _, err := unstructured.NestedSlice(project.Object, "spec", "members")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "appuio#" + redeemedBy}}, members)
+}
+
+func Test_InvitationRedeemReconciler_Reconcile_MultiUse(t *testing.T) {
+	ctx := context.Background()
+
+	team := &controlv1.Team{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "appuio.io/v1",
+			Kind:       "Team",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-team-01",
+			Namespace: "example-organization-01",
+		},
+	}
+
+	subject := &userv1.Invitation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "subject",
+		},
+		Spec: userv1.InvitationSpec{
+			MaxRedemptions: 3,
+		},
+		Status: userv1.InvitationStatus{
+			Redeemers: []userv1.Redeemer{
+				{Username: "user-1", TargetStatuses: []userv1.TargetStatus{{TargetRef: targetRefFromObject(team)}}},
+				{Username: "user-2", TargetStatuses: []userv1.TargetStatus{{TargetRef: targetRefFromObject(team)}}},
+			},
+		},
+	}
+
+	c := prepareTest(t, team, subject)
+
+	r := invitationRedeemReconciler(c)
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err, "partially redeemed invitations should be reconciled")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	for _, redeemer := range subject.Status.Redeemers {
+		for _, targetStatus := range redeemer.TargetStatuses {
+			assert.Equal(t, metav1.ConditionTrue, targetStatus.Condition.Status)
+		}
+	}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(team), team))
+	assert.Equal(t, []controlv1.UserRef{{Name: "user-1"}, {Name: "user-2"}}, team.Spec.UserRefs)
+
+	subject.Status.Redeemers = append(subject.Status.Redeemers, userv1.Redeemer{
+		Username:       "user-3",
+		TargetStatuses: []userv1.TargetStatus{{TargetRef: targetRefFromObject(team)}},
+	})
+	require.NoError(t, c.Status().Update(ctx, subject))
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(team), team))
+	assert.Equal(t, []controlv1.UserRef{{Name: "user-1"}, {Name: "user-2"}, {Name: "user-3"}}, team.Spec.UserRefs)
+
+	rolebinding := &rbacv1.ClusterRoleBinding{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "invitations-subject-redeemer"}, rolebinding))
+	assert.Equal(t, []rbacv1.Subject{
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "user-1"},
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "user-2"},
+		{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "user-3"},
+	}, rolebinding.Subjects, "every redeeming user should have read access to the invitation")
+}
+
 func invitationRedeemReconciler(c client.WithWatch) *InvitationRedeemReconciler {
 	return &InvitationRedeemReconciler{
 		Client:   c,
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/exp/slices"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/appuio/control-api/mailsenders"
+	"github.com/prometheus/client_golang/prometheus"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+// InvitationReminderReconciler reconciles invitations and sends reminder emails before they expire.
+// It also notifies the inviter if an invitation expired without being redeemed.
+// Sent emails are recorded as conditions on the invitation, so they are sent at most once.
+type InvitationReminderReconciler struct {
+	client.Client
+
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	// ReminderMailSender sends the reminder to the invited user.
+	ReminderMailSender mailsenders.MailSender
+	// RemindBefore is the duration before the invitation expires the reminder is sent.
+	// Zero disables reminders.
+	RemindBefore time.Duration
+	// ExpiredMailSender sends the notification about an expired invitation to the inviter.
+	// Nil disables the notification.
+	ExpiredMailSender mailsenders.MailSender
+	// UsernamePrefix is the prefix of the inviting users, it is removed to find their User resource.
+	UsernamePrefix string
+	BaseRetryDelay time.Duration
+
+	failureCounter prometheus.Counter
+	successCounter prometheus.Counter
+}
+
+func NewInvitationReminderReconciler(client client.Client, eventRecorder record.EventRecorder, scheme *runtime.Scheme, reminderMailSender mailsenders.MailSender, remindBefore time.Duration, expiredMailSender mailsenders.MailSender, usernamePrefix string, baseRetryDelay time.Duration) InvitationReminderReconciler {
+	return InvitationReminderReconciler{
+		Client:             client,
+		Recorder:           eventRecorder,
+		Scheme:             scheme,
+		ReminderMailSender: reminderMailSender,
+		RemindBefore:       remindBefore,
+		ExpiredMailSender:  expiredMailSender,
+		UsernamePrefix:     usernamePrefix,
+		BaseRetryDelay:     baseRetryDelay,
+		failureCounter:     newFailureCounter("control_api_invitation_reminder_emails"),
+		successCounter:     newSuccessCounter("control_api_invitation_reminder_emails"),
+	}
+}
+
+func (r *InvitationReminderReconciler) GetMetrics() prometheus.Collector {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(r.failureCounter)
+	reg.MustRegister(r.successCounter)
+	return reg
+}
+
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations,verbs=get;list;watch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=invitations,verbs=get;list;watch
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=invitations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=invitations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=appuio.io,resources=users,verbs=get;list;watch
+
+// Reconcile sends a reminder email to the invited user if the invitation expires soon.
+// If the invitation expired without being redeemed, the inviter is notified.
+func (r *InvitationReminderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	inv := userv1.Invitation{}
+	if err := r.Get(ctx, req.NamespacedName, &inv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !inv.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if !inv.HasToken() || inv.IsRedeemed() || inv.IsRevoked() {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	if inv.Status.ValidUntil.After(now) {
+		res, err := r.remind(ctx, &inv, now)
+		if r.ExpiredMailSender != nil {
+			// Requeue once the invitation expired to notify the inviter
+			expiresIn := inv.Status.ValidUntil.Sub(now) + time.Second
+			if res.RequeueAfter == 0 || expiresIn < res.RequeueAfter {
+				res.RequeueAfter = expiresIn
+			}
+		}
+		return res, err
+	}
+	if len(inv.Status.Redeemers) > 0 {
+		// Partially redeemed multi-use invitations did not expire unredeemed
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, r.notifyExpired(ctx, &inv)
+}
+
+// remind sends the reminder to the invited user once the reminder period before the expiry started.
+// Invitations whose first email was sent within the reminder period don't get a reminder.
+// Only the hash of the token is stored, the reminder refers to the link in the invitation email and never contains the token.
+// Locked invitations can't be redeemed with the token and don't get a reminder.
+func (r *InvitationReminderReconciler) remind(ctx context.Context, inv *userv1.Invitation, now time.Time) (ctrl.Result, error) {
+	if r.RemindBefore <= 0 || inv.Spec.Email == "" || inv.IsLocked() || apimeta.IsStatusConditionTrue(inv.Status.Conditions, userv1.ConditionReminderSent) {
+		return ctrl.Result{}, nil
+	}
+	sent := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionEmailSent)
+	if sent == nil || sent.Status != metav1.ConditionTrue {
+		return ctrl.Result{}, nil
+	}
+	remindAt := inv.Status.ValidUntil.Add(-r.RemindBefore)
+	if !sent.LastTransitionTime.Time.Before(remindAt) {
+		return ctrl.Result{}, nil
+	}
+	if now.Before(remindAt) {
+		return ctrl.Result{RequeueAfter: remindAt.Sub(now)}, nil
+	}
+
+	// Invitations created before tokens were hashed still store their token
+	mailInv := inv.DeepCopy()
+	mailInv.Status.Token = ""
+	id, err := r.ReminderMailSender.Send(ctx, inv.Spec.Email, *mailInv)
+	return ctrl.Result{}, r.recordSend(ctx, inv, userv1.ConditionReminderSent, []string{id}, err, nil)
+}
+
+// notifyExpired sends the notification about the expired invitation to the inviter.
+// The inviter is the subject of the ClusterRoleBinding created for the creator of the invitation.
+// Every recipient is recorded once notified, a failed notification is only retried for the recipients not notified yet.
+func (r *InvitationReminderReconciler) notifyExpired(ctx context.Context, inv *userv1.Invitation) error {
+	if r.ExpiredMailSender == nil || expiryNotified(inv) {
+		return nil
+	}
+
+	recipients, err := r.inviterEmails(ctx, inv)
+	if err != nil {
+		return fmt.Errorf("failed to get inviter: %w", err)
+	}
+	if len(recipients) == 0 {
+		apimeta.SetStatusCondition(&inv.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionExpiryNotificationSent,
+			Status:  metav1.ConditionFalse,
+			Reason:  userv1.ConditionReasonNoRecipient,
+			Message: "No e-mail address of the inviter found",
+		})
+		return r.Client.Status().Update(ctx, inv)
+	}
+
+	ids := make([]string, 0, len(recipients))
+	notified := make([]string, 0, len(recipients))
+	var errs []error
+	for _, recipient := range recipients {
+		if slices.Contains(inv.Status.ExpiryNotificationRecipients, recipient) {
+			continue
+		}
+		id, err := r.ExpiredMailSender.Send(ctx, recipient, *inv)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify %q: %w", recipient, err))
+			continue
+		}
+		ids = append(ids, id)
+		notified = append(notified, recipient)
+	}
+	return r.recordSend(ctx, inv, userv1.ConditionExpiryNotificationSent, ids, multierr.Combine(errs...), func(inv *userv1.Invitation) {
+		for _, recipient := range notified {
+			if !slices.Contains(inv.Status.ExpiryNotificationRecipients, recipient) {
+				inv.Status.ExpiryNotificationRecipients = append(inv.Status.ExpiryNotificationRecipients, recipient)
+			}
+		}
+	})
+}
+
+// recordSend counts the sent email and records the result in the given condition.
+// The status is updated even if sending failed, so recipients notified before the failure are persisted.
+// The optional change records the sent emails in the status, it is applied together with the condition.
+func (r *InvitationReminderReconciler) recordSend(ctx context.Context, inv *userv1.Invitation, conditionType string, ids []string, sendErr error, change func(*userv1.Invitation)) error {
+	cond := metav1.Condition{
+		Type:   conditionType,
+		Status: metav1.ConditionTrue,
+		Reason: conditionType,
+	}
+	if sendErr != nil {
+		log.FromContext(ctx).V(0).Error(sendErr, "Error in e-mail backend")
+		r.failureCounter.Add(1)
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = userv1.ConditionReasonSendFailed
+		cond.Message = sendErr.Error()
+	} else {
+		r.successCounter.Add(1)
+		if ids := nonEmpty(ids); len(ids) > 0 {
+			cond.Message = fmt.Sprintf("Message ID: %s", strings.Join(ids, ", "))
+		}
+	}
+
+	return multierr.Append(sendErr, updateStatus(ctx, r.Client, inv, func(inv *userv1.Invitation) {
+		if change != nil {
+			change(inv)
+		}
+		apimeta.SetStatusCondition(&inv.Status.Conditions, cond)
+	}))
+}
+
+// inviterEmails returns the e-mail addresses of the users owning the invitation.
+func (r *InvitationReminderReconciler) inviterEmails(ctx context.Context, inv *userv1.Invitation) ([]string, error) {
+	owner := rbacv1.ClusterRoleBinding{}
+	if err := r.Get(ctx, client.ObjectKey{Name: invOwnerRoleName(inv.Name)}, &owner); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	emails := []string{}
+	for _, s := range owner.Subjects {
+		if s.Kind != rbacv1.UserKind || !strings.HasPrefix(s.Name, r.UsernamePrefix) {
+			continue
+		}
+		user := controlv1.User{}
+		err := r.Get(ctx, client.ObjectKey{Name: strings.TrimPrefix(s.Name, r.UsernamePrefix)}, &user)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user.Status.Email != "" {
+			emails = append(emails, user.Status.Email)
+		}
+	}
+	return emails, nil
+}
+
+// expiryNotified returns true if the inviter was notified about the expired invitation or can't be notified.
+func expiryNotified(inv *userv1.Invitation) bool {
+	cond := apimeta.FindStatusCondition(inv.Status.Conditions, userv1.ConditionExpiryNotificationSent)
+	return cond != nil && (cond.Status == metav1.ConditionTrue || cond.Reason == userv1.ConditionReasonNoRecipient)
+}
+
+func nonEmpty(s []string) []string {
+	r := make([]string, 0, len(s))
+	for _, v := range s {
+		if v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *InvitationReminderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.Invitation{}).
+		WithOptions(controller.Options{
+			RateLimiter: emailRateLimiter(r.BaseRetryDelay),
+		}).
+		Complete(r)
+}
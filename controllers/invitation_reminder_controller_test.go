@@ -0,0 +1,266 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/mailsenders"
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+func Test_InvitationReminderReconciler_Reconcile_Reminder(t *testing.T) {
+	ctx := context.Background()
+
+	hash, err := invitationtoken.Hash("token")
+	require.NoError(t, err)
+	subject := reminderInvitation(time.Hour, -48*time.Hour)
+	subject.Status.Token = ""
+	subject.Status.TokenHash = hash
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	r := invitationReminderReconciler(c, reminders, nil)
+
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.Len(t, reminders.sent, 1)
+	assert.Equal(t, []string{"subject@example.com"}, reminders.recipients)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionReminderSent))
+	assert.Empty(t, reminders.sent[0].Status.Token, "reminder must not contain a token")
+	assert.Equal(t, hash, subject.Status.TokenHash, "token must not be rotated for the reminder")
+
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+	assert.Len(t, reminders.sent, 1, "reminder must only be sent once")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Locked(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(time.Hour, -48*time.Hour)
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionLocked,
+		Status: metav1.ConditionTrue,
+		Reason: userv1.ConditionReasonTooManyFailedAttempts,
+	})
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	_, err := invitationReminderReconciler(c, reminders, nil).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, reminders.sent, "locked invitations must not get a reminder")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_ReminderLegacyToken(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(time.Hour, -48*time.Hour)
+	subject.Status.Token = "legacy"
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	_, err := invitationReminderReconciler(c, reminders, nil).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	require.Len(t, reminders.sent, 1)
+	assert.Empty(t, reminders.sent[0].Status.Token, "reminder must not contain the stored token of invitations created before tokens were hashed")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_ReminderNotDue(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(48*time.Hour, -48*time.Hour)
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	res, err := invitationReminderReconciler(c, reminders, nil).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, reminders.sent)
+	assert.InDelta(t, 24*time.Hour, res.RequeueAfter, float64(time.Minute), "should requeue once the reminder is due")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_FirstEmailWithinReminderPeriod(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(time.Hour, -time.Minute)
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	_, err := invitationReminderReconciler(c, reminders, nil).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, reminders.sent, "no reminder should be sent right after the invitation email")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Expired(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(-time.Minute, -48*time.Hour)
+	owner := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "invitations-subject-owner"},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#inviter"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#unknown"},
+		},
+	}
+	inviter := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "inviter"},
+		Status:     controlv1.UserStatus{Email: "inviter@example.com"},
+	}
+	c := prepareTest(t, subject, owner, inviter)
+
+	reminders := &recordingSender{}
+	notifications := &recordingSender{}
+	r := invitationReminderReconciler(c, reminders, notifications)
+
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, reminders.sent)
+	assert.Equal(t, []string{"inviter@example.com"}, notifications.recipients)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionExpiryNotificationSent))
+
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+	assert.Len(t, notifications.sent, 1, "notification must only be sent once")
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Expired_NoInviter(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(-time.Minute, -48*time.Hour)
+	c := prepareTest(t, subject)
+
+	notifications := &recordingSender{}
+	_, err := invitationReminderReconciler(c, &recordingSender{}, notifications).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, notifications.sent)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	condition := apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionExpiryNotificationSent)
+	require.NotNil(t, condition)
+	assert.Equal(t, userv1.ConditionReasonNoRecipient, condition.Reason)
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Expired_SendFailure(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(-time.Minute, -48*time.Hour)
+	owner := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "invitations-subject-owner"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#inviter"}},
+	}
+	inviter := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "inviter"},
+		Status:     controlv1.UserStatus{Email: "inviter@example.com"},
+	}
+	c := prepareTest(t, subject, owner, inviter)
+
+	_, err := invitationReminderReconciler(c, &recordingSender{}, &FailingSender{}).Reconcile(ctx, requestFor(subject))
+	require.Error(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	condition := apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionExpiryNotificationSent)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, userv1.ConditionReasonSendFailed, condition.Reason)
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Expired_PartialSendFailure(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(-time.Minute, -48*time.Hour)
+	owner := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "invitations-subject-owner"},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#inviter"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#other"},
+		},
+	}
+	inviter := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "inviter"},
+		Status:     controlv1.UserStatus{Email: "inviter@example.com"},
+	}
+	other := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "other"},
+		Status:     controlv1.UserStatus{Email: "other@example.com"},
+	}
+	c := prepareTest(t, subject, owner, inviter, other)
+
+	notifications := &recordingSender{failFor: map[string]bool{"other@example.com": true}}
+	r := invitationReminderReconciler(c, &recordingSender{}, notifications)
+
+	_, err := r.Reconcile(ctx, requestFor(subject))
+	require.Error(t, err)
+	assert.Equal(t, []string{"inviter@example.com"}, notifications.recipients)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.Equal(t, []string{"inviter@example.com"}, subject.Status.ExpiryNotificationRecipients)
+	assert.False(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionExpiryNotificationSent))
+
+	notifications.failFor = nil
+	_, err = r.Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inviter@example.com", "other@example.com"}, notifications.recipients, "notified recipients must not be notified again")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionExpiryNotificationSent))
+}
+
+func Test_InvitationReminderReconciler_Reconcile_Redeemed(t *testing.T) {
+	ctx := context.Background()
+
+	subject := reminderInvitation(time.Hour, -48*time.Hour)
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionRedeemed,
+		Status: metav1.ConditionTrue,
+	})
+	c := prepareTest(t, subject)
+
+	reminders := &recordingSender{}
+	_, err := invitationReminderReconciler(c, reminders, nil).Reconcile(ctx, requestFor(subject))
+	require.NoError(t, err)
+
+	assert.Empty(t, reminders.sent)
+}
+
+// reminderInvitation returns an invitation expiring in validFor whose invitation email was sent emailSentAgo.
+func reminderInvitation(validFor, emailSentAgo time.Duration) *userv1.Invitation {
+	inv := baseInvitation()
+	inv.Status.ValidUntil = metav1.NewTime(time.Now().Add(validFor))
+	inv.Status.Conditions = []metav1.Condition{{
+		Type:               userv1.ConditionEmailSent,
+		Status:             metav1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(time.Now().Add(emailSentAgo)),
+	}}
+	return inv
+}
+
+func invitationReminderReconciler(c client.WithWatch, reminderSender, expiredSender mailsenders.MailSender) *InvitationReminderReconciler {
+	r := NewInvitationReminderReconciler(
+		c,
+		record.NewFakeRecorder(3),
+		c.Scheme(),
+		reminderSender,
+		24*time.Hour,
+		expiredSender,
+		"appuio#",
+		time.Minute,
+	)
+	return &r
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+// JoinRequestReconciler reconciles join requests and adds the requester to the organization once the join request is approved.
+type JoinRequestReconciler struct {
+	client.Client
+
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	// UsernamePrefix is the prefix of the requesting users, it is removed before adding them to the organization.
+	UsernamePrefix string
+}
+
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+
+//+kubebuilder:rbac:groups=appuio.io,resources=organizationmembers,verbs=get;list;watch;update;patch
+
+// Reconcile reacts to approved join requests and adds the requester to the OrganizationMembers of the organization.
+func (r *JoinRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	jr := userv1.JoinRequest{}
+	if err := r.Get(ctx, req.NamespacedName, &jr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !jr.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if !jr.IsApproved() || jr.HasJoined() {
+		return ctrl.Result{}, nil
+	}
+
+	if jr.Status.Requester == "" {
+		return ctrl.Result{}, errors.New("approved join request has no requester")
+	}
+
+	username := strings.TrimPrefix(jr.Status.Requester, r.UsernamePrefix)
+	err := addUserToTarget(ctx, r.Client, nil, username, r.UsernamePrefix, userv1.TargetRef{
+		APIGroup:  controlv1.GroupVersion.Group,
+		Kind:      "OrganizationMembers",
+		Namespace: jr.Namespace,
+		Name:      "members",
+	})
+	if err != nil {
+		r.Recorder.Eventf(&jr, "Warning", "AddUserFailed", "Failed to add user %q to organization %q: %s", username, jr.Namespace, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	apimeta.SetStatusCondition(&jr.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionJoined,
+		Status: metav1.ConditionTrue,
+		Reason: userv1.ConditionJoined,
+	})
+	return ctrl.Result{}, r.Client.Status().Update(ctx, &jr)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JoinRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.JoinRequest{}).
+		Complete(r)
+}
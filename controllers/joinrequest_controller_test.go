@@ -0,0 +1,94 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+)
+
+func Test_JoinRequestReconciler_Reconcile_Approved(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionApproved,
+		Status: metav1.ConditionTrue,
+		Reason: userv1.ConditionApproved,
+	})
+	members := &controlv1.OrganizationMembers{
+		ObjectMeta: metav1.ObjectMeta{Name: "members", Namespace: subject.Namespace},
+		Spec: controlv1.OrganizationMembersSpec{
+			UserRefs: []controlv1.OrganizationMemberRef{{Name: "member"}},
+		},
+	}
+	c := prepareTest(t, subject, members)
+
+	_, err := joinRequestReconciler(c).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(members), members))
+	assert.Equal(t, []controlv1.OrganizationMemberRef{{Name: "member"}, {Name: "requester"}}, members.Spec.UserRefs)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, subject.HasJoined())
+}
+
+func Test_JoinRequestReconciler_Reconcile_Pending(t *testing.T) {
+	ctx := context.Background()
+
+	for name, conditions := range map[string][]metav1.Condition{
+		"pending":  nil,
+		"rejected": {{Type: userv1.ConditionApproved, Status: metav1.ConditionFalse, Reason: userv1.ConditionReasonRejected}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			subject := baseJoinRequest()
+			subject.Status.Conditions = conditions
+			members := &controlv1.OrganizationMembers{
+				ObjectMeta: metav1.ObjectMeta{Name: "members", Namespace: subject.Namespace},
+			}
+			c := prepareTest(t, subject, members)
+
+			_, err := joinRequestReconciler(c).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+			require.NoError(t, err)
+
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(members), members))
+			assert.Empty(t, members.Spec.UserRefs)
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+			assert.False(t, subject.HasJoined())
+		})
+	}
+}
+
+func baseJoinRequest() *userv1.JoinRequest {
+	return &userv1.JoinRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "join",
+			Namespace: "acme-corp",
+		},
+		Spec: userv1.JoinRequestSpec{
+			Message: "let me in",
+		},
+		Status: userv1.JoinRequestStatus{
+			Requester: "appuio#requester",
+		},
+	}
+}
+
+func joinRequestReconciler(c client.WithWatch) *JoinRequestReconciler {
+	return &JoinRequestReconciler{
+		Client:         c,
+		Scheme:         c.Scheme(),
+		Recorder:       record.NewFakeRecorder(3),
+		UsernamePrefix: "appuio#",
+	}
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"golang.org/x/exp/slices"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/appuio/control-api/mailsenders"
+	"github.com/prometheus/client_golang/prometheus"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/orgadmins"
+)
+
+// JoinRequestEmailReconciler reconciles join requests and notifies the organization admins about pending join requests.
+// Sent emails are recorded as condition on the join request, so the admins are notified at most once.
+type JoinRequestEmailReconciler struct {
+	client.Client
+
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	MailSender mailsenders.MailSender
+	// UsernamePrefix is the prefix of the organization admins, it is removed to find their User resource.
+	UsernamePrefix string
+	// Admins resolves the organization admins from the RoleBindings in the organization namespace.
+	// It is shared with the organization admin webhook, so the admins notified are the admins protected by the webhook.
+	Admins         orgadmins.Resolver
+	BaseRetryDelay time.Duration
+
+	failureCounter prometheus.Counter
+	successCounter prometheus.Counter
+}
+
+func NewJoinRequestEmailReconciler(client client.Client, eventRecorder record.EventRecorder, scheme *runtime.Scheme, mailSender mailsenders.MailSender, usernamePrefix string, admins orgadmins.Resolver, baseRetryDelay time.Duration) JoinRequestEmailReconciler {
+	return JoinRequestEmailReconciler{
+		Client:         client,
+		Recorder:       eventRecorder,
+		Scheme:         scheme,
+		MailSender:     mailSender,
+		UsernamePrefix: usernamePrefix,
+		Admins:         admins,
+		BaseRetryDelay: baseRetryDelay,
+		failureCounter: newFailureCounter("control_api_join_request_emails"),
+		successCounter: newSuccessCounter("control_api_join_request_emails"),
+	}
+}
+
+func (r *JoinRequestEmailReconciler) GetMetrics() prometheus.Collector {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(r.failureCounter)
+	reg.MustRegister(r.successCounter)
+	return reg
+}
+
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests,verbs=get;list;watch
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="user.appuio.io",resources=joinrequests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
+//+kubebuilder:rbac:groups=appuio.io,resources=users,verbs=get;list;watch
+
+// Reconcile sends an email to the admins of the organization if a join request is pending.
+func (r *JoinRequestEmailReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	jr := userv1.JoinRequest{}
+	if err := r.Get(ctx, req.NamespacedName, &jr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !jr.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if jr.IsDecided() || jr.Status.Requester == "" {
+		return ctrl.Result{}, nil
+	}
+	cond := apimeta.FindStatusCondition(jr.Status.Conditions, userv1.ConditionEmailSent)
+	if cond != nil && (cond.Status == metav1.ConditionTrue || cond.Reason == userv1.ConditionReasonNoRecipient) {
+		return ctrl.Result{}, nil
+	}
+
+	recipients, err := r.adminEmails(ctx, jr.Namespace)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get organization admins: %w", err)
+	}
+	if len(recipients) == 0 {
+		apimeta.SetStatusCondition(&jr.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionEmailSent,
+			Status:  metav1.ConditionFalse,
+			Reason:  userv1.ConditionReasonNoRecipient,
+			Message: "No e-mail address of an organization admin found",
+		})
+		return ctrl.Result{}, r.Client.Status().Update(ctx, &jr)
+	}
+
+	ids := make([]string, 0, len(recipients))
+	notified := make([]string, 0, len(recipients))
+	var errs []error
+	for _, recipient := range recipients {
+		if slices.Contains(jr.Status.NotificationRecipients, recipient) {
+			continue
+		}
+		id, err := r.MailSender.Send(ctx, recipient, jr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to notify %q: %w", recipient, err))
+			continue
+		}
+		ids = append(ids, id)
+		notified = append(notified, recipient)
+	}
+	recordNotified := func(jr *userv1.JoinRequest) {
+		for _, recipient := range notified {
+			if !slices.Contains(jr.Status.NotificationRecipients, recipient) {
+				jr.Status.NotificationRecipients = append(jr.Status.NotificationRecipients, recipient)
+			}
+		}
+	}
+	if err := multierr.Combine(errs...); err != nil {
+		log.V(0).Error(err, "Error in e-mail backend")
+		r.failureCounter.Add(1)
+		cond := metav1.Condition{
+			Type:    userv1.ConditionEmailSent,
+			Status:  metav1.ConditionFalse,
+			Reason:  userv1.ConditionReasonSendFailed,
+			Message: err.Error(),
+		}
+		// Admins notified before the failure are recorded, so they are not notified again when retrying.
+		return ctrl.Result{}, multierr.Append(err, updateStatus(ctx, r.Client, &jr, func(jr *userv1.JoinRequest) {
+			recordNotified(jr)
+			apimeta.SetStatusCondition(&jr.Status.Conditions, cond)
+		}))
+	}
+	r.successCounter.Add(1)
+
+	var message string
+	if ids := nonEmpty(ids); len(ids) > 0 {
+		message = fmt.Sprintf("Message ID: %s", strings.Join(ids, ", "))
+	}
+	return ctrl.Result{}, updateStatus(ctx, r.Client, &jr, func(jr *userv1.JoinRequest) {
+		recordNotified(jr)
+		apimeta.SetStatusCondition(&jr.Status.Conditions, metav1.Condition{
+			Type:    userv1.ConditionEmailSent,
+			Status:  metav1.ConditionTrue,
+			Reason:  userv1.ConditionEmailSent,
+			Message: message,
+		})
+	})
+}
+
+// adminEmails returns the e-mail addresses of the users bound to one of the admin roles of the organization.
+// Admins bound through several RoleBindings are only returned once.
+func (r *JoinRequestEmailReconciler) adminEmails(ctx context.Context, organization string) ([]string, error) {
+	rbs := rbacv1.RoleBindingList{}
+	if err := r.List(ctx, &rbs, client.InNamespace(organization)); err != nil {
+		return nil, err
+	}
+
+	emails := []string{}
+	for _, s := range r.Admins.Subjects(rbs.Items, nil) {
+		if s.Kind != rbacv1.UserKind || !strings.HasPrefix(s.Name, r.UsernamePrefix) {
+			continue
+		}
+		user := controlv1.User{}
+		err := r.Get(ctx, client.ObjectKey{Name: strings.TrimPrefix(s.Name, r.UsernamePrefix)}, &user)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user.Status.Email != "" && !slices.Contains(emails, user.Status.Email) {
+			emails = append(emails, user.Status.Email)
+		}
+	}
+	return emails, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JoinRequestEmailReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&userv1.JoinRequest{}).
+		WithOptions(controller.Options{
+			RateLimiter: emailRateLimiter(r.BaseRetryDelay),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,225 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/mailsenders"
+	"github.com/appuio/control-api/pkg/orgadmins"
+)
+
+type recordingJoinRequestSender struct {
+	sent       []userv1.JoinRequest
+	recipients []string
+	// failFor are the recipients sending fails for
+	failFor map[string]bool
+}
+
+func (s *recordingJoinRequestSender) Send(_ context.Context, recipient string, obj any) (string, error) {
+	if s.failFor[recipient] {
+		return "", errors.New("Err0r")
+	}
+	s.sent = append(s.sent, obj.(userv1.JoinRequest))
+	s.recipients = append(s.recipients, recipient)
+	return "", nil
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_Success(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	admins := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "control-api:organization-admin", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("control-api:organization-admin"),
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#admin"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#unknown"},
+			{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: "admins"},
+		},
+	}
+	admin := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		Status:     controlv1.UserStatus{Email: "admin@example.com"},
+	}
+	c := prepareTest(t, subject, admins, admin)
+
+	sender := &recordingJoinRequestSender{}
+	r := joinRequestEmailReconciler(c, sender)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"admin@example.com"}, sender.recipients)
+	assert.Equal(t, "let me in", sender.sent[0].Spec.Message)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionEmailSent))
+
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+	assert.Len(t, sender.sent, 1, "admins must only be notified once")
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_NoAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	c := prepareTest(t, subject)
+
+	sender := &recordingJoinRequestSender{}
+	_, err := joinRequestEmailReconciler(c, sender).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+
+	assert.Empty(t, sender.sent)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	condition := apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionEmailSent)
+	require.NotNil(t, condition)
+	assert.Equal(t, userv1.ConditionReasonNoRecipient, condition.Reason)
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_SendFailure(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	admins := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "control-api:organization-admin", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("control-api:organization-admin"),
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#admin"}},
+	}
+	admin := &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "admin"},
+		Status:     controlv1.UserStatus{Email: "admin@example.com"},
+	}
+	c := prepareTest(t, subject, admins, admin)
+
+	_, err := joinRequestEmailReconciler(c, &FailingSender{}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.Error(t, err)
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	condition := apimeta.FindStatusCondition(subject.Status.Conditions, userv1.ConditionEmailSent)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, userv1.ConditionReasonSendFailed, condition.Reason)
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_AdminRoles(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	members := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "members:control-api:organization-admin", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("control-api:organization-admin"),
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#member-admin"}},
+	}
+	owners := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "owners", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("organization-owner"),
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#owner"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#member-admin"},
+		},
+	}
+	viewers := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("control-api:organization-viewer"),
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#viewer"}},
+	}
+	c := prepareTest(t, subject, members, owners, viewers,
+		userWithEmail("member-admin", "member-admin@example.com"),
+		userWithEmail("owner", "owner@example.com"),
+		userWithEmail("viewer", "viewer@example.com"),
+	)
+
+	sender := &recordingJoinRequestSender{}
+	_, err := joinRequestEmailReconciler(c, sender).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"member-admin@example.com", "owner@example.com"}, sender.recipients, "admins of all admin roles must be notified once")
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_PartialSendFailure(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	admins := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "control-api:organization-admin", Namespace: subject.Namespace},
+		RoleRef:    adminRoleRef("control-api:organization-admin"),
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#admin"},
+			{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#other"},
+		},
+	}
+	c := prepareTest(t, subject, admins, userWithEmail("admin", "admin@example.com"), userWithEmail("other", "other@example.com"))
+
+	sender := &recordingJoinRequestSender{failFor: map[string]bool{"other@example.com": true}}
+	r := joinRequestEmailReconciler(c, sender)
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.Error(t, err)
+	assert.Equal(t, []string{"admin@example.com"}, sender.recipients)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.Equal(t, []string{"admin@example.com"}, subject.Status.NotificationRecipients)
+
+	sender.failFor = nil
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin@example.com", "other@example.com"}, sender.recipients, "retry must only notify the remaining admins")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(subject), subject))
+	assert.True(t, apimeta.IsStatusConditionTrue(subject.Status.Conditions, userv1.ConditionEmailSent))
+	assert.Equal(t, []string{"admin@example.com", "other@example.com"}, subject.Status.NotificationRecipients)
+}
+
+func Test_JoinRequestEmailReconciler_Reconcile_Decided(t *testing.T) {
+	ctx := context.Background()
+
+	subject := baseJoinRequest()
+	apimeta.SetStatusCondition(&subject.Status.Conditions, metav1.Condition{
+		Type:   userv1.ConditionApproved,
+		Status: metav1.ConditionTrue,
+		Reason: userv1.ConditionApproved,
+	})
+	c := prepareTest(t, subject)
+
+	sender := &recordingJoinRequestSender{}
+	_, err := joinRequestEmailReconciler(c, sender).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(subject)})
+	require.NoError(t, err)
+	assert.Empty(t, sender.sent)
+}
+
+func joinRequestEmailReconciler(c client.WithWatch, sender mailsenders.MailSender) *JoinRequestEmailReconciler {
+	r := NewJoinRequestEmailReconciler(
+		c,
+		record.NewFakeRecorder(3),
+		c.Scheme(),
+		sender,
+		"appuio#",
+		orgadmins.Resolver{
+			AdminRoles: []string{"control-api:organization-admin", "organization-owner"},
+			UserPrefix: "appuio#",
+		},
+		time.Minute,
+	)
+	return &r
+}
+
+func adminRoleRef(role string) rbacv1.RoleRef {
+	return rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role}
+}
+
+func userWithEmail(name, email string) *controlv1.User {
+	return &controlv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     controlv1.UserStatus{Email: email},
+	}
+}
@@ -2,17 +2,26 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"go.uber.org/multierr"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/controllers/targetref"
+	"github.com/appuio/control-api/pkg/memberroles"
 )
 
 // OrganizationMembersReconciler reconciles OrganizationMembers resources
@@ -22,12 +31,16 @@ type OrganizationMembersReconciler struct {
 	Scheme   *runtime.Scheme
 
 	// UserPrefix is the prefix applied to the user in the RoleBinding.subjects.name.
-	UserPrefix  string
+	UserPrefix string
+	// MemberRoles are the ClusterRoles bound to members without roles.
 	MemberRoles []string
+	// AllowedRoles are the ClusterRoles which can be assigned to individual members in addition to the MemberRoles.
+	AllowedRoles []string
 }
 
 //+kubebuilder:rbac:groups=appuio.io,resources=organizationmembers,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=appuio.io,resources=organizationmembers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=users,verbs=get;list;watch
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
@@ -38,7 +51,10 @@ type OrganizationMembersReconciler struct {
 //+kubebuilder:rbac:groups="appuio.io",resources=teams,verbs=get;list;watch;create;delete;patch;update
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile reacts on changes of users and mirrors these changes to Keycloak
+// Reconcile resolves the members of an organization and binds their roles to them.
+// Every role gets a RoleBinding containing the members with the role, RoleBindings of roles no member has anymore are removed.
+// The member roles are always bound, even if no member has them.
+// The RoleBindings are named by memberroles.RoleBindingName, existing RoleBindings not controlled by the organization members are never adopted.
 func (r *OrganizationMembersReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.V(1).WithValues("request", req).Info("Reconciling")
@@ -52,32 +68,99 @@ func (r *OrganizationMembersReconciler) Reconcile(ctx context.Context, req ctrl.
 		return ctrl.Result{}, nil
 	}
 
+	roleUsers, notAllowed := r.resolver().UsersByRole(memb.Spec.UserRefs)
+
+	roles := make([]string, 0, len(roleUsers))
+	for role := range roleUsers {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
 	var errGroup error
-	for _, role := range r.MemberRoles {
-		err := r.putRoleBinding(ctx, memb, role)
+	for _, role := range roles {
+		err := r.putRoleBinding(ctx, memb, role, roleUsers[role])
 		if err != nil {
 			errGroup = multierr.Append(errGroup, err)
 			r.Recorder.Event(&memb, "Warning", "RBACUpdateFailed", "Failed to set RBAC for Organization members")
 		}
 	}
+	if err := r.deleteStaleRoleBindings(ctx, memb, roleUsers); err != nil {
+		errGroup = multierr.Append(errGroup, err)
+		r.Recorder.Event(&memb, "Warning", "RBACUpdateFailed", "Failed to remove stale RBAC for Organization members")
+	}
+
+	if len(notAllowed) > 0 {
+		r.Recorder.Eventf(&memb, "Warning", controlv1.ConditionReasonRoleNotAllowed, "Roles not allowed for Organization members: %s", strings.Join(notAllowed, ", "))
+		apimeta.SetStatusCondition(&memb.Status.Conditions, metav1.Condition{
+			Type:    controlv1.ConditionRolesAllowed,
+			Status:  metav1.ConditionFalse,
+			Reason:  controlv1.ConditionReasonRoleNotAllowed,
+			Message: fmt.Sprintf("Roles not allowed: %s", strings.Join(notAllowed, ", ")),
+		})
+	} else {
+		apimeta.SetStatusCondition(&memb.Status.Conditions, metav1.Condition{
+			Type:   controlv1.ConditionRolesAllowed,
+			Status: metav1.ConditionTrue,
+			Reason: controlv1.ConditionRolesAllowed,
+		})
+	}
+
+	return ctrl.Result{}, multierr.Append(errGroup, r.updateResolvedUserRefs(ctx, memb))
+}
+
+func (r *OrganizationMembersReconciler) resolver() memberroles.Resolver {
+	return memberroles.Resolver{
+		DefaultRoles: r.MemberRoles,
+		AllowedRoles: r.AllowedRoles,
+	}
+}
+
+// updateResolvedUserRefs resolves the referenced users and updates the status of the organization members
+func (r *OrganizationMembersReconciler) updateResolvedUserRefs(ctx context.Context, memb controlv1.OrganizationMembers) error {
+	refs := make([]controlv1.UserRef, len(memb.Spec.UserRefs))
+	for i, mr := range memb.Spec.UserRefs {
+		refs[i] = mr.UserRef()
+	}
+	resolved, notFound, err := resolveUserRefs(ctx, r.Client, refs)
+	if err != nil {
+		return err
+	}
 
-	return ctrl.Result{}, errGroup
+	memb.Status.ResolvedUserRefs = resolved
+	if len(notFound) > 0 {
+		apimeta.SetStatusCondition(&memb.Status.Conditions, metav1.Condition{
+			Type:    controlv1.ConditionMembersResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  controlv1.ConditionReasonUserNotFound,
+			Message: fmt.Sprintf("Users not found: %s", strings.Join(notFound, ", ")),
+		})
+	} else {
+		apimeta.SetStatusCondition(&memb.Status.Conditions, metav1.Condition{
+			Type:   controlv1.ConditionMembersResolved,
+			Status: metav1.ConditionTrue,
+			Reason: controlv1.ConditionMembersResolved,
+		})
+	}
+	return r.Status().Update(ctx, &memb)
 }
 
-func (r *OrganizationMembersReconciler) putRoleBinding(ctx context.Context, memb controlv1.OrganizationMembers, role string) error {
+func (r *OrganizationMembersReconciler) putRoleBinding(ctx context.Context, memb controlv1.OrganizationMembers, role string, users []string) error {
 	rb := rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      role,
+			Name:      memberroles.RoleBindingName(role),
 			Namespace: memb.Namespace,
 		},
 	}
 	op, err := ctrl.CreateOrUpdate(ctx, r.Client, &rb, func() error {
-		sub := make([]rbacv1.Subject, len(memb.Spec.UserRefs))
-		for i, ur := range memb.Spec.UserRefs {
+		if rb.ResourceVersion != "" && !metav1.IsControlledBy(&rb, &memb) {
+			return fmt.Errorf("RoleBinding %q exists and is not controlled by the organization members", rb.Name)
+		}
+		sub := make([]rbacv1.Subject, len(users))
+		for i, u := range users {
 			sub[i] = rbacv1.Subject{
 				APIGroup: rbacv1.GroupName,
 				Kind:     "User",
-				Name:     r.UserPrefix + ur.Name,
+				Name:     r.UserPrefix + u,
 			}
 		}
 		rb.Subjects = sub
@@ -92,10 +175,45 @@ func (r *OrganizationMembersReconciler) putRoleBinding(ctx context.Context, memb
 	return err
 }
 
+// deleteStaleRoleBindings deletes the RoleBindings controlled by the organization members for roles no longer bound.
+func (r *OrganizationMembersReconciler) deleteStaleRoleBindings(ctx context.Context, memb controlv1.OrganizationMembers, roleUsers map[string][]string) error {
+	rbs := rbacv1.RoleBindingList{}
+	if err := r.List(ctx, &rbs, client.InNamespace(memb.Namespace)); err != nil {
+		return err
+	}
+	var errGroup error
+	for _, rb := range rbs.Items {
+		if !metav1.IsControlledBy(&rb, &memb) {
+			continue
+		}
+		if _, ok := roleUsers[rb.RoleRef.Name]; ok && rb.Name == memberroles.RoleBindingName(rb.RoleRef.Name) {
+			continue
+		}
+		log.FromContext(ctx).V(1).Info("delete stale RoleBinding", "rolebinding", rb.Name)
+		errGroup = multierr.Append(errGroup, client.IgnoreNotFound(r.Delete(ctx, &rb)))
+	}
+	return errGroup
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *OrganizationMembersReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&controlv1.OrganizationMembers{}).
 		Owns(&rbacv1.RoleBinding{}).
+		Watches(&source.Kind{Type: &controlv1.User{}}, handler.EnqueueRequestsFromMapFunc(r.mapUserToOrganizationMembers)).
 		Complete(r)
 }
+
+func (r *OrganizationMembersReconciler) mapUserToOrganizationMembers(obj client.Object) []reconcile.Request {
+	membl := controlv1.OrganizationMembersList{}
+	if err := r.List(context.Background(), &membl); err != nil {
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, memb := range membl.Items {
+		if a, err := targetref.NewUserAccessor(&memb); err == nil && a.HasUser("", obj.GetName()) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&memb)})
+		}
+	}
+	return reqs
+}
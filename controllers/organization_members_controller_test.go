@@ -11,14 +11,17 @@ import (
 
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	controlv1 "github.com/appuio/control-api/apis/v1"
 	. "github.com/appuio/control-api/controllers"
+	"github.com/appuio/control-api/pkg/memberroles"
 )
 
 var testMemb = controlv1.OrganizationMembers{
@@ -27,7 +30,7 @@ var testMemb = controlv1.OrganizationMembers{
 		Namespace: "foo-gmbh",
 	},
 	Spec: controlv1.OrganizationMembersSpec{
-		UserRefs: []controlv1.UserRef{
+		UserRefs: []controlv1.OrganizationMemberRef{
 			{Name: "u1"},
 			{Name: "u2"},
 			{Name: "u3"},
@@ -95,7 +98,7 @@ func Test_OrganizationMembersReconciler_Reconcile_PartialFailure(t *testing.T) {
 		testRoleExists(t, c, role, testUserPrefix, testMemb)
 	}
 	rb := rbacv1.RoleBinding{}
-	assert.Errorf(t, c.Get(ctx, types.NamespacedName{Name: "fail-bar", Namespace: testMemb.Namespace}, &rb), "don't create bar")
+	assert.Errorf(t, c.Get(ctx, types.NamespacedName{Name: memberroles.RoleBindingName("fail-bar"), Namespace: testMemb.Namespace}, &rb), "don't create bar")
 	require.Len(t, fakeRecorder.Events, 1)
 }
 
@@ -107,7 +110,7 @@ func Test_OrganizationMembersReconciler_Reconcile_MissmatchStatus(t *testing.T)
 		{Name: "u1"},
 		{Name: "u3"},
 	}
-	memb.Spec.UserRefs = []controlv1.UserRef{
+	memb.Spec.UserRefs = []controlv1.OrganizationMemberRef{
 		{Name: "u2"},
 		{Name: "u3"},
 	}
@@ -135,10 +138,158 @@ func Test_OrganizationMembersReconciler_Reconcile_MissmatchStatus(t *testing.T)
 	}
 }
 
+func Test_OrganizationMembersReconciler_Reconcile_ResolveUserRefs(t *testing.T) {
+	ctx := context.Background()
+	memb := *testMemb.DeepCopy()
+	memb.Status = controlv1.OrganizationMembersStatus{}
+
+	c := prepareTest(t, &memb, &u1, &u3)
+
+	_, err := (&OrganizationMembersReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		UserPrefix: testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      memb.Name,
+			Namespace: memb.Namespace,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: memb.Name, Namespace: memb.Namespace}, &memb))
+	assert.Equal(t, []controlv1.UserRef{{Name: "u1"}, {Name: "u3"}}, memb.Status.ResolvedUserRefs)
+	cond := apimeta.FindStatusCondition(memb.Status.Conditions, controlv1.ConditionMembersResolved)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, controlv1.ConditionReasonUserNotFound, cond.Reason)
+	assert.Contains(t, cond.Message, "u2")
+
+	require.NoError(t, c.Create(ctx, &controlv1.User{ObjectMeta: metav1.ObjectMeta{Name: "u2"}}))
+	_, err = (&OrganizationMembersReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		UserPrefix: testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      memb.Name,
+			Namespace: memb.Namespace,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: memb.Name, Namespace: memb.Namespace}, &memb))
+	assert.Equal(t, []controlv1.UserRef{{Name: "u1"}, {Name: "u2"}, {Name: "u3"}}, memb.Status.ResolvedUserRefs)
+	assert.True(t, apimeta.IsStatusConditionTrue(memb.Status.Conditions, controlv1.ConditionMembersResolved))
+}
+
+func Test_OrganizationMembersReconciler_Reconcile_UserRoles(t *testing.T) {
+	ctx := context.Background()
+	memb := *testMemb.DeepCopy()
+	memb.Spec.UserRefs = []controlv1.OrganizationMemberRef{
+		{Name: "u1"},
+		{Name: "u2", Roles: []string{"admin", "viewer"}},
+		{Name: "u3", Roles: []string{"viewer", "cluster-admin"}},
+	}
+	stale := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "billing",
+			Namespace: memb.Namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: controlv1.GroupVersion.String(),
+				Kind:       "OrganizationMembers",
+				Name:       memb.Name,
+				Controller: pointer.Bool(true),
+			}},
+		},
+		RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "billing"},
+	}
+	unrelated := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: memb.Namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "other"},
+	}
+
+	c := prepareTest(t, &memb, stale, unrelated)
+	fakeRecorder := record.NewFakeRecorder(3)
+
+	_, err := (&OrganizationMembersReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: fakeRecorder,
+
+		MemberRoles:  []string{"member", "owner"},
+		AllowedRoles: []string{"admin", "viewer", "billing"},
+		UserPrefix:   testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      memb.Name,
+			Namespace: memb.Namespace,
+		},
+	})
+	require.NoError(t, err)
+
+	subjectsOf := func(role string) []string {
+		rb := rbacv1.RoleBinding{}
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: memberroles.RoleBindingName(role), Namespace: memb.Namespace}, &rb))
+		names := []string{}
+		for _, s := range rb.Subjects {
+			names = append(names, strings.TrimPrefix(s.Name, testUserPrefix))
+		}
+		return names
+	}
+	assert.Equal(t, []string{"u1"}, subjectsOf("member"), "users without roles get the member roles")
+	assert.Equal(t, []string{"u1"}, subjectsOf("owner"), "users without roles get the member roles")
+	assert.Equal(t, []string{"u2"}, subjectsOf("admin"))
+	assert.Equal(t, []string{"u2", "u3"}, subjectsOf("viewer"))
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(unrelated), &rbacv1.RoleBinding{}), "unrelated RoleBindings are kept")
+
+	rb := rbacv1.RoleBinding{}
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, types.NamespacedName{Name: memberroles.RoleBindingName("cluster-admin"), Namespace: memb.Namespace}, &rb)), "not allowed roles must not be bound")
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, types.NamespacedName{Name: "billing", Namespace: memb.Namespace}, &rb)), "stale RoleBindings must be removed")
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: memb.Name, Namespace: memb.Namespace}, &memb))
+	cond := apimeta.FindStatusCondition(memb.Status.Conditions, controlv1.ConditionRolesAllowed)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, controlv1.ConditionReasonRoleNotAllowed, cond.Reason)
+	assert.Contains(t, cond.Message, "cluster-admin")
+	require.Len(t, fakeRecorder.Events, 1)
+}
+
+func Test_OrganizationMembersReconciler_Reconcile_NoAdoption(t *testing.T) {
+	ctx := context.Background()
+	memb := *testMemb.DeepCopy()
+	foreign := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: memberroles.RoleBindingName("admin"), Namespace: memb.Namespace},
+		Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: "User", Name: "creator"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+	}
+
+	c := prepareTest(t, &memb, foreign)
+	_, err := (&OrganizationMembersReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		MemberRoles: []string{"admin"},
+		UserPrefix:  testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&memb)})
+	require.ErrorContains(t, err, "not controlled by the organization members")
+
+	rb := rbacv1.RoleBinding{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(foreign), &rb))
+	assert.Equal(t, foreign.Subjects, rb.Subjects, "RoleBindings not controlled by the organization members must not be changed")
+	assert.Empty(t, rb.OwnerReferences)
+}
+
 func testRoleExists(t *testing.T, c client.WithWatch, role, userPrefix string, memb controlv1.OrganizationMembers) {
 	t.Run(role+" exists", func(t *testing.T) {
 		rb := rbacv1.RoleBinding{}
-		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: role, Namespace: memb.Namespace}, &rb))
+		require.NoError(t, c.Get(context.TODO(), types.NamespacedName{Name: memberroles.RoleBindingName(role), Namespace: memb.Namespace}, &rb))
 
 		assert.Equal(t, rb.RoleRef, rbacv1.RoleRef{
 			APIGroup: rbacv1.GroupName,
@@ -165,7 +316,7 @@ type failingClient struct {
 }
 
 func (c failingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
-	if strings.HasPrefix(obj.GetName(), "fail-") {
+	if strings.Contains(obj.GetName(), "fail-") {
 		return apierrors.NewInternalError(errors.New("ups"))
 	}
 	return c.WithWatch.Create(ctx, obj, opts...)
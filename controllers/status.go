@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateStatus applies the change to the status of the object and updates it.
+// Objects stored in secrets reject updates of outdated objects. If the object changed since it was read,
+// the change is applied to the latest object again. Changes recording sent e-mails must not be lost, the e-mails would be sent again.
+func updateStatus[T client.Object](ctx context.Context, c client.Client, obj T, change func(T)) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !first {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return err
+			}
+		}
+		first = false
+		change(obj)
+		return c.Status().Update(ctx, obj)
+	})
+}
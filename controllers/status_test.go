@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+)
+
+func Test_updateStatus_Conflict(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	require.NoError(t, userv1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(&userv1.Invitation{ObjectMeta: metav1.ObjectMeta{Name: "subject"}}).
+		Build()
+
+	stale := &userv1.Invitation{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "subject"}, stale))
+	concurrent := stale.DeepCopy()
+	concurrent.Status.RedeemedBy = "user"
+	require.NoError(t, c.Status().Update(ctx, concurrent))
+
+	require.NoError(t, updateStatus(ctx, c, stale, func(inv *userv1.Invitation) {
+		inv.Status.ExpiryNotificationRecipients = append(inv.Status.ExpiryNotificationRecipients, "inviter@example.com")
+	}))
+
+	inv := &userv1.Invitation{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "subject"}, inv))
+	assert.Equal(t, "user", inv.Status.RedeemedBy, "concurrent change must be kept")
+	assert.Equal(t, []string{"inviter@example.com"}, inv.Status.ExpiryNotificationRecipients, "change must be applied once to the latest object")
+}
@@ -0,0 +1,189 @@
+package targetref
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+)
+
+// GenericKind configures a custom resource kind users can be invited into.
+// The users are stored in a list in the object, either as plain strings or as objects holding the name in NameField.
+type GenericKind struct {
+	// Group is the API group of the kind.
+	Group string `json:"group"`
+	// Version is the API version used to access the kind.
+	Version string `json:"version"`
+	// Kind is the kind of the objects.
+	Kind string `json:"kind"`
+
+	// UsersPath is the dot separated path to the list of users, e.g. `spec.members`.
+	UsersPath string `json:"usersPath"`
+	// NameField is the field holding the user name if the list items are objects, e.g. `name` for `[{name: user}]`.
+	// The list items are plain strings if empty.
+	NameField string `json:"nameField,omitempty"`
+	// UsernamePrefix controls whether the user names in the list are prefixed with the username prefix.
+	UsernamePrefix bool `json:"usernamePrefix,omitempty"`
+}
+
+// GroupVersionKind returns the GroupVersionKind of the configured kind.
+func (k GenericKind) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: k.Group, Version: k.Version, Kind: k.Kind}
+}
+
+func (k GenericKind) usersPath() []string {
+	return strings.Split(strings.TrimPrefix(k.UsersPath, "."), ".")
+}
+
+// Registry holds the generic kinds supported as targets in addition to the built-in kinds.
+// A nil Registry only supports the built-in kinds.
+type Registry struct {
+	kinds []GenericKind
+}
+
+// NewRegistry returns a Registry supporting the given generic kinds.
+// Returns an error if a kind is incomplete or conflicts with another kind.
+func NewRegistry(kinds ...GenericKind) (*Registry, error) {
+	r := &Registry{}
+	for _, k := range kinds {
+		if k.Version == "" || k.Kind == "" || k.UsersPath == "" {
+			return nil, fmt.Errorf("generic target kind %q.%q: version, kind, and usersPath are required", k.Group, k.Kind)
+		}
+		ref := userv1.TargetRef{APIGroup: k.Group, Kind: k.Kind}
+		if _, err := newBuiltinObjectFromRef(ref); err == nil || IsBillingEntity(ref) {
+			return nil, fmt.Errorf("generic target kind %q.%q conflicts with a built-in kind", k.Group, k.Kind)
+		}
+		if _, ok := r.lookup(k.Group, k.Kind); ok {
+			return nil, fmt.Errorf("generic target kind %q.%q is configured more than once", k.Group, k.Kind)
+		}
+		r.kinds = append(r.kinds, k)
+	}
+	return r, nil
+}
+
+// LoadRegistry reads a YAML list of generic kinds from the given file and returns a Registry supporting them.
+// Returns an empty Registry if path is empty.
+func LoadRegistry(path string) (*Registry, error) {
+	if path == "" {
+		return NewRegistry()
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read generic target kinds: %w", err)
+	}
+	var kinds []GenericKind
+	if err := yaml.UnmarshalStrict(raw, &kinds); err != nil {
+		return nil, fmt.Errorf("failed to parse generic target kinds: %w", err)
+	}
+	return NewRegistry(kinds...)
+}
+
+func (r *Registry) lookup(group, kind string) (GenericKind, bool) {
+	if r == nil {
+		return GenericKind{}, false
+	}
+	for _, k := range r.kinds {
+		if k.Group == group && k.Kind == kind {
+			return k, true
+		}
+	}
+	return GenericKind{}, false
+}
+
+// unstructuredUserListAccessor accesses the user list of a generic kind.
+type unstructuredUserListAccessor struct {
+	obj  *unstructured.Unstructured
+	kind GenericKind
+}
+
+var _ UserAccessor = &unstructuredUserListAccessor{}
+
+func newUnstructuredUserListAccessor(obj *unstructured.Unstructured, kind GenericKind) (*unstructuredUserListAccessor, error) {
+	a := &unstructuredUserListAccessor{obj: obj, kind: kind}
+	items, err := a.items()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if _, ok := a.nameOf(item); !ok {
+			return nil, fmt.Errorf("unexpected user %v in %q of %s %q", item, kind.UsersPath, kind.Kind, obj.GetName())
+		}
+	}
+	return a, nil
+}
+
+func (s *unstructuredUserListAccessor) HasUser(prefix, user string) bool {
+	name := s.username(prefix, user)
+	items, _ := s.items()
+	for _, item := range items {
+		if n, _ := s.nameOf(item); n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *unstructuredUserListAccessor) EnsureUser(prefix, user string) (added bool) {
+	if s.HasUser(prefix, user) {
+		return false
+	}
+	var item interface{} = s.username(prefix, user)
+	if s.kind.NameField != "" {
+		item = map[string]interface{}{s.kind.NameField: item}
+	}
+	items, _ := s.items()
+	// The path was validated when creating the accessor, setting it can't fail.
+	_ = unstructured.SetNestedSlice(s.obj.Object, append(items, item), s.kind.usersPath()...)
+	return true
+}
+
+func (s *unstructuredUserListAccessor) RemoveUser(prefix, user string) (removed bool) {
+	name := s.username(prefix, user)
+	items, _ := s.items()
+	kept := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if n, _ := s.nameOf(item); n == name {
+			removed = true
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if removed {
+		// The path was validated when creating the accessor, setting it can't fail.
+		_ = unstructured.SetNestedSlice(s.obj.Object, kept, s.kind.usersPath()...)
+	}
+	return removed
+}
+
+func (s *unstructuredUserListAccessor) items() ([]interface{}, error) {
+	items, _, err := unstructured.NestedSlice(s.obj.Object, s.kind.usersPath()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users of %s %q: %w", s.kind.Kind, s.obj.GetName(), err)
+	}
+	return items, nil
+}
+
+func (s *unstructuredUserListAccessor) nameOf(item interface{}) (string, bool) {
+	if s.kind.NameField == "" {
+		name, ok := item.(string)
+		return name, ok
+	}
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	name, ok := m[s.kind.NameField].(string)
+	return name, ok
+}
+
+func (s *unstructuredUserListAccessor) username(prefix, user string) string {
+	if s.kind.UsernamePrefix {
+		return prefix + user
+	}
+	return user
+}
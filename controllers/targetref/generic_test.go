@@ -0,0 +1,190 @@
+package targetref_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	userv1 "github.com/appuio/control-api/apis/user/v1"
+	"github.com/appuio/control-api/controllers/targetref"
+)
+
+var (
+	projectKind = targetref.GenericKind{
+		Group:     "projects.example.com",
+		Version:   "v1",
+		Kind:      "Project",
+		UsersPath: "spec.members",
+	}
+	contractKind = targetref.GenericKind{
+		Group:          "support.example.com",
+		Version:        "v1alpha1",
+		Kind:           "SupportContract",
+		UsersPath:      ".spec.contacts",
+		NameField:      "name",
+		UsernamePrefix: true,
+	}
+)
+
+func Test_NewRegistry(t *testing.T) {
+	_, err := targetref.NewRegistry(projectKind, contractKind)
+	require.NoError(t, err)
+
+	_, err = targetref.NewRegistry(projectKind, projectKind)
+	require.ErrorContains(t, err, "more than once")
+
+	_, err = targetref.NewRegistry(targetref.GenericKind{Group: "projects.example.com", Kind: "Project", UsersPath: "spec.members"})
+	require.ErrorContains(t, err, "required")
+
+	_, err = targetref.NewRegistry(targetref.GenericKind{Group: "appuio.io", Version: "v1", Kind: "Team", UsersPath: "spec.userRefs"})
+	require.ErrorContains(t, err, "built-in")
+	_, err = targetref.NewRegistry(targetref.GenericKind{Group: "billing.appuio.io", Version: "v1", Kind: "BillingEntity", UsersPath: "spec.users"})
+	require.ErrorContains(t, err, "built-in")
+}
+
+func Test_LoadRegistry(t *testing.T) {
+	r, err := targetref.LoadRegistry("")
+	require.NoError(t, err)
+	_, err = r.NewObjectFromRef(userv1.TargetRef{APIGroup: projectKind.Group, Kind: projectKind.Kind})
+	require.ErrorContains(t, err, "unsupported target")
+
+	path := filepath.Join(t.TempDir(), "kinds.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- group: projects.example.com
+  version: v1
+  kind: Project
+  usersPath: spec.members
+`), 0o600))
+	r, err = targetref.LoadRegistry(path)
+	require.NoError(t, err)
+	obj, err := r.NewObjectFromRef(userv1.TargetRef{APIGroup: projectKind.Group, Kind: projectKind.Kind})
+	require.NoError(t, err)
+	require.Equal(t, projectKind.GroupVersionKind(), obj.GetObjectKind().GroupVersionKind())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"group": "projects.example.com", "unknown": "field"}]`), 0o600))
+	_, err = targetref.LoadRegistry(path)
+	require.ErrorContains(t, err, "failed to parse")
+}
+
+func Test_Registry_GetTarget(t *testing.T) {
+	r, err := targetref.NewRegistry(projectKind)
+	require.NoError(t, err)
+
+	project := &unstructured.Unstructured{}
+	project.SetGroupVersionKind(projectKind.GroupVersionKind())
+	project.SetName("project")
+	project.SetNamespace("test")
+	c := newClient(t, project)
+
+	obj, err := r.GetTarget(context.Background(), c, userv1.TargetRef{
+		APIGroup:  projectKind.Group,
+		Kind:      projectKind.Kind,
+		Name:      "project",
+		Namespace: "test",
+	})
+	require.NoError(t, err)
+	require.IsType(t, &unstructured.Unstructured{}, obj)
+	require.Equal(t, "project", obj.GetName())
+
+	_, err = targetref.GetTarget(context.Background(), c, userv1.TargetRef{
+		APIGroup: projectKind.Group,
+		Kind:     projectKind.Kind,
+	})
+	require.ErrorContains(t, err, "unsupported target", "kinds are only supported through the registry")
+}
+
+func Test_Registry_UserAccessor(t *testing.T) {
+	const usernamePrefix = "appuio#"
+	r, err := targetref.NewRegistry(projectKind, contractKind)
+	require.NoError(t, err)
+
+	tt := map[string]struct {
+		kind     targetref.GenericKind
+		path     []string
+		object   map[string]interface{}
+		expected []interface{}
+	}{
+		"strings": {
+			kind: projectKind,
+			path: []string{"spec", "members"},
+			object: map[string]interface{}{
+				"spec": map[string]interface{}{"members": []interface{}{"user1"}},
+			},
+			expected: []interface{}{"user1", "user2"},
+		},
+		"strings, missing list": {
+			kind:     projectKind,
+			path:     []string{"spec", "members"},
+			object:   map[string]interface{}{},
+			expected: []interface{}{"user2"},
+		},
+		"objects, prefixed": {
+			kind: contractKind,
+			path: []string{"spec", "contacts"},
+			object: map[string]interface{}{
+				"spec": map[string]interface{}{"contacts": []interface{}{
+					map[string]interface{}{"name": usernamePrefix + "user1", "phone": "+41 00 000 00 00"},
+				}},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"name": usernamePrefix + "user1", "phone": "+41 00 000 00 00"},
+				map[string]interface{}{"name": usernamePrefix + "user2"},
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: tc.object}
+			obj.SetGroupVersionKind(tc.kind.GroupVersionKind())
+
+			a, err := r.NewUserAccessor(obj)
+			require.NoError(t, err)
+
+			require.False(t, a.HasUser(usernamePrefix, "user2"))
+			require.True(t, a.EnsureUser(usernamePrefix, "user2"))
+			require.True(t, a.HasUser(usernamePrefix, "user2"))
+			require.False(t, a.EnsureUser(usernamePrefix, "user2"))
+
+			users, _, err := unstructured.NestedSlice(obj.Object, tc.path...)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, users)
+
+			require.True(t, a.RemoveUser(usernamePrefix, "user2"))
+			require.False(t, a.HasUser(usernamePrefix, "user2"))
+			require.False(t, a.RemoveUser(usernamePrefix, "user2"))
+			users, _, err = unstructured.NestedSlice(obj.Object, tc.path...)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected[:len(tc.expected)-1], users)
+		})
+	}
+}
+
+func Test_Registry_UserAccessor_Invalid(t *testing.T) {
+	r, err := targetref.NewRegistry(projectKind, contractKind)
+	require.NoError(t, err)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"members": []interface{}{map[string]interface{}{"name": "user1"}}},
+	}}
+	obj.SetGroupVersionKind(projectKind.GroupVersionKind())
+	_, err = r.NewUserAccessor(obj)
+	require.ErrorContains(t, err, "unexpected user")
+
+	obj = &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"contacts": "user1"},
+	}}
+	obj.SetGroupVersionKind(contractKind.GroupVersionKind())
+	_, err = r.NewUserAccessor(obj)
+	require.ErrorContains(t, err, "failed to get users")
+
+	obj = &unstructured.Unstructured{}
+	obj.SetAPIVersion("apps/v1")
+	obj.SetKind("Deployment")
+	_, err = r.NewUserAccessor(obj)
+	require.ErrorContains(t, err, "unsupported object")
+}
@@ -3,28 +3,98 @@ package targetref
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	billingv1 "github.com/appuio/control-api/apis/billing/v1"
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/billingrbac"
+	"github.com/appuio/control-api/pkg/memberroles"
 )
 
 // GetTarget returns the target object for the given TargetRef.
 // Returns an error if the target is not supported.
+// Only built-in kinds are supported, see Registry.GetTarget for generic kinds.
 func GetTarget(ctx context.Context, c client.Client, target userv1.TargetRef) (client.Object, error) {
-	obj, err := NewObjectFromRef(target)
+	return (*Registry)(nil).GetTarget(ctx, c, target)
+}
+
+// GetTarget returns the target object for the given TargetRef.
+// Returns an error if the target is neither a built-in kind nor a kind of the registry.
+func (r *Registry) GetTarget(ctx context.Context, c client.Client, target userv1.TargetRef) (client.Object, error) {
+	ref, err := ResolveRef(target)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := r.NewObjectFromRef(ref)
 	if err != nil {
 		return nil, err
 	}
-	return obj, c.Get(ctx, client.ObjectKey{Name: target.Name, Namespace: target.Namespace}, obj)
+	return obj, c.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, obj)
+}
+
+// ResolveRef returns the reference to the object users are added to for the given TargetRef.
+// BillingEntity targets resolve to the ClusterRoleBinding granting the role of the target.
+// All other targets are returned unchanged.
+func ResolveRef(target userv1.TargetRef) (userv1.TargetRef, error) {
+	if !IsBillingEntity(target) {
+		return target, nil
+	}
+	if err := ValidateRole(target); err != nil {
+		return userv1.TargetRef{}, err
+	}
+	name := billingrbac.ViewerRoleName(target.Name)
+	if target.Role == BillingEntityRoleAdmin {
+		name = billingrbac.AdminRoleName(target.Name)
+	}
+	return userv1.TargetRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "ClusterRoleBinding",
+		Name:     name,
+	}, nil
+}
+
+// IsBillingEntity returns true if the target references a BillingEntity.
+func IsBillingEntity(target userv1.TargetRef) bool {
+	return target.APIGroup == billingv1.GroupVersion.Group && target.Kind == "BillingEntity"
+}
+
+// HasOrganizationRole returns true if the target grants an organization role.
+func HasOrganizationRole(target userv1.TargetRef) bool {
+	return target.Role != "" && target.APIGroup == "appuio.io" && target.Kind == "OrganizationMembers"
 }
 
 // NewObjectFromRef returns a new object for the given TargetRef or an error if the target is not supported.
+// BillingEntity targets return the ClusterRoleBinding granting the role of the target, see ResolveRef.
+// Only built-in kinds are supported, see Registry.NewObjectFromRef for generic kinds.
 func NewObjectFromRef(target userv1.TargetRef) (client.Object, error) {
+	return newBuiltinObjectFromRef(target)
+}
+
+// NewObjectFromRef returns a new object for the given TargetRef or an error if the target is not supported.
+// Generic kinds of the registry are returned as unstructured objects.
+func (r *Registry) NewObjectFromRef(target userv1.TargetRef) (client.Object, error) {
+	if k, ok := r.lookup(target.APIGroup, target.Kind); ok {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(k.GroupVersionKind())
+		return obj, nil
+	}
+	return newBuiltinObjectFromRef(target)
+}
+
+func newBuiltinObjectFromRef(target userv1.TargetRef) (client.Object, error) {
 	var obj client.Object
 	switch {
+	case IsBillingEntity(target):
+		if err := ValidateRole(target); err != nil {
+			return nil, err
+		}
+		obj = &rbacv1.ClusterRoleBinding{}
 	case target.APIGroup == "appuio.io" && target.Kind == "OrganizationMembers":
 		obj = &controlv1.OrganizationMembers{}
 	case target.APIGroup == "appuio.io" && target.Kind == "Team":
@@ -40,6 +110,55 @@ func NewObjectFromRef(target userv1.TargetRef) (client.Object, error) {
 	return obj, nil
 }
 
+// DefaultOrganizationRolePrefix is the prefix of the ClusterRoles and organization RoleBindings granting organization roles.
+const DefaultOrganizationRolePrefix = "control-api:organization-"
+
+const (
+	// BillingEntityRoleAdmin is the role of BillingEntity targets granting admin access to the billing entity.
+	BillingEntityRoleAdmin = "admin"
+	// BillingEntityRoleViewer is the role of BillingEntity targets granting view access to the billing entity.
+	BillingEntityRoleViewer = "viewer"
+)
+
+// ValidateRole returns an error if the target has a role but does not support roles or the role is not a valid name.
+// BillingEntity targets require either the admin or the viewer role.
+func ValidateRole(target userv1.TargetRef) error {
+	if IsBillingEntity(target) {
+		if target.Role != BillingEntityRoleAdmin && target.Role != BillingEntityRoleViewer {
+			return fmt.Errorf("invalid role %q for target %q.%q, must be %q or %q", target.Role, target.APIGroup, target.Kind, BillingEntityRoleAdmin, BillingEntityRoleViewer)
+		}
+		return nil
+	}
+	if target.Role == "" {
+		return nil
+	}
+	if target.APIGroup != "appuio.io" || target.Kind != "OrganizationMembers" {
+		return fmt.Errorf("role %q not supported for target %q.%q", target.Role, target.APIGroup, target.Kind)
+	}
+	if errs := validation.IsDNS1123Label(target.Role); len(errs) > 0 {
+		return fmt.Errorf("invalid role %q: %s", target.Role, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// OrganizationRole returns the name of the ClusterRole granting the organization role of the target.
+// The ClusterRole is named after the role prefixed with rolePrefix.
+func OrganizationRole(target userv1.TargetRef, rolePrefix string) string {
+	return rolePrefix + target.Role
+}
+
+// RoleBindingRef returns a reference to the RoleBinding the OrganizationMembers controller binds the role of the target to the organization members with.
+// The RoleBinding is named `members:<ClusterRole>` after the ClusterRole granting the role.
+// Users allowed to edit the RoleBinding are allowed to delegate the role.
+func RoleBindingRef(target userv1.TargetRef, rolePrefix string) userv1.TargetRef {
+	return userv1.TargetRef{
+		APIGroup:  rbacv1.GroupName,
+		Kind:      "RoleBinding",
+		Name:      memberroles.RoleBindingName(OrganizationRole(target, rolePrefix)),
+		Namespace: target.Namespace,
+	}
+}
+
 // UserAccessor is an interface for accessing users from objects supported by this project.
 type UserAccessor interface {
 	// EnsureUser adds the user to the object if it is not already present.
@@ -49,13 +168,31 @@ type UserAccessor interface {
 	// HasUser returns true if the user is present in the object.
 	// Uses the prefix where applicable.
 	HasUser(prefix, user string) bool
+	// RemoveUser removes the user from the object if it is present.
+	// Returns true if the user was removed.
+	// Uses the prefix where applicable.
+	RemoveUser(prefix, user string) (removed bool)
 }
 
 // NewUserAccessor returns a UserAccessor for the given object or an error if the object is not supported.
+// Only built-in kinds are supported, see Registry.NewUserAccessor for generic kinds.
 func NewUserAccessor(obj client.Object) (UserAccessor, error) {
+	return (*Registry)(nil).NewUserAccessor(obj)
+}
+
+// NewUserAccessor returns a UserAccessor for the given object or an error if the object is not supported.
+// Unstructured objects are supported if their kind is in the registry.
+func (r *Registry) NewUserAccessor(obj client.Object) (UserAccessor, error) {
 	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		gvk := o.GroupVersionKind()
+		k, ok := r.lookup(gvk.Group, gvk.Kind)
+		if !ok {
+			return nil, fmt.Errorf("unsupported object %q.%q", gvk.Group, gvk.Kind)
+		}
+		return newUnstructuredUserListAccessor(o, k)
 	case *controlv1.OrganizationMembers:
-		return &controlv1UserRefAccessor{userRefs: &o.Spec.UserRefs}, nil
+		return &controlv1OrganizationMemberRefAccessor{memberRefs: &o.Spec.UserRefs}, nil
 	case *controlv1.Team:
 		return &controlv1UserRefAccessor{userRefs: &o.Spec.UserRefs}, nil
 	case *rbacv1.ClusterRoleBinding:
@@ -84,6 +221,51 @@ func (s *controlv1UserRefAccessor) EnsureUser(_, user string) (added bool) {
 	return
 }
 
+func (s *controlv1UserRefAccessor) RemoveUser(_, user string) (removed bool) {
+	// Prefix is not used for UserRef
+	*s.userRefs, removed = remove(*s.userRefs, controlv1.UserRef{Name: user})
+	return
+}
+
+type controlv1OrganizationMemberRefAccessor struct {
+	memberRefs *[]controlv1.OrganizationMemberRef
+}
+
+var _ UserAccessor = &controlv1OrganizationMemberRefAccessor{}
+
+func (s *controlv1OrganizationMemberRefAccessor) HasUser(_, user string) bool {
+	// Prefix is not used for OrganizationMemberRef
+	for _, mr := range *s.memberRefs {
+		if mr.Name == user {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *controlv1OrganizationMemberRefAccessor) EnsureUser(_, user string) (added bool) {
+	// Prefix is not used for OrganizationMemberRef
+	if s.HasUser("", user) {
+		return false
+	}
+	*s.memberRefs = append(*s.memberRefs, controlv1.OrganizationMemberRef{Name: user})
+	return true
+}
+
+func (s *controlv1OrganizationMemberRefAccessor) RemoveUser(_, user string) (removed bool) {
+	// Prefix is not used for OrganizationMemberRef
+	kept := make([]controlv1.OrganizationMemberRef, 0, len(*s.memberRefs))
+	for _, mr := range *s.memberRefs {
+		if mr.Name == user {
+			removed = true
+			continue
+		}
+		kept = append(kept, mr)
+	}
+	*s.memberRefs = kept
+	return
+}
+
 type rbacv1SubjectAccessor struct {
 	subjects *[]rbacv1.Subject
 }
@@ -99,6 +281,11 @@ func (s *rbacv1SubjectAccessor) EnsureUser(prefix, user string) (added bool) {
 	return
 }
 
+func (s *rbacv1SubjectAccessor) RemoveUser(prefix, user string) (removed bool) {
+	*s.subjects, removed = remove(*s.subjects, newSubject(prefix+user))
+	return
+}
+
 func newSubject(user string) rbacv1.Subject {
 	return rbacv1.Subject{
 		Kind:     rbacv1.UserKind,
@@ -116,6 +303,18 @@ func ensure[T comparable](s []T, e T) (ret []T, added bool) {
 	return append(s, e), true
 }
 
+func remove[T comparable](s []T, e T) (ret []T, removed bool) {
+	ret = make([]T, 0, len(s))
+	for _, v := range s {
+		if v == e {
+			removed = true
+			continue
+		}
+		ret = append(ret, v)
+	}
+	return ret, removed
+}
+
 func isInSlice[T comparable](s []T, e T) (found bool) {
 	for _, v := range s {
 		if v == e {
@@ -97,7 +97,7 @@ func Test_UserAccessor_AccessUser(t *testing.T) {
 		},
 		&controlv1.OrganizationMembers{
 			Spec: controlv1.OrganizationMembersSpec{
-				UserRefs: []controlv1.UserRef{
+				UserRefs: []controlv1.OrganizationMemberRef{
 					{Name: "user1"},
 				},
 			},
@@ -121,6 +121,10 @@ func Test_UserAccessor_AccessUser(t *testing.T) {
 			require.True(t, a.HasUser(usernamePrefix, "user2"))
 			require.False(t, a.EnsureUser(usernamePrefix, "user2"))
 			require.True(t, a.HasUser(usernamePrefix, "user2"))
+			require.True(t, a.RemoveUser(usernamePrefix, "user2"))
+			require.False(t, a.HasUser(usernamePrefix, "user2"))
+			require.True(t, a.HasUser(usernamePrefix, "user1"))
+			require.False(t, a.RemoveUser(usernamePrefix, "user2"))
 		})
 	}
 }
@@ -142,3 +146,85 @@ func newClient(t *testing.T, objs ...client.Object) client.Client {
 		WithObjects(objs...).
 		Build()
 }
+
+func Test_ValidateRole(t *testing.T) {
+	members := userv1.TargetRef{APIGroup: "appuio.io", Kind: "OrganizationMembers", Name: "members", Namespace: "org"}
+	require.NoError(t, targetref.ValidateRole(members), "targets without role are valid")
+
+	members.Role = "viewer"
+	require.NoError(t, targetref.ValidateRole(members))
+
+	members.Role = "Viewer:all"
+	require.ErrorContains(t, targetref.ValidateRole(members), "invalid role")
+
+	team := userv1.TargetRef{APIGroup: "appuio.io", Kind: "Team", Name: "team", Namespace: "org", Role: "viewer"}
+	require.ErrorContains(t, targetref.ValidateRole(team), "not supported")
+
+	be := userv1.TargetRef{APIGroup: "billing.appuio.io", Kind: "BillingEntity", Name: "be-1234"}
+	require.ErrorContains(t, targetref.ValidateRole(be), "invalid role", "billing entity targets require a role")
+	be.Role = "viewer"
+	require.NoError(t, targetref.ValidateRole(be))
+	be.Role = "admin"
+	require.NoError(t, targetref.ValidateRole(be))
+	be.Role = "owner"
+	require.ErrorContains(t, targetref.ValidateRole(be), "invalid role")
+}
+
+func Test_GetTarget_BillingEntity(t *testing.T) {
+	viewers := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "billingentities-be-1234-viewer"}}
+	admins := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "billingentities-be-1234-admin"}}
+	c := newClient(t, viewers, admins)
+
+	for role, expected := range map[string]string{"viewer": viewers.Name, "admin": admins.Name} {
+		t.Run(role, func(t *testing.T) {
+			obj, err := targetref.GetTarget(context.Background(), c, userv1.TargetRef{
+				APIGroup: "billing.appuio.io",
+				Kind:     "BillingEntity",
+				Name:     "be-1234",
+				Role:     role,
+			})
+			require.NoError(t, err)
+			require.IsType(t, &rbacv1.ClusterRoleBinding{}, obj)
+			require.Equal(t, expected, obj.GetName())
+		})
+	}
+
+	_, err := targetref.GetTarget(context.Background(), c, userv1.TargetRef{
+		APIGroup: "billing.appuio.io",
+		Kind:     "BillingEntity",
+		Name:     "be-1234",
+	})
+	require.ErrorContains(t, err, "invalid role")
+}
+
+func Test_ResolveRef(t *testing.T) {
+	members := userv1.TargetRef{APIGroup: "appuio.io", Kind: "OrganizationMembers", Name: "members", Namespace: "org", Role: "admin"}
+	ref, err := targetref.ResolveRef(members)
+	require.NoError(t, err)
+	require.Equal(t, members, ref, "non billing entity targets are returned unchanged")
+
+	ref, err = targetref.ResolveRef(userv1.TargetRef{APIGroup: "billing.appuio.io", Kind: "BillingEntity", Name: "be-1234", Role: "admin"})
+	require.NoError(t, err)
+	require.Equal(t, userv1.TargetRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "ClusterRoleBinding",
+		Name:     "billingentities-be-1234-admin",
+	}, ref)
+}
+
+func Test_RoleBindingRef(t *testing.T) {
+	ref := targetref.RoleBindingRef(userv1.TargetRef{
+		APIGroup:  "appuio.io",
+		Kind:      "OrganizationMembers",
+		Name:      "members",
+		Namespace: "org",
+		Role:      "admin",
+	}, "control-api:organization-")
+
+	require.Equal(t, userv1.TargetRef{
+		APIGroup:  rbacv1.GroupName,
+		Kind:      "RoleBinding",
+		Name:      "members:control-api:organization-admin",
+		Namespace: "org",
+	}, ref)
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/multierr"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/controllers/targetref"
+)
+
+// TeamReconciler reconciles Team resources
+type TeamReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+
+	// UserPrefix is the prefix applied to the user in the RoleBinding.subjects.name.
+	UserPrefix string
+	// TeamRoles are the ClusterRoles bound to the members of every team in the organization namespace.
+	TeamRoles []string
+}
+
+//+kubebuilder:rbac:groups=appuio.io,resources=teams,verbs=get;list;watch
+//+kubebuilder:rbac:groups=appuio.io,resources=teams/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=organizationmembers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=appuio.io,resources=users,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile resolves the members of a team and binds the team roles to them.
+// RoleBindings controlled by the team for roles which are no longer team roles are removed.
+// Existing RoleBindings not controlled by the team are never adopted.
+func (r *TeamReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	team := controlv1.Team{}
+	if err := r.Get(ctx, req.NamespacedName, &team); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !team.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	memb := controlv1.OrganizationMembers{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "members", Namespace: team.Namespace}, &memb); err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	resolved, notFound, err := resolveUserRefs(ctx, r.Client, team.Spec.UserRefs)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	orgMembers, err := targetref.NewUserAccessor(&memb)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	members := make([]controlv1.UserRef, 0, len(resolved))
+	nonMembers := []string{}
+	for _, ur := range resolved {
+		if orgMembers.HasUser("", ur.Name) {
+			members = append(members, ur)
+		} else {
+			nonMembers = append(nonMembers, ur.Name)
+		}
+	}
+
+	team.Status.ResolvedUserRefs = members
+	switch {
+	case len(notFound) > 0:
+		apimeta.SetStatusCondition(&team.Status.Conditions, metav1.Condition{
+			Type:    controlv1.ConditionMembersResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  controlv1.ConditionReasonUserNotFound,
+			Message: fmt.Sprintf("Users not found: %s", strings.Join(notFound, ", ")),
+		})
+	case len(nonMembers) > 0:
+		apimeta.SetStatusCondition(&team.Status.Conditions, metav1.Condition{
+			Type:    controlv1.ConditionMembersResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  controlv1.ConditionReasonNotOrganizationMember,
+			Message: fmt.Sprintf("Users not members of organization %q: %s", team.Namespace, strings.Join(nonMembers, ", ")),
+		})
+	default:
+		apimeta.SetStatusCondition(&team.Status.Conditions, metav1.Condition{
+			Type:   controlv1.ConditionMembersResolved,
+			Status: metav1.ConditionTrue,
+			Reason: controlv1.ConditionMembersResolved,
+		})
+	}
+
+	var errGroup error
+	for _, role := range r.TeamRoles {
+		err := r.putRoleBinding(ctx, team, role)
+		if err != nil {
+			errGroup = multierr.Append(errGroup, err)
+			r.Recorder.Event(&team, "Warning", "RBACUpdateFailed", "Failed to set RBAC for Team members")
+		}
+	}
+	if err := r.deleteStaleRoleBindings(ctx, team); err != nil {
+		errGroup = multierr.Append(errGroup, err)
+		r.Recorder.Event(&team, "Warning", "RBACUpdateFailed", "Failed to remove stale RBAC for Team members")
+	}
+
+	return ctrl.Result{}, multierr.Append(errGroup, r.Status().Update(ctx, &team))
+}
+
+func (r *TeamReconciler) putRoleBinding(ctx context.Context, team controlv1.Team, role string) error {
+	rb := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TeamRoleBindingName(team.Name, role),
+			Namespace: team.Namespace,
+		},
+	}
+	op, err := ctrl.CreateOrUpdate(ctx, r.Client, &rb, func() error {
+		if rb.ResourceVersion != "" && !metav1.IsControlledBy(&rb, &team) {
+			return fmt.Errorf("RoleBinding %q exists and is not controlled by the team", rb.Name)
+		}
+		sub := make([]rbacv1.Subject, len(team.Status.ResolvedUserRefs))
+		for i, ur := range team.Status.ResolvedUserRefs {
+			sub[i] = rbacv1.Subject{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "User",
+				Name:     r.UserPrefix + ur.Name,
+			}
+		}
+		rb.Subjects = sub
+		rb.RoleRef = rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role,
+		}
+		return ctrl.SetControllerReference(&team, &rb, r.Scheme)
+	})
+	log.FromContext(ctx).V(1).Info("reconcile RoleBinding", "operation", op)
+	return err
+}
+
+// deleteStaleRoleBindings deletes the RoleBindings controlled by the team for roles which are no longer team roles.
+func (r *TeamReconciler) deleteStaleRoleBindings(ctx context.Context, team controlv1.Team) error {
+	rbs := rbacv1.RoleBindingList{}
+	if err := r.List(ctx, &rbs, client.InNamespace(team.Namespace)); err != nil {
+		return err
+	}
+	var errGroup error
+	for _, rb := range rbs.Items {
+		if !metav1.IsControlledBy(&rb, &team) {
+			continue
+		}
+		if isInSlice(r.TeamRoles, rb.RoleRef.Name) && rb.Name == TeamRoleBindingName(team.Name, rb.RoleRef.Name) {
+			continue
+		}
+		log.FromContext(ctx).V(1).Info("delete stale RoleBinding", "rolebinding", rb.Name)
+		errGroup = multierr.Append(errGroup, client.IgnoreNotFound(r.Delete(ctx, &rb)))
+	}
+	return errGroup
+}
+
+// TeamRoleBindingName returns the name of the RoleBinding binding the given role to the members of the team.
+func TeamRoleBindingName(team, role string) string {
+	return fmt.Sprintf("team-%s-%s", team, role)
+}
+
+// resolveUserRefs returns the user references with an existing User and the names of the users not found.
+func resolveUserRefs(ctx context.Context, c client.Client, refs []controlv1.UserRef) ([]controlv1.UserRef, []string, error) {
+	resolved := make([]controlv1.UserRef, 0, len(refs))
+	notFound := []string{}
+	for _, ur := range refs {
+		user := controlv1.User{}
+		if err := c.Get(ctx, types.NamespacedName{Name: ur.Name}, &user); err != nil {
+			if apierrors.IsNotFound(err) {
+				notFound = append(notFound, ur.Name)
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get user %q: %w", ur.Name, err)
+		}
+		resolved = append(resolved, ur)
+	}
+	return resolved, notFound, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TeamReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&controlv1.Team{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Watches(&source.Kind{Type: &controlv1.OrganizationMembers{}}, handler.EnqueueRequestsFromMapFunc(r.mapOrganizationMembersToTeams)).
+		Watches(&source.Kind{Type: &controlv1.User{}}, handler.EnqueueRequestsFromMapFunc(r.mapUserToTeams)).
+		Complete(r)
+}
+
+func (r *TeamReconciler) mapOrganizationMembersToTeams(obj client.Object) []reconcile.Request {
+	teams := controlv1.TeamList{}
+	if err := r.List(context.Background(), &teams, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(teams.Items))
+	for _, team := range teams.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&team)})
+	}
+	return reqs
+}
+
+func (r *TeamReconciler) mapUserToTeams(obj client.Object) []reconcile.Request {
+	teams := controlv1.TeamList{}
+	if err := r.List(context.Background(), &teams); err != nil {
+		return nil
+	}
+	reqs := []reconcile.Request{}
+	for _, team := range teams.Items {
+		if isInSlice(team.Spec.UserRefs, controlv1.UserRef{Name: obj.GetName()}) {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&team)})
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,191 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+)
+
+var testTeam = controlv1.Team{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "team",
+		Namespace: "foo-gmbh",
+	},
+	Spec: controlv1.TeamSpec{
+		UserRefs: []controlv1.UserRef{
+			{Name: "u1"},
+			{Name: "u2"},
+		},
+	},
+}
+
+func Test_TeamReconciler_Reconcile_Success(t *testing.T) {
+	ctx := context.Background()
+	c := prepareTest(t, &testTeam, &testMemb, &u1, &u2)
+	teamRoles := []string{"foo", "bar"}
+
+	_, err := (&TeamReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		TeamRoles:  teamRoles,
+		UserPrefix: testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{
+		NamespacedName: types.NamespacedName{
+			Name:      testTeam.Name,
+			Namespace: testTeam.Namespace,
+		},
+	})
+	require.NoError(t, err)
+
+	team := controlv1.Team{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: testTeam.Name, Namespace: testTeam.Namespace}, &team))
+	assert.Equal(t, testTeam.Spec.UserRefs, team.Status.ResolvedUserRefs)
+	assert.True(t, apimeta.IsStatusConditionTrue(team.Status.Conditions, controlv1.ConditionMembersResolved))
+
+	for _, role := range teamRoles {
+		rb := rbacv1.RoleBinding{}
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: TeamRoleBindingName(testTeam.Name, role), Namespace: testTeam.Namespace}, &rb))
+		assert.Equal(t, role, rb.RoleRef.Name)
+		assert.ElementsMatch(t, []rbacv1.Subject{
+			{APIGroup: rbacv1.GroupName, Kind: "User", Name: testUserPrefix + "u1"},
+			{APIGroup: rbacv1.GroupName, Kind: "User", Name: testUserPrefix + "u2"},
+		}, rb.Subjects)
+		require.Len(t, rb.OwnerReferences, 1, "controller must set owner reference")
+		assert.Equal(t, testTeam.Name, rb.OwnerReferences[0].Name)
+	}
+}
+
+func Test_TeamReconciler_Reconcile_UnresolvedUsers(t *testing.T) {
+	tests := map[string]struct {
+		users  []controlv1.UserRef
+		reason string
+	}{
+		"user not found": {
+			users:  []controlv1.UserRef{{Name: "u1"}, {Name: "ghost"}},
+			reason: controlv1.ConditionReasonUserNotFound,
+		},
+		"user not organization member": {
+			users:  []controlv1.UserRef{{Name: "u1"}, {Name: "outsider"}},
+			reason: controlv1.ConditionReasonNotOrganizationMember,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			team := *testTeam.DeepCopy()
+			team.Spec.UserRefs = tc.users
+			outsider := controlv1.User{ObjectMeta: metav1.ObjectMeta{Name: "outsider"}}
+			c := prepareTest(t, &team, &testMemb, &u1, &outsider)
+
+			_, err := (&TeamReconciler{
+				Client:   c,
+				Scheme:   c.Scheme(),
+				Recorder: record.NewFakeRecorder(3),
+
+				TeamRoles:  []string{"foo"},
+				UserPrefix: testUserPrefix,
+			}).Reconcile(ctx, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      team.Name,
+					Namespace: team.Namespace,
+				},
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: team.Name, Namespace: team.Namespace}, &team))
+			assert.Equal(t, []controlv1.UserRef{{Name: "u1"}}, team.Status.ResolvedUserRefs)
+			cond := apimeta.FindStatusCondition(team.Status.Conditions, controlv1.ConditionMembersResolved)
+			require.NotNil(t, cond)
+			assert.Equal(t, metav1.ConditionFalse, cond.Status)
+			assert.Equal(t, tc.reason, cond.Reason)
+
+			rb := rbacv1.RoleBinding{}
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: TeamRoleBindingName(team.Name, "foo"), Namespace: team.Namespace}, &rb))
+			assert.Equal(t, []rbacv1.Subject{
+				{APIGroup: rbacv1.GroupName, Kind: "User", Name: testUserPrefix + "u1"},
+			}, rb.Subjects)
+		})
+	}
+}
+
+func Test_TeamReconciler_Reconcile_StaleRoleBindings(t *testing.T) {
+	ctx := context.Background()
+	team := *testTeam.DeepCopy()
+	stale := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      TeamRoleBindingName(team.Name, "old"),
+			Namespace: team.Namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: controlv1.GroupVersion.String(),
+				Kind:       "Team",
+				Name:       team.Name,
+				Controller: pointer.Bool(true),
+			}},
+		},
+		RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "old"},
+	}
+	unrelated := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: team.Namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "old"},
+	}
+
+	c := prepareTest(t, &team, &testMemb, &u1, &u2, stale, unrelated)
+	_, err := (&TeamReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		TeamRoles:  []string{"foo"},
+		UserPrefix: testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&team)})
+	require.NoError(t, err)
+
+	rb := rbacv1.RoleBinding{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: TeamRoleBindingName(team.Name, "foo"), Namespace: team.Namespace}, &rb))
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(stale), &rb)), "stale RoleBindings must be removed")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(unrelated), &rb), "unrelated RoleBindings are kept")
+}
+
+func Test_TeamReconciler_Reconcile_NoAdoption(t *testing.T) {
+	ctx := context.Background()
+	team := *testTeam.DeepCopy()
+	foreign := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: TeamRoleBindingName(team.Name, "foo"), Namespace: team.Namespace},
+		Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: "User", Name: "creator"}},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "foo"},
+	}
+
+	c := prepareTest(t, &team, &testMemb, &u1, &u2, foreign)
+	_, err := (&TeamReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+
+		TeamRoles:  []string{"foo"},
+		UserPrefix: testUserPrefix,
+	}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&team)})
+	require.ErrorContains(t, err, "not controlled by the team")
+
+	rb := rbacv1.RoleBinding{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(foreign), &rb))
+	assert.Equal(t, foreign.Subjects, rb.Subjects, "RoleBindings not controlled by the team must not be changed")
+	assert.Empty(t, rb.OwnerReferences)
+}
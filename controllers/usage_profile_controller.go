@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+// UsageProfileFinalizer is set on usage profiles, so the resources created from a usage profile are pruned before it is removed.
+const UsageProfileFinalizer = "appuio.io/usage-profile"
+
+// UsageProfileReconciler reconciles UsageProfile resources.
+// It applies the resources of the usage profile to all namespaces of organizations using the usage profile.
+// The resources are controlled by the usage profile, only resources controlled by the usage profile are pruned.
+type UsageProfileReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Scheme   *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=appuio.io,resources=usageprofiles,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=usageprofiles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=appuio.io,resources=usageprofiles/finalizers,verbs=update
+//+kubebuilder:rbac:groups="rbac.appuio.io",resources=organizations,verbs=get;list;watch
+//+kubebuilder:rbac:groups="organization.appuio.io",resources=organizations,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// The controller needs to be able to manage the usageProfileKinds.
+//+kubebuilder:rbac:groups="",resources=configmaps;limitranges;resourcequotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// usageProfileKinds are the kinds of resources a usage profile may contain.
+var usageProfileKinds = []schema.GroupKind{
+	{Kind: "ConfigMap"},
+	{Kind: "LimitRange"},
+	{Kind: "ResourceQuota"},
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"},
+}
+
+// Reconcile applies the resources of the usage profile to all namespaces of the organizations using the profile.
+// Deleted usage profiles prune their resources from all namespaces before the finalizer is removed.
+func (r *UsageProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.V(1).WithValues("request", req).Info("Reconciling")
+
+	profile := controlv1.UsageProfile{}
+	if err := r.Get(ctx, req.NamespacedName, &profile); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !profile.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&profile, UsageProfileFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.pruneResources(ctx, profile, nil, nil); err != nil {
+			r.Recorder.Event(&profile, "Warning", "PruneFailed", "Failed to prune usage profile resources")
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(&profile, UsageProfileFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &profile)
+	}
+
+	if controllerutil.AddFinalizer(&profile, UsageProfileFinalizer) {
+		if err := r.Update(ctx, &profile); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	resources, err := renderUsageProfileResources(profile)
+	if err != nil {
+		r.Recorder.Event(&profile, "Warning", "RenderFailed", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	orgs, err := r.organizationsForProfile(ctx, profile.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var namespaces []corev1.Namespace
+	for _, org := range orgs {
+		nsl := corev1.NamespaceList{}
+		if err := r.List(ctx, &nsl, client.MatchingLabels{controlv1.OrganizationLabel: org.Name}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to list namespaces of organization %q: %w", org.Name, err)
+		}
+		namespaces = append(namespaces, nsl.Items...)
+	}
+
+	var applied, failed int
+	var errGroup error
+	for _, ns := range namespaces {
+		for _, res := range resources {
+			if err := r.applyResource(ctx, ns.Name, res); err != nil {
+				failed++
+				errGroup = multierr.Append(errGroup, fmt.Errorf("failed to apply %s %q in namespace %q: %w", res.GetKind(), res.GetName(), ns.Name, err))
+				continue
+			}
+			applied++
+		}
+	}
+	errGroup = multierr.Append(errGroup, r.pruneResources(ctx, profile, namespaces, resources))
+
+	profile.Status.Organizations = len(orgs)
+	profile.Status.Namespaces = len(namespaces)
+	profile.Status.AppliedResources = applied
+	profile.Status.FailedResources = failed
+	if errGroup == nil {
+		// Only forget resources once they are pruned everywhere
+		profile.Status.Resources = usageProfileResourceRefs(resources)
+		apimeta.SetStatusCondition(&profile.Status.Conditions, metav1.Condition{
+			Type:   controlv1.ConditionResourcesApplied,
+			Status: metav1.ConditionTrue,
+			Reason: controlv1.ConditionResourcesApplied,
+		})
+	} else {
+		profile.Status.Resources = mergeUsageProfileResourceRefs(profile.Status.Resources, usageProfileResourceRefs(resources))
+		r.Recorder.Event(&profile, "Warning", controlv1.ConditionReasonApplyFailed, "Failed to apply usage profile resources")
+		apimeta.SetStatusCondition(&profile.Status.Conditions, metav1.Condition{
+			Type:    controlv1.ConditionResourcesApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  controlv1.ConditionReasonApplyFailed,
+			Message: errGroup.Error(),
+		})
+	}
+
+	return ctrl.Result{}, multierr.Append(errGroup, r.Status().Update(ctx, &profile))
+}
+
+func (r *UsageProfileReconciler) organizationsForProfile(ctx context.Context, profile string) ([]orgv1.Organization, error) {
+	orgl := orgv1.OrganizationList{}
+	if err := r.List(ctx, &orgl); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	orgs := make([]orgv1.Organization, 0, len(orgl.Items))
+	for _, org := range orgl.Items {
+		if org.Annotations[controlv1.UsageProfileAnnotation] == profile {
+			orgs = append(orgs, org)
+		}
+	}
+	return orgs, nil
+}
+
+func (r *UsageProfileReconciler) applyResource(ctx context.Context, namespace string, res *unstructured.Unstructured) error {
+	obj := res.DeepCopy()
+	obj.SetNamespace(namespace)
+	return r.Patch(ctx, obj, client.Apply, client.ForceOwnership, client.FieldOwner("control-api"))
+}
+
+// pruneResources deletes the resources created by the usage profile which are no longer part of the usage profile
+// or are in a namespace the usage profile no longer applies to, for example after the usage profile annotation of an organization was removed or changed.
+// Without namespaces and resources, all resources created by the usage profile are pruned.
+// Resources are found by the usage profile label for every kind the usage profile contains or contained when last reconciled.
+// The label can be set by anyone able to edit the resources, so only resources controlled by the usage profile are deleted.
+func (r *UsageProfileReconciler) pruneResources(ctx context.Context, profile controlv1.UsageProfile, namespaces []corev1.Namespace, resources []*unstructured.Unstructured) error {
+	current := usageProfileResourceRefs(resources)
+	nsNames := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		nsNames = append(nsNames, ns.Name)
+	}
+
+	var errGroup error
+	for _, gvk := range usageProfileResourceKinds(mergeUsageProfileResourceRefs(profile.Status.Resources, current)) {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.List(ctx, list, client.MatchingLabels{controlv1.UsageProfileLabel: profile.Name}); err != nil {
+			errGroup = multierr.Append(errGroup, fmt.Errorf("failed to list %s: %w", gvk.Kind, err))
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !metav1.IsControlledBy(obj, &profile) {
+				continue
+			}
+			ref := controlv1.UsageProfileResourceRef{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: obj.GetName()}
+			if isInSlice(nsNames, obj.GetNamespace()) && isInSlice(current, ref) {
+				continue
+			}
+			log.FromContext(ctx).V(1).Info("pruning resource", "kind", gvk.Kind, "name", obj.GetName(), "namespace", obj.GetNamespace())
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				errGroup = multierr.Append(errGroup, fmt.Errorf("failed to prune %s %q in namespace %q: %w", gvk.Kind, obj.GetName(), obj.GetNamespace(), err))
+			}
+		}
+	}
+	return errGroup
+}
+
+// renderUsageProfileResources decodes the resources of the usage profile.
+// The key of the resource map is used as the name of the resource.
+// The resources are labeled with the name of the usage profile and controlled by it.
+func renderUsageProfileResources(profile controlv1.UsageProfile) ([]*unstructured.Unstructured, error) {
+	names := make([]string, 0, len(profile.Spec.Resources))
+	for name := range profile.Spec.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources := make([]*unstructured.Unstructured, 0, len(names))
+	for _, name := range names {
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(profile.Spec.Resources[name].Raw, &obj.Object); err != nil {
+			return nil, fmt.Errorf("failed to decode resource %q: %w", name, err)
+		}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("resource %q is missing apiVersion or kind", name)
+		}
+		if gk := obj.GroupVersionKind().GroupKind(); !isInSlice(usageProfileKinds, gk) {
+			return nil, fmt.Errorf("resource %q is of kind %q which is not allowed in usage profiles", name, gk)
+		}
+		obj.SetName(name)
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[controlv1.UsageProfileLabel] = profile.Name
+		obj.SetLabels(labels)
+		obj.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(&profile, controlv1.GroupVersion.WithKind("UsageProfile"))})
+		resources = append(resources, obj)
+	}
+	return resources, nil
+}
+
+func usageProfileResourceRefs(resources []*unstructured.Unstructured) []controlv1.UsageProfileResourceRef {
+	refs := make([]controlv1.UsageProfileResourceRef, 0, len(resources))
+	for _, res := range resources {
+		refs = append(refs, controlv1.UsageProfileResourceRef{
+			APIVersion: res.GetAPIVersion(),
+			Kind:       res.GetKind(),
+			Name:       res.GetName(),
+		})
+	}
+	return refs
+}
+
+// usageProfileResourceKinds returns the distinct kinds of the referenced resources.
+func usageProfileResourceKinds(refs []controlv1.UsageProfileResourceRef) []schema.GroupVersionKind {
+	kinds := make([]schema.GroupVersionKind, 0)
+	for _, ref := range refs {
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+		if !isInSlice(kinds, gvk) {
+			kinds = append(kinds, gvk)
+		}
+	}
+	return kinds
+}
+
+func mergeUsageProfileResourceRefs(a, b []controlv1.UsageProfileResourceRef) []controlv1.UsageProfileResourceRef {
+	merged := append([]controlv1.UsageProfileResourceRef{}, a...)
+	for _, ref := range b {
+		if !isInSlice(merged, ref) {
+			merged = append(merged, ref)
+		}
+	}
+	return merged
+}
+
+func isInSlice[T comparable](s []T, e T) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UsageProfileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&controlv1.UsageProfile{}).
+		Watches(&source.Kind{Type: &orgv1.Organization{}}, handler.EnqueueRequestsFromMapFunc(r.mapOrganizationToProfile)).
+		Watches(&source.Kind{Type: &orgv1.Organization{}}, enqueuePreviousProfile(r.mapOrganizationToProfile)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.mapNamespaceToProfile)).
+		Watches(&source.Kind{Type: &corev1.Namespace{}}, enqueuePreviousProfile(r.mapNamespaceToProfile)).
+		Complete(r)
+}
+
+// enqueuePreviousProfile enqueues the usage profile an object mapped to before an update.
+// This allows the previous usage profile to prune its resources if the usage profile annotation or the organization of a namespace changes.
+func enqueuePreviousProfile(mapFn handler.MapFunc) handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			for _, req := range mapFn(e.ObjectOld) {
+				q.Add(req)
+			}
+		},
+	}
+}
+
+func (r *UsageProfileReconciler) mapOrganizationToProfile(obj client.Object) []reconcile.Request {
+	profile := obj.GetAnnotations()[controlv1.UsageProfileAnnotation]
+	if profile == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: profile}}}
+}
+
+func (r *UsageProfileReconciler) mapNamespaceToProfile(obj client.Object) []reconcile.Request {
+	orgName := obj.GetLabels()[controlv1.OrganizationLabel]
+	if orgName == "" {
+		return nil
+	}
+	org := orgv1.Organization{}
+	if err := r.Get(context.Background(), client.ObjectKey{Name: orgName}, &org); err != nil {
+		return nil
+	}
+	return r.mapOrganizationToProfile(&org)
+}
@@ -0,0 +1,272 @@
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	. "github.com/appuio/control-api/controllers"
+)
+
+func Test_UsageProfileReconciler_Reconcile_Success(t *testing.T) {
+	ctx := context.Background()
+
+	profile := controlv1.UsageProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+		Spec: controlv1.UsageProfileSpec{
+			Resources: map[string]runtime.RawExtension{
+				"limits": {Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"cpu":"2"}}`)},
+				"extra":  {Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"foo":"bar"}}`)},
+			},
+		},
+	}
+	org := orgv1.Organization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+			Annotations: map[string]string{
+				controlv1.UsageProfileAnnotation: "default",
+			},
+		},
+	}
+	otherOrg := orgv1.Organization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "bar",
+		},
+	}
+	ns1 := namespaceForOrg("foo-1", "foo")
+	ns2 := namespaceForOrg("foo-2", "foo")
+	ns3 := namespaceForOrg("bar-1", "bar")
+
+	c := prepareTest(t, &profile, &org, &otherOrg, ns1, ns2, ns3)
+
+	subject := UsageProfileReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+	}
+
+	_, err := subject.Reconcile(ctx, requestFor(&profile))
+	require.NoError(t, err)
+
+	for _, ns := range []string{"foo-1", "foo-2"} {
+		cm := corev1.ConfigMap{}
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: ns}, &cm))
+		assert.Equal(t, "2", cm.Data["cpu"])
+		assert.Equal(t, "default", cm.Labels[controlv1.UsageProfileLabel])
+		require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "extra", Namespace: ns}, &cm))
+	}
+	err = c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: "bar-1"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err), "resources should not be applied to other organizations")
+
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&profile), &profile))
+	assert.Equal(t, 1, profile.Status.Organizations)
+	assert.Equal(t, 2, profile.Status.Namespaces)
+	assert.Equal(t, 4, profile.Status.AppliedResources)
+	assert.Equal(t, 0, profile.Status.FailedResources)
+	assert.Len(t, profile.Status.Resources, 2)
+	assert.True(t, apimeta.IsStatusConditionTrue(profile.Status.Conditions, controlv1.ConditionResourcesApplied))
+
+	t.Run("prunes removed resources", func(t *testing.T) {
+		delete(profile.Spec.Resources, "extra")
+		require.NoError(t, c.Update(ctx, &profile))
+
+		_, err := subject.Reconcile(ctx, requestFor(&profile))
+		require.NoError(t, err)
+
+		for _, ns := range []string{"foo-1", "foo-2"} {
+			require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: ns}, &corev1.ConfigMap{}))
+			err := c.Get(ctx, client.ObjectKey{Name: "extra", Namespace: ns}, &corev1.ConfigMap{})
+			assert.True(t, apierrors.IsNotFound(err), "removed resource should be pruned")
+		}
+
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&profile), &profile))
+		assert.Equal(t, 2, profile.Status.AppliedResources)
+		assert.Equal(t, []controlv1.UsageProfileResourceRef{{APIVersion: "v1", Kind: "ConfigMap", Name: "limits"}}, profile.Status.Resources)
+	})
+
+	t.Run("prunes resources of organizations no longer using the profile", func(t *testing.T) {
+		unmanaged := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "foo-1"}}
+		require.NoError(t, c.Create(ctx, &unmanaged))
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&org), &org))
+		delete(org.Annotations, controlv1.UsageProfileAnnotation)
+		require.NoError(t, c.Update(ctx, &org))
+
+		_, err := subject.Reconcile(ctx, requestFor(&profile))
+		require.NoError(t, err)
+
+		for _, ns := range []string{"foo-1", "foo-2"} {
+			err := c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: ns}, &corev1.ConfigMap{})
+			assert.True(t, apierrors.IsNotFound(err), "resource should be pruned")
+		}
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&unmanaged), &unmanaged), "resources not created by the profile should be kept")
+
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&profile), &profile))
+		assert.Equal(t, 0, profile.Status.Organizations)
+		assert.Equal(t, 0, profile.Status.AppliedResources)
+	})
+}
+
+func Test_UsageProfileReconciler_Reconcile_KeepsUncontrolledResources(t *testing.T) {
+	ctx := context.Background()
+
+	profile := controlv1.UsageProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+			UID:  "profile-uid",
+		},
+		Spec: controlv1.UsageProfileSpec{
+			Resources: map[string]runtime.RawExtension{
+				"limits": {Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"cpu":"2"}}`)},
+			},
+		},
+	}
+	org := orgv1.Organization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{controlv1.UsageProfileAnnotation: "default"},
+		},
+	}
+	// A tenant can label their own resources with the usage profile label
+	labeled := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Name:      "tenant",
+		Namespace: "foo-1",
+		Labels:    map[string]string{controlv1.UsageProfileLabel: "default"},
+	}}
+	c := prepareTest(t, &profile, &org, namespaceForOrg("foo-1", "foo"), &labeled)
+
+	subject := UsageProfileReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+	}
+	_, err := subject.Reconcile(ctx, requestFor(&profile))
+	require.NoError(t, err)
+
+	cm := corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: "foo-1"}, &cm))
+	assert.True(t, metav1.IsControlledBy(&cm, &profile), "applied resources must be controlled by the profile")
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&labeled), &corev1.ConfigMap{}), "labeled resources not controlled by the profile must be kept")
+}
+
+func Test_UsageProfileReconciler_Reconcile_Deleted(t *testing.T) {
+	ctx := context.Background()
+
+	profile := controlv1.UsageProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+			UID:  "profile-uid",
+		},
+		Spec: controlv1.UsageProfileSpec{
+			Resources: map[string]runtime.RawExtension{
+				"limits": {Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"cpu":"2"}}`)},
+			},
+		},
+	}
+	org := orgv1.Organization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Annotations: map[string]string{controlv1.UsageProfileAnnotation: "default"},
+		},
+	}
+	c := prepareTest(t, &profile, &org, namespaceForOrg("foo-1", "foo"))
+
+	subject := UsageProfileReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(3),
+	}
+	_, err := subject.Reconcile(ctx, requestFor(&profile))
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(&profile), &profile))
+	assert.Contains(t, profile.Finalizers, UsageProfileFinalizer)
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: "foo-1"}, &corev1.ConfigMap{}))
+
+	require.NoError(t, c.Delete(ctx, &profile))
+	_, err = subject.Reconcile(ctx, requestFor(&profile))
+	require.NoError(t, err)
+
+	err = c.Get(ctx, client.ObjectKey{Name: "limits", Namespace: "foo-1"}, &corev1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err), "resources of deleted profiles must be pruned")
+	err = c.Get(ctx, client.ObjectKeyFromObject(&profile), &profile)
+	assert.True(t, apierrors.IsNotFound(err), "profile must be removed once its resources are pruned")
+}
+
+func Test_UsageProfileReconciler_Reconcile_InvalidResource(t *testing.T) {
+	ctx := context.Background()
+
+	profile := controlv1.UsageProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+		Spec: controlv1.UsageProfileSpec{
+			Resources: map[string]runtime.RawExtension{
+				"broken": {Raw: []byte(`{"data":{"cpu":"2"}}`)},
+			},
+		},
+	}
+
+	c := prepareTest(t, &profile)
+	recorder := record.NewFakeRecorder(3)
+
+	subject := UsageProfileReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: recorder,
+	}
+
+	_, err := subject.Reconcile(ctx, requestFor(&profile))
+	require.Error(t, err)
+	require.Len(t, recorder.Events, 1)
+}
+
+func Test_UsageProfileReconciler_Reconcile_KindNotAllowed(t *testing.T) {
+	ctx := context.Background()
+
+	profile := controlv1.UsageProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+		Spec: controlv1.UsageProfileSpec{
+			Resources: map[string]runtime.RawExtension{
+				"admin": {Raw: []byte(`{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRoleBinding"}`)},
+			},
+		},
+	}
+
+	c := prepareTest(t, &profile)
+	recorder := record.NewFakeRecorder(3)
+
+	subject := UsageProfileReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: recorder,
+	}
+
+	_, err := subject.Reconcile(ctx, requestFor(&profile))
+	require.ErrorContains(t, err, "not allowed")
+	require.Len(t, recorder.Events, 1)
+}
+
+func namespaceForOrg(name, org string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				controlv1.OrganizationLabel: org,
+			},
+		},
+	}
+}
@@ -32,7 +32,7 @@ type UserReconciler struct {
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
-// Reconcile reacts on changes of users and mirrors these changes to Keycloak
+// Reconcile reacts on changes of users and grants them access to their own User resource
 func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 	log.V(1).WithValues("request", req).Info("Reconciling")
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/appuio/control-api/controllers/targetref"
+)
+
+// organizationRoleFlags configures the roles of organization members and admins.
+// The API server and the controller must be started with the same values, so both commands register the flags through addFlags.
+type organizationRoleFlags struct {
+	rolePrefix         string
+	memberRoles        []string
+	allowedMemberRoles []string
+	adminRoles         []string
+}
+
+func (f *organizationRoleFlags) addFlags(cmd *cobra.Command) {
+	fs := cmd.Flags()
+	fs.StringVar(&f.rolePrefix, "organization-role-prefix", targetref.DefaultOrganizationRolePrefix, "Prefix of the ClusterRoles granting the roles of role-scoped invitations and of the organization RoleBindings. The creator of an organization is bound to <prefix>admin through the RoleBinding of the same name. Role-scoped invitations grant <prefix><role>, which must be one of the member roles or allowed member roles.")
+	fs.StringSliceVar(&f.memberRoles, "member-roles", []string{}, "ClusterRoles to assign to every organization member without roles for its namespace")
+	fs.StringSliceVar(&f.allowedMemberRoles, "allowed-member-roles", []string{}, "ClusterRoles which can be assigned to individual organization members through the roles of their user reference, in addition to the member roles")
+	fs.StringSliceVar(&f.adminRoles, "organization-admin-roles", []string{}, "ClusterRoles granting admin permissions on an organization. Changes removing the last subject bound to one of them in an organization namespace are rejected. Defaults to <organization-role-prefix>admin.")
+}
+
+// invitationValidityFlags configures the validity of invitations.
+// The API server sets the validity when issuing tokens and the webhook of the controller rejects invitations requesting a longer validity,
+// so both commands register the flags through addFlags.
+type invitationValidityFlags struct {
+	validFor    time.Duration
+	maxValidFor time.Duration
+}
+
+func (f *invitationValidityFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&f.validFor, "invitation-valid-for", 30*24*time.Hour, "The duration an invitation token is valid for")
+	cmd.Flags().DurationVar(&f.maxValidFor, "invitation-max-valid-for", 90*24*time.Hour, "Maximum validity an invitation can request or be extended by when resending it. Zero allows any validity.")
+}
+
+// invitationTokenHandoffFlags configures the namespace the API server hands off the tokens of invitations to the controller sending the invitation emails in.
+// Only the API server and the controller must be allowed to access secrets in the namespace.
+type invitationTokenHandoffFlags struct {
+	namespace string
+}
+
+func (f *invitationTokenHandoffFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.namespace, "invitation-token-handoff-ns", "default", "Namespace to store the tokens of invitations in until the invitation email is sent. Only the API server and the controller must be allowed to access secrets in the namespace.")
+}
@@ -19,6 +19,7 @@ require (
 	k8s.io/apimachinery v0.26.2
 	k8s.io/apiserver v0.26.2
 	k8s.io/client-go v0.26.2
+	k8s.io/component-base v0.26.2
 	k8s.io/klog/v2 v2.110.1
 	sigs.k8s.io/apiserver-runtime v1.1.2-0.20231017233931-4d54d00b524a
 	sigs.k8s.io/controller-runtime v0.14.6
@@ -131,7 +132,6 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	k8s.io/apiextensions-apiserver v0.26.1 // indirect
-	k8s.io/component-base v0.26.2 // indirect
 	k8s.io/kms v0.26.2 // indirect
 	k8s.io/kube-openapi v0.0.0-20230109183929-3758b55a6596 // indirect
 	k8s.io/utils v0.0.0-20231127182322-b307cd553661
@@ -0,0 +1,11 @@
+//go:build idpfake
+
+package main
+
+import "github.com/appuio/control-api/pkg/idp"
+
+// The in-memory identity provider loses all users and groups on restart.
+// It's only available in builds with the `idpfake` tag, for local development.
+func init() {
+	idpBackends["fake"] = func() idp.Client { return idp.NewFakeClient() }
+}
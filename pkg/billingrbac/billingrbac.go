@@ -17,9 +17,19 @@ type ClusterRolesParams struct {
 	AllowSubjectsToViewRole bool
 }
 
+// ViewerRoleName returns the name of the ClusterRole and ClusterRoleBinding granting view access to the given BillingEntity.
+func ViewerRoleName(beName string) string {
+	return fmt.Sprintf("billingentities-%s-viewer", beName)
+}
+
+// AdminRoleName returns the name of the ClusterRole and ClusterRoleBinding granting admin access to the given BillingEntity.
+func AdminRoleName(beName string) string {
+	return fmt.Sprintf("billingentities-%s-admin", beName)
+}
+
 // ClusterRoles returns the ClusterRoles and ClusterRoleBindings for the given BillingEntity.
 func ClusterRoles(beName string, p ClusterRolesParams) (ar *rbacv1.ClusterRole, arBinding *rbacv1.ClusterRoleBinding, vr *rbacv1.ClusterRole, vrBinding *rbacv1.ClusterRoleBinding) {
-	viewRoleName := fmt.Sprintf("billingentities-%s-viewer", beName)
+	viewRoleName := ViewerRoleName(beName)
 	viewRole := &rbacv1.ClusterRole{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "rbac.authorization.k8s.io/v1",
@@ -60,7 +70,7 @@ func ClusterRoles(beName string, p ClusterRolesParams) (ar *rbacv1.ClusterRole,
 			Name:     viewRoleName,
 		},
 	}
-	adminRoleName := fmt.Sprintf("billingentities-%s-admin", beName)
+	adminRoleName := AdminRoleName(beName)
 	adminRole := &rbacv1.ClusterRole{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "rbac.authorization.k8s.io/v1",
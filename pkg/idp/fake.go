@@ -0,0 +1,181 @@
+package idp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var _ Client = &FakeClient{}
+
+// FakeClient is an in-memory identity provider.
+// It is intended for testing and local development.
+type FakeClient struct {
+	mu sync.Mutex
+
+	users   map[string]User
+	groups  map[string]Group
+	members map[string]map[string]struct{}
+}
+
+// NewFakeClient returns a new empty in-memory identity provider.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		users:   map[string]User{},
+		groups:  map[string]Group{},
+		members: map[string]map[string]struct{}{},
+	}
+}
+
+// GetUser returns the user with the given username or ErrNotFound.
+func (f *FakeClient) GetUser(_ context.Context, username string) (User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, u := range f.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return User{}, fmt.Errorf("user %q: %w", username, ErrNotFound)
+}
+
+// CreateUser creates the user and returns it including its ID.
+func (f *FakeClient) CreateUser(_ context.Context, user User) (User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, u := range f.users {
+		if u.Username == user.Username {
+			return User{}, fmt.Errorf("user %q already exists", user.Username)
+		}
+	}
+	user.ID = uuid.NewString()
+	f.users[user.ID] = user
+	return user, nil
+}
+
+// UpdateUser updates the user with the ID of the given user.
+func (f *FakeClient) UpdateUser(_ context.Context, user User) (User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.users[user.ID]; !ok {
+		return User{}, fmt.Errorf("user %q: %w", user.ID, ErrNotFound)
+	}
+	f.users[user.ID] = user
+	return user, nil
+}
+
+// DeleteUser deletes the user with the given ID.
+func (f *FakeClient) DeleteUser(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.users[id]; !ok {
+		return fmt.Errorf("user %q: %w", id, ErrNotFound)
+	}
+	delete(f.users, id)
+	for _, m := range f.members {
+		delete(m, id)
+	}
+	return nil
+}
+
+// CreateGroup creates the group if it does not exist and returns it including its ID.
+func (f *FakeClient) CreateGroup(_ context.Context, group Group) (Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if group.ParentID != "" {
+		if _, ok := f.groups[group.ParentID]; !ok {
+			return Group{}, fmt.Errorf("parent group %q: %w", group.ParentID, ErrNotFound)
+		}
+	}
+	for _, g := range f.groups {
+		if g.Name == group.Name && g.ParentID == group.ParentID {
+			return g, nil
+		}
+	}
+	group.ID = uuid.NewString()
+	f.groups[group.ID] = group
+	f.members[group.ID] = map[string]struct{}{}
+	return group, nil
+}
+
+// DeleteGroup deletes the group with the given ID and all its subgroups.
+func (f *FakeClient) DeleteGroup(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.groups[id]; !ok {
+		return fmt.Errorf("group %q: %w", id, ErrNotFound)
+	}
+	f.deleteGroup(id)
+	return nil
+}
+
+func (f *FakeClient) deleteGroup(id string) {
+	for _, g := range f.groups {
+		if g.ParentID == id {
+			f.deleteGroup(g.ID)
+		}
+	}
+	delete(f.groups, id)
+	delete(f.members, id)
+}
+
+// SetGroupMembers sets the members of the group with the given ID to exactly the given usernames.
+func (f *FakeClient) SetGroupMembers(_ context.Context, groupID string, usernames []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.groups[groupID]; !ok {
+		return fmt.Errorf("group %q: %w", groupID, ErrNotFound)
+	}
+
+	members := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		found := false
+		for _, u := range f.users {
+			if u.Username == username {
+				members[u.ID] = struct{}{}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("user %q: %w", username, ErrNotFound)
+		}
+	}
+	f.members[groupID] = members
+	return nil
+}
+
+// GetGroup returns the group with the given ID or ErrNotFound.
+func (f *FakeClient) GetGroup(id string) (Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	g, ok := f.groups[id]
+	if !ok {
+		return Group{}, fmt.Errorf("group %q: %w", id, ErrNotFound)
+	}
+	return g, nil
+}
+
+// GroupMembers returns the sorted usernames of the members of the group with the given ID.
+func (f *FakeClient) GroupMembers(id string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	usernames := make([]string, 0, len(f.members[id]))
+	for uid := range f.members[id] {
+		usernames = append(usernames, f.users[uid].Username)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
@@ -0,0 +1,49 @@
+// Package idp contains clients to synchronize users and groups to an identity provider.
+package idp
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned if the requested user or group does not exist in the identity provider.
+var ErrNotFound = errors.New("not found")
+
+// User is a user in the identity provider.
+type User struct {
+	// ID is the identity provider internal ID of the user.
+	ID string
+	// Username is the unique name of the user.
+	Username    string
+	Email       string
+	DisplayName string
+}
+
+// Group is a group in the identity provider.
+type Group struct {
+	// ID is the identity provider internal ID of the group.
+	ID string
+	// Name is the name of the group. It is unique for the parent group.
+	Name string
+	// ParentID is the ID of the parent group. It is empty for top-level groups.
+	ParentID string
+}
+
+// Client is the interface to an identity provider.
+type Client interface {
+	// GetUser returns the user with the given username or ErrNotFound.
+	GetUser(ctx context.Context, username string) (User, error)
+	// CreateUser creates the user and returns it including its ID.
+	CreateUser(ctx context.Context, user User) (User, error)
+	// UpdateUser updates the user with the ID of the given user.
+	UpdateUser(ctx context.Context, user User) (User, error)
+	// DeleteUser deletes the user with the given ID.
+	DeleteUser(ctx context.Context, id string) error
+
+	// CreateGroup creates the group if it does not exist and returns it including its ID.
+	CreateGroup(ctx context.Context, group Group) (Group, error)
+	// DeleteGroup deletes the group with the given ID.
+	DeleteGroup(ctx context.Context, id string) error
+	// SetGroupMembers sets the members of the group with the given ID to exactly the given usernames.
+	SetGroupMembers(ctx context.Context, groupID string, usernames []string) error
+}
@@ -0,0 +1,333 @@
+package idp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ Client = &KeycloakClient{}
+
+// KeycloakClient is an identity provider client using the Keycloak Admin REST API.
+type KeycloakClient struct {
+	// BaseURL is the URL of the Keycloak server, e.g. https://id.example.com.
+	BaseURL string
+	// Realm is the realm users and groups are managed in.
+	Realm string
+	// LoginRealm is the realm used to authenticate. Defaults to Realm if empty.
+	LoginRealm string
+	// ClientID is the client used to authenticate. Defaults to "admin-cli" if empty.
+	ClientID string
+	Username string
+	Password string
+
+	HTTPClient *http.Client
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewKeycloakClient returns a new Keycloak client authenticating with the given credentials in the given realm.
+func NewKeycloakClient(baseURL, realm, username, password string) *KeycloakClient {
+	return &KeycloakClient{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Realm:      realm,
+		Username:   username,
+		Password:   password,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+type keycloakUser struct {
+	ID        string `json:"id,omitempty"`
+	Username  string `json:"username"`
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	Enabled   bool   `json:"enabled"`
+}
+
+type keycloakGroup struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+// GetUser returns the user with the given username or ErrNotFound.
+func (k *KeycloakClient) GetUser(ctx context.Context, username string) (User, error) {
+	users := []keycloakUser{}
+	q := url.Values{"username": {username}, "exact": {"true"}}
+	if _, err := k.do(ctx, http.MethodGet, "/users?"+q.Encode(), nil, &users); err != nil {
+		return User{}, err
+	}
+	for _, u := range users {
+		if u.Username == username {
+			return fromKeycloakUser(u), nil
+		}
+	}
+	return User{}, fmt.Errorf("user %q: %w", username, ErrNotFound)
+}
+
+// CreateUser creates the user and returns it including its ID.
+func (k *KeycloakClient) CreateUser(ctx context.Context, user User) (User, error) {
+	res, err := k.do(ctx, http.MethodPost, "/users", toKeycloakUser(user), nil)
+	if err != nil {
+		return User{}, err
+	}
+	user.ID = idFromLocation(res)
+	if user.ID == "" {
+		return k.GetUser(ctx, user.Username)
+	}
+	return user, nil
+}
+
+// UpdateUser updates the user with the ID of the given user.
+func (k *KeycloakClient) UpdateUser(ctx context.Context, user User) (User, error) {
+	_, err := k.do(ctx, http.MethodPut, "/users/"+url.PathEscape(user.ID), toKeycloakUser(user), nil)
+	return user, err
+}
+
+// DeleteUser deletes the user with the given ID.
+func (k *KeycloakClient) DeleteUser(ctx context.Context, id string) error {
+	_, err := k.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// CreateGroup creates the group if it does not exist and returns it including its ID.
+func (k *KeycloakClient) CreateGroup(ctx context.Context, group Group) (Group, error) {
+	p := "/groups"
+	if group.ParentID != "" {
+		p = fmt.Sprintf("/groups/%s/children", url.PathEscape(group.ParentID))
+	}
+
+	groups := []keycloakGroup{}
+	q := url.Values{"search": {group.Name}, "exact": {"true"}, "briefRepresentation": {"true"}}
+	if _, err := k.do(ctx, http.MethodGet, p+"?"+q.Encode(), nil, &groups); err != nil {
+		return Group{}, err
+	}
+	for _, g := range groups {
+		if g.Name == group.Name {
+			group.ID = g.ID
+			return group, nil
+		}
+	}
+
+	created := keycloakGroup{}
+	res, err := k.do(ctx, http.MethodPost, p, keycloakGroup{Name: group.Name}, &created)
+	if err != nil {
+		return Group{}, err
+	}
+	group.ID = idFromLocation(res)
+	if group.ID == "" {
+		// Older Keycloak versions return the created child group in the body
+		group.ID = created.ID
+	}
+	if group.ID == "" {
+		return Group{}, fmt.Errorf("keycloak did not return an ID for group %q", group.Name)
+	}
+	return group, nil
+}
+
+// DeleteGroup deletes the group with the given ID.
+func (k *KeycloakClient) DeleteGroup(ctx context.Context, id string) error {
+	_, err := k.do(ctx, http.MethodDelete, "/groups/"+url.PathEscape(id), nil, nil)
+	return err
+}
+
+// SetGroupMembers sets the members of the group with the given ID to exactly the given usernames.
+func (k *KeycloakClient) SetGroupMembers(ctx context.Context, groupID string, usernames []string) error {
+	current := []keycloakUser{}
+	q := url.Values{"max": {"-1"}, "briefRepresentation": {"true"}}
+	if _, err := k.do(ctx, http.MethodGet, fmt.Sprintf("/groups/%s/members?%s", url.PathEscape(groupID), q.Encode()), nil, &current); err != nil {
+		return err
+	}
+
+	desired := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		desired[username] = struct{}{}
+	}
+	for _, u := range current {
+		if _, ok := desired[u.Username]; ok {
+			delete(desired, u.Username)
+			continue
+		}
+		if _, err := k.do(ctx, http.MethodDelete, memberPath(u.ID, groupID), nil, nil); err != nil {
+			return fmt.Errorf("failed to remove user %q from group: %w", u.Username, err)
+		}
+	}
+	for _, username := range usernames {
+		if _, ok := desired[username]; !ok {
+			continue
+		}
+		u, err := k.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+		if _, err := k.do(ctx, http.MethodPut, memberPath(u.ID, groupID), nil, nil); err != nil {
+			return fmt.Errorf("failed to add user %q to group: %w", username, err)
+		}
+	}
+	return nil
+}
+
+func memberPath(userID, groupID string) string {
+	return fmt.Sprintf("/users/%s/groups/%s", url.PathEscape(userID), url.PathEscape(groupID))
+}
+
+// do sends a request to the admin API of the configured realm.
+// The body is encoded as JSON and the response is decoded into out if it is not nil.
+func (k *KeycloakClient) do(ctx context.Context, method, p string, body, out any) (*http.Response, error) {
+	token, err := k.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/admin/realms/%s%s", k.BaseURL, url.PathEscape(k.Realm), p), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := k.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, p, err)
+	}
+	if out != nil && res.StatusCode != http.StatusNoContent {
+		raw, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, out); err != nil {
+				return nil, fmt.Errorf("failed to decode response of %s %s: %w", method, p, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// getToken returns a cached access token or requests a new one.
+func (k *KeycloakClient) getToken(ctx context.Context) (string, error) {
+	k.tokenMu.Lock()
+	defer k.tokenMu.Unlock()
+
+	if k.token != "" && time.Now().Before(k.tokenExpiry) {
+		return k.token, nil
+	}
+
+	loginRealm := k.LoginRealm
+	if loginRealm == "" {
+		loginRealm = k.Realm
+	}
+	clientID := k.ClientID
+	if clientID == "" {
+		clientID = "admin-cli"
+	}
+
+	form := url.Values{
+		"grant_type": {"password"},
+		"client_id":  {clientID},
+		"username":   {k.Username},
+		"password":   {k.Password},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token", k.BaseURL, url.PathEscape(loginRealm)),
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := k.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if err := checkResponse(res); err != nil {
+		return "", fmt.Errorf("failed to login to keycloak: %w", err)
+	}
+
+	tokenRes := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&tokenRes); err != nil {
+		return "", fmt.Errorf("failed to decode keycloak token: %w", err)
+	}
+
+	k.token = tokenRes.AccessToken
+	// Refresh the token a bit before it actually expires
+	k.tokenExpiry = time.Now().Add(time.Duration(tokenRes.ExpiresIn)*time.Second - 10*time.Second)
+	return k.token, nil
+}
+
+func (k *KeycloakClient) httpClient() *http.Client {
+	if k.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return k.HTTPClient
+}
+
+func checkResponse(res *http.Response) error {
+	if res.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(string(msg)), ErrNotFound)
+	}
+	return fmt.Errorf("unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+func idFromLocation(res *http.Response) string {
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return ""
+	}
+	return path.Base(loc)
+}
+
+func toKeycloakUser(u User) keycloakUser {
+	first, last, _ := strings.Cut(u.DisplayName, " ")
+	return keycloakUser{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: first,
+		LastName:  last,
+		Enabled:   true,
+	}
+}
+
+func fromKeycloakUser(u keycloakUser) User {
+	return User{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		DisplayName: strings.TrimSpace(u.FirstName + " " + u.LastName),
+	}
+}
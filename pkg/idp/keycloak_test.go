@@ -0,0 +1,120 @@
+package idp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/appuio/control-api/pkg/idp"
+)
+
+func TestKeycloakClient_User(t *testing.T) {
+	var created map[string]any
+	srv := httptest.NewServer(keycloakHandler(t, map[string]http.HandlerFunc{
+		"GET /admin/realms/appuio/users": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "exact", r.URL.Query().Get("username"))
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"id": "1", "username": "exactly"},
+				{"id": "2", "username": "exact", "email": "exact@example.com", "firstName": "Ex", "lastName": "Act"},
+			})
+		},
+		"POST /admin/realms/appuio/users": func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			w.Header().Set("Location", "http://"+r.Host+"/admin/realms/appuio/users/new-id")
+			w.WriteHeader(http.StatusCreated)
+		},
+		"DELETE /admin/realms/appuio/users/gone": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+	}))
+	defer srv.Close()
+
+	c := idp.NewKeycloakClient(srv.URL, "appuio", "admin", "secret")
+
+	u, err := c.GetUser(context.Background(), "exact")
+	require.NoError(t, err)
+	assert.Equal(t, idp.User{ID: "2", Username: "exact", Email: "exact@example.com", DisplayName: "Ex Act"}, u)
+
+	u, err = c.CreateUser(context.Background(), idp.User{Username: "new", DisplayName: "New User"})
+	require.NoError(t, err)
+	assert.Equal(t, "new-id", u.ID)
+	assert.Equal(t, "new", created["username"])
+	assert.Equal(t, "New", created["firstName"])
+	assert.Equal(t, "User", created["lastName"])
+	assert.Equal(t, true, created["enabled"])
+
+	err = c.DeleteUser(context.Background(), "gone")
+	assert.ErrorIs(t, err, idp.ErrNotFound)
+}
+
+func TestKeycloakClient_Group(t *testing.T) {
+	added := []string{}
+	removed := []string{}
+	srv := httptest.NewServer(keycloakHandler(t, map[string]http.HandlerFunc{
+		"GET /admin/realms/appuio/groups": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]any{{"id": "org-id", "name": "org"}})
+		},
+		"GET /admin/realms/appuio/groups/org-id/children": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]any{})
+		},
+		"POST /admin/realms/appuio/groups/org-id/children": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": "team-id", "name": "team"})
+		},
+		"GET /admin/realms/appuio/groups/team-id/members": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]any{{"id": "u1", "username": "user1"}, {"id": "u2", "username": "user2"}})
+		},
+		"GET /admin/realms/appuio/users": func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]any{{"id": "u3", "username": r.URL.Query().Get("username")}})
+		},
+		"PUT /admin/realms/appuio/users/u3/groups/team-id": func(w http.ResponseWriter, r *http.Request) {
+			added = append(added, "u3")
+			w.WriteHeader(http.StatusNoContent)
+		},
+		"DELETE /admin/realms/appuio/users/u1/groups/team-id": func(w http.ResponseWriter, r *http.Request) {
+			removed = append(removed, "u1")
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}))
+	defer srv.Close()
+
+	c := idp.NewKeycloakClient(srv.URL, "appuio", "admin", "secret")
+
+	org, err := c.CreateGroup(context.Background(), idp.Group{Name: "org"})
+	require.NoError(t, err)
+	assert.Equal(t, "org-id", org.ID)
+
+	team, err := c.CreateGroup(context.Background(), idp.Group{Name: "team", ParentID: org.ID})
+	require.NoError(t, err)
+	assert.Equal(t, "team-id", team.ID)
+
+	require.NoError(t, c.SetGroupMembers(context.Background(), team.ID, []string{"user2", "user3"}))
+	assert.Equal(t, []string{"u3"}, added)
+	assert.Equal(t, []string{"u1"}, removed)
+}
+
+// keycloakHandler returns a handler serving the given routes and a token endpoint.
+func keycloakHandler(t *testing.T, routes map[string]http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/realms/appuio/protocol/openid-connect/token" {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "admin", r.PostForm.Get("username"))
+			assert.Equal(t, "secret", r.PostForm.Get("password"))
+			json.NewEncoder(w).Encode(map[string]any{"access_token": "token", "expires_in": 60})
+			return
+		}
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		h, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		h(w, r)
+	})
+}
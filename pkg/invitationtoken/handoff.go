@@ -0,0 +1,60 @@
+package invitationtoken
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// handoffTokenKey is the key of the token in the data of a hand-off secret.
+const handoffTokenKey = "token"
+
+// Handoff passes the token of an invitation from the API server issuing it to the controller sending the invitation email.
+// The token is kept in a Secret named after the invitation until the email is sent.
+// The namespace must only be accessible to the API server and the controller.
+type Handoff struct {
+	Client    client.Client
+	Namespace string
+}
+
+// Put stores the token of the invitation with the given name, replacing a previously stored token.
+func (h Handoff) Put(ctx context.Context, invitation, token string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      invitation,
+			Namespace: h.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, h.Client, secret, func() error {
+		secret.Data = map[string][]byte{handoffTokenKey: []byte(token)}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to hand off token: %w", err)
+	}
+	return nil
+}
+
+// Get returns the token of the invitation with the given name.
+// Returns an empty token if there is none.
+func (h Handoff) Get(ctx context.Context, invitation string) (string, error) {
+	secret := &corev1.Secret{}
+	if err := h.Client.Get(ctx, client.ObjectKey{Namespace: h.Namespace, Name: invitation}, secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return string(secret.Data[handoffTokenKey]), nil
+}
+
+// Delete removes the token of the invitation with the given name.
+func (h Handoff) Delete(ctx context.Context, invitation string) error {
+	return client.IgnoreNotFound(h.Client.Delete(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      invitation,
+			Namespace: h.Namespace,
+		},
+	}))
+}
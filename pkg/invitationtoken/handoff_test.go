@@ -0,0 +1,33 @@
+package invitationtoken_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+func TestHandoff(t *testing.T) {
+	ctx := context.Background()
+	subject := invitationtoken.Handoff{Client: fake.NewClientBuilder().Build(), Namespace: "tokens"}
+
+	token, err := subject.Get(ctx, "inv")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	require.NoError(t, subject.Put(ctx, "inv", "first"))
+	require.NoError(t, subject.Put(ctx, "inv", "second"))
+	token, err = subject.Get(ctx, "inv")
+	require.NoError(t, err)
+	assert.Equal(t, "second", token, "the latest token must be handed off")
+
+	require.NoError(t, subject.Delete(ctx, "inv"))
+	require.NoError(t, subject.Delete(ctx, "inv"), "deleting a missing hand-off must succeed")
+	token, err = subject.Get(ctx, "inv")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
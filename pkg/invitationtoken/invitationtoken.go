@@ -0,0 +1,70 @@
+// invitationtoken generates invitation tokens and verifies them against their salted hashes.
+// Only the hash of a token is persisted, the token itself is only known to the issuer.
+package invitationtoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// tokenSize is the number of random bytes of a token.
+	tokenSize = 32
+	// saltSize is the number of random bytes of the salt of a hash.
+	saltSize = 16
+
+	hashAlgorithm = "sha256"
+)
+
+// Generate returns a new random token generated by a cryptographically secure random number generator.
+// The token is URL safe.
+func Generate() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns a salted hash of the token in the format `sha256:<base64 salt>:<base64 hash>`.
+func Hash(token string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return strings.Join([]string{
+		hashAlgorithm,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(sum(salt, token)),
+	}, ":"), nil
+}
+
+// Verify returns true if the token matches the hash returned by Hash.
+// The hashes are compared in constant time.
+func Verify(hash, token string) bool {
+	parts := strings.Split(hash, ":")
+	if len(parts) != 3 || parts[0] != hashAlgorithm {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(expected, sum(salt, token)) == 1
+}
+
+func sum(salt []byte, token string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(token))
+	return h.Sum(nil)
+}
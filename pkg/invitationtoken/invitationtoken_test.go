@@ -0,0 +1,32 @@
+package invitationtoken_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/appuio/control-api/pkg/invitationtoken"
+)
+
+func TestHashVerify(t *testing.T) {
+	token, err := invitationtoken.Generate()
+	require.NoError(t, err)
+	other, err := invitationtoken.Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, token, other)
+
+	h1, err := invitationtoken.Hash(token)
+	require.NoError(t, err)
+	h2, err := invitationtoken.Hash(token)
+	require.NoError(t, err)
+	assert.NotEqual(t, h1, h2, "hashes must be salted")
+	assert.NotContains(t, h1, token)
+
+	assert.True(t, invitationtoken.Verify(h1, token))
+	assert.True(t, invitationtoken.Verify(h2, token))
+	assert.False(t, invitationtoken.Verify(h1, other))
+	assert.False(t, invitationtoken.Verify(h1, ""))
+	assert.False(t, invitationtoken.Verify("", token))
+	assert.False(t, invitationtoken.Verify("md5:abc:def", token))
+}
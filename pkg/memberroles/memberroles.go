@@ -0,0 +1,62 @@
+// memberroles resolves the ClusterRoles bound to the members of an organization.
+// It is shared by the OrganizationMembers controller, which binds the roles, and the webhooks, which predict the bindings.
+package memberroles
+
+import (
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+// roleBindingPrefix is the prefix of the RoleBindings binding the roles to the organization members.
+// It keeps the RoleBindings apart from RoleBindings not managed by the OrganizationMembers controller, such as the organization admin RoleBinding.
+const roleBindingPrefix = "members:"
+
+// Resolver resolves the roles of organization members.
+type Resolver struct {
+	// DefaultRoles are the ClusterRoles bound to members without roles.
+	DefaultRoles []string
+	// AllowedRoles are the ClusterRoles which can be assigned to individual members in addition to the DefaultRoles.
+	AllowedRoles []string
+}
+
+// IsAllowed returns true if the role can be bound to organization members.
+func (r Resolver) IsAllowed(role string) bool {
+	return contains(r.DefaultRoles, role) || contains(r.AllowedRoles, role)
+}
+
+// UsersByRole returns the users bound to every role, and the requested roles which are not allowed.
+// Members without roles get the default roles. The default roles are always returned, even without users.
+func (r Resolver) UsersByRole(refs []controlv1.OrganizationMemberRef) (roleUsers map[string][]string, notAllowed []string) {
+	roleUsers = make(map[string][]string, len(r.DefaultRoles))
+	for _, role := range r.DefaultRoles {
+		roleUsers[role] = []string{}
+	}
+	notAllowed = []string{}
+	for _, mr := range refs {
+		for _, role := range mr.RolesOrDefault(r.DefaultRoles) {
+			if !r.IsAllowed(role) {
+				if !contains(notAllowed, role) {
+					notAllowed = append(notAllowed, role)
+				}
+				continue
+			}
+			if !contains(roleUsers[role], mr.Name) {
+				roleUsers[role] = append(roleUsers[role], mr.Name)
+			}
+		}
+	}
+	return roleUsers, notAllowed
+}
+
+// RoleBindingName returns the name of the RoleBinding binding the role to the organization members.
+func RoleBindingName(role string) string {
+	return roleBindingPrefix + role
+}
+
+func contains(s []string, e string) bool {
+	for _, v := range s {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package memberroles_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+)
+
+func TestResolver_UsersByRole(t *testing.T) {
+	r := memberroles.Resolver{
+		DefaultRoles: []string{"member", "viewer"},
+		AllowedRoles: []string{"admin"},
+	}
+
+	roleUsers, notAllowed := r.UsersByRole([]controlv1.OrganizationMemberRef{
+		{Name: "u1"},
+		{Name: "u2", Roles: []string{"admin", "viewer"}},
+		{Name: "u3", Roles: []string{"cluster-admin", "admin", "admin"}},
+		{Name: "u1"},
+	})
+
+	assert.Equal(t, map[string][]string{
+		"member": {"u1"},
+		"viewer": {"u1", "u2"},
+		"admin":  {"u2", "u3"},
+	}, roleUsers)
+	assert.Equal(t, []string{"cluster-admin"}, notAllowed)
+}
+
+func TestResolver_UsersByRole_DefaultRolesWithoutUsers(t *testing.T) {
+	r := memberroles.Resolver{DefaultRoles: []string{"member"}}
+
+	roleUsers, notAllowed := r.UsersByRole(nil)
+
+	assert.Equal(t, map[string][]string{"member": {}}, roleUsers)
+	assert.Empty(t, notAllowed)
+}
+
+func TestResolver_IsAllowed(t *testing.T) {
+	r := memberroles.Resolver{
+		DefaultRoles: []string{"member"},
+		AllowedRoles: []string{"admin"},
+	}
+
+	assert.True(t, r.IsAllowed("member"))
+	assert.True(t, r.IsAllowed("admin"))
+	assert.False(t, r.IsAllowed("cluster-admin"))
+}
+
+func TestRoleBindingName(t *testing.T) {
+	assert.Equal(t, "members:admin", memberroles.RoleBindingName("admin"))
+}
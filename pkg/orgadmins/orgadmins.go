@@ -0,0 +1,87 @@
+// Package orgadmins computes the admins of an organization.
+// It is shared by the organization admin webhook and the `organizations/leave` subresource, so both agree on who is an admin.
+package orgadmins
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+)
+
+// AdminRolesOrDefault returns the admin roles, or the role `<organizationRolePrefix>admin` if no admin roles are configured.
+func AdminRolesOrDefault(adminRoles []string, organizationRolePrefix string) []string {
+	if len(adminRoles) > 0 {
+		return adminRoles
+	}
+	return []string{organizationRolePrefix + "admin"}
+}
+
+// Resolver resolves the admins of an organization from the RoleBindings in the organization namespace.
+type Resolver struct {
+	// AdminRoles are the ClusterRoles granting admin permissions on an organization.
+	AdminRoles []string
+	// UserPrefix is the prefix applied to the organization members in the RoleBindings managed by the OrganizationMembers.
+	UserPrefix string
+	// MemberRoles resolves the roles the OrganizationMembers controller binds to the organization members.
+	MemberRoles memberroles.Resolver
+}
+
+// Subjects returns the admin subjects of the given RoleBindings.
+// replace returns the subjects of a RoleBinding after a change. If replace is nil, the current subjects are used.
+func (r Resolver) Subjects(rbs []rbacv1.RoleBinding, replace func(rb rbacv1.RoleBinding) []rbacv1.Subject) []rbacv1.Subject {
+	subjects := []rbacv1.Subject{}
+	for _, rb := range rbs {
+		if !r.IsAdminRoleBinding(rb) {
+			continue
+		}
+		if replace == nil {
+			subjects = append(subjects, rb.Subjects...)
+			continue
+		}
+		subjects = append(subjects, replace(rb)...)
+	}
+	return subjects
+}
+
+// IsAdminRoleBinding returns true if the RoleBinding binds one of the admin roles.
+func (r Resolver) IsAdminRoleBinding(rb rbacv1.RoleBinding) bool {
+	if rb.RoleRef.APIGroup != rbacv1.GroupName || rb.RoleRef.Kind != "ClusterRole" {
+		return false
+	}
+	for _, role := range r.AdminRoles {
+		if rb.RoleRef.Name == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceMembers returns the subjects of a RoleBinding after the OrganizationMembers with the given name change to memb.
+// RoleBindings controlled by the OrganizationMembers are bound to the users with the role by the OrganizationMembers controller.
+// Controlled RoleBindings not named after a role are stale and removed by the controller.
+// Other RoleBindings are not changed.
+func (r Resolver) ReplaceMembers(name string, memb *controlv1.OrganizationMembers) func(rb rbacv1.RoleBinding) []rbacv1.Subject {
+	roleUsers, _ := r.MemberRoles.UsersByRole(memb.Spec.UserRefs)
+
+	return func(rb rbacv1.RoleBinding) []rbacv1.Subject {
+		owner := metav1.GetControllerOf(&rb)
+		if owner == nil || owner.Kind != "OrganizationMembers" || owner.APIVersion != controlv1.GroupVersion.String() || owner.Name != name {
+			return rb.Subjects
+		}
+		if rb.Name != memberroles.RoleBindingName(rb.RoleRef.Name) {
+			return nil
+		}
+		users := roleUsers[rb.RoleRef.Name]
+		sub := make([]rbacv1.Subject, len(users))
+		for i, u := range users {
+			sub[i] = rbacv1.Subject{
+				APIGroup: rbacv1.GroupName,
+				Kind:     rbacv1.UserKind,
+				Name:     r.UserPrefix + u,
+			}
+		}
+		return sub
+	}
+}
@@ -0,0 +1,79 @@
+package orgadmins_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/orgadmins"
+)
+
+func TestAdminRolesOrDefault(t *testing.T) {
+	assert.Equal(t, []string{"p:admin"}, orgadmins.AdminRolesOrDefault(nil, "p:"))
+	assert.Equal(t, []string{"owner"}, orgadmins.AdminRolesOrDefault([]string{"owner"}, "p:"))
+}
+
+func TestResolver_Subjects(t *testing.T) {
+	r := orgadmins.Resolver{
+		AdminRoles: []string{"admin"},
+		UserPrefix: "appuio#",
+		MemberRoles: memberroles.Resolver{
+			DefaultRoles: []string{"member"},
+			AllowedRoles: []string{"admin"},
+		},
+	}
+	rbs := []rbacv1.RoleBinding{
+		roleBinding("admin", "admin", false, "appuio#creator"),
+		roleBinding(memberroles.RoleBindingName("admin"), "admin", true, "appuio#u1"),
+		roleBinding("admin-stale", "admin", true, "appuio#u2"),
+		roleBinding(memberroles.RoleBindingName("member"), "member", true, "appuio#u3"),
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "namespaced"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "admin"},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#u4"}},
+		},
+	}
+
+	assert.Equal(t, []string{"appuio#creator", "appuio#u1", "appuio#u2"}, names(r.Subjects(rbs, nil)))
+
+	changed := &controlv1.OrganizationMembers{Spec: controlv1.OrganizationMembersSpec{UserRefs: []controlv1.OrganizationMemberRef{
+		{Name: "u1"},
+		{Name: "u5", Roles: []string{"admin"}},
+	}}}
+	assert.Equal(t, []string{"appuio#creator", "appuio#u5"}, names(r.Subjects(rbs, r.ReplaceMembers("members", changed))))
+
+	r.MemberRoles.AllowedRoles = nil
+	assert.Equal(t, []string{"appuio#creator"}, names(r.Subjects(rbs, r.ReplaceMembers("members", changed))), "roles which are not allowed must not count")
+}
+
+func roleBinding(name, role string, ownedByMembers bool, users ...string) rbacv1.RoleBinding {
+	rb := rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+	}
+	if ownedByMembers {
+		rb.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: controlv1.GroupVersion.String(),
+			Kind:       "OrganizationMembers",
+			Name:       "members",
+			Controller: pointer.Bool(true),
+		}}
+	}
+	for _, u := range users {
+		rb.Subjects = append(rb.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: u})
+	}
+	return rb
+}
+
+func names(subjects []rbacv1.Subject) []string {
+	n := make([]string, len(subjects))
+	for i, s := range subjects {
+		n[i] = s.Name
+	}
+	return n
+}
@@ -2,11 +2,14 @@ package webhooks
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"go.uber.org/multierr"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -14,6 +17,7 @@ import (
 
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	"github.com/appuio/control-api/controllers/targetref"
+	"github.com/appuio/control-api/pkg/memberroles"
 	"github.com/appuio/control-api/pkg/sar"
 )
 
@@ -27,6 +31,19 @@ type InvitationValidator struct {
 	decoder *admission.Decoder
 
 	UsernamePrefix string
+	// OrganizationRolePrefix is the prefix of the ClusterRoles granting the roles of role-scoped targets
+	OrganizationRolePrefix string
+	// MemberRoles are the ClusterRoles bound to organization members without roles.
+	MemberRoles []string
+	// AllowedMemberRoles are the ClusterRoles which can be assigned to individual organization members in addition to the MemberRoles.
+	// Role-scoped targets must grant one of the MemberRoles or AllowedMemberRoles.
+	AllowedMemberRoles []string
+	// MaxValidFor is the maximum validity an invitation can request.
+	// Zero allows any validity.
+	MaxValidFor time.Duration
+	// TargetKinds holds the generic kinds supported as targets in addition to the built-in kinds.
+	// Only built-in kinds are supported if nil.
+	TargetKinds *targetref.Registry
 }
 
 // Handle handles the users.appuio.io admission requests
@@ -39,9 +56,20 @@ func (v *InvitationValidator) Handle(ctx context.Context, req admission.Request)
 	}
 	log.V(1).WithValues("invitation", inv).Info("Validating")
 
+	var old *userv1.Invitation
+	if len(req.OldObject.Raw) > 0 {
+		old = &userv1.Invitation{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if err := validateValidity(inv, old, time.Now(), v.MaxValidFor); err != nil {
+		return admission.Denied(fmt.Sprintf("invalid validity: %s", err))
+	}
+
 	authErrors := make([]error, 0, len(inv.Spec.TargetRefs))
 	for _, target := range inv.Spec.TargetRefs {
-		authErrors = append(authErrors, authorizeTarget(ctx, v.client, req.UserInfo, target))
+		authErrors = append(authErrors, authorizeTarget(ctx, v.client, v.TargetKinds, req.UserInfo, target, v.OrganizationRolePrefix, memberroles.Resolver{DefaultRoles: v.MemberRoles, AllowedRoles: v.AllowedMemberRoles}))
 	}
 	if err := multierr.Combine(authErrors...); err != nil {
 		return admission.Denied(fmt.Sprintf("user %q is not allowed to invite to the targets: %s", req.UserInfo.Username, err))
@@ -50,6 +78,40 @@ func (v *InvitationValidator) Handle(ctx context.Context, req admission.Request)
 	return admission.Allowed("target refs are valid")
 }
 
+// validateValidity validates the requested validity of the invitation against the maximum validity.
+// The validity of an updated invitation is only validated if it changed, as a requested expiry passes over time.
+// The validity is applied when the token is issued, so it can't be changed afterwards. Resending the invitation extends it instead.
+func validateValidity(inv, old *userv1.Invitation, now time.Time, max time.Duration) error {
+	spec := inv.Spec
+	if old != nil && apiequality.Semantic.DeepEqual(spec.ValidFor, old.Spec.ValidFor) && apiequality.Semantic.DeepEqual(spec.ValidUntil, old.Spec.ValidUntil) {
+		return nil
+	}
+	if old != nil && old.HasToken() {
+		return errors.New("validFor and validUntil can't be changed after the token was issued, resend the invitation to extend it")
+	}
+
+	if spec.ValidFor != nil && spec.ValidUntil != nil {
+		return errors.New("validFor and validUntil are mutually exclusive")
+	}
+	if spec.ValidFor != nil {
+		if spec.ValidFor.Duration <= 0 {
+			return errors.New("validFor must be positive")
+		}
+		if max > 0 && spec.ValidFor.Duration > max {
+			return fmt.Errorf("validFor must not exceed %s", max)
+		}
+	}
+	if spec.ValidUntil != nil {
+		if !spec.ValidUntil.After(now) {
+			return errors.New("validUntil must be in the future")
+		}
+		if max > 0 && spec.ValidUntil.After(now.Add(max)) {
+			return fmt.Errorf("validUntil must not be more than %s in the future", max)
+		}
+	}
+	return nil
+}
+
 // InjectDecoder injects a Admission request decoder into the InvitationValidator
 func (v *InvitationValidator) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
@@ -62,14 +124,40 @@ func (v *InvitationValidator) InjectClient(c client.Client) error {
 	return nil
 }
 
-func authorizeTarget(ctx context.Context, c client.Client, user authenticationv1.UserInfo, target userv1.TargetRef) error {
+func authorizeTarget(ctx context.Context, c client.Client, kinds *targetref.Registry, user authenticationv1.UserInfo, target userv1.TargetRef, rolePrefix string, memberRoles memberroles.Resolver) error {
 	// Check if the target references a supported resource
-	_, err := targetref.NewObjectFromRef(target)
+	_, err := kinds.NewObjectFromRef(target)
 	if err != nil {
 		return err
 	}
+	if err := targetref.ValidateRole(target); err != nil {
+		return err
+	}
+
+	if targetref.IsBillingEntity(target) {
+		// Only billing admins may invite, regardless of the invited role.
+		// Billing admins are the users allowed to edit the ClusterRoleBinding granting the admin role.
+		admin := target
+		admin.Role = targetref.BillingEntityRoleAdmin
+		ref, err := targetref.ResolveRef(admin)
+		if err != nil {
+			return err
+		}
+		return canEditTarget(ctx, c, user, ref)
+	}
 
-	return canEditTarget(ctx, c, user, target)
+	if err := canEditTarget(ctx, c, user, target); err != nil {
+		return err
+	}
+	if !targetref.HasOrganizationRole(target) {
+		return nil
+	}
+	// The role is bound by the OrganizationMembers controller, which only binds the member roles
+	if role := targetref.OrganizationRole(target, rolePrefix); !memberRoles.IsAllowed(role) {
+		return fmt.Errorf("role %q can't be assigned to organization members", target.Role)
+	}
+	// The user must be allowed to delegate the role by editing the RoleBinding binding it to the organization members
+	return canEditTarget(ctx, c, user, targetref.RoleBindingRef(target, rolePrefix))
 }
 
 // canEditTarget checks if the user is allowed to edit the target.
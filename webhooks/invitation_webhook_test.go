@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +24,7 @@ import (
 	orgv1 "github.com/appuio/control-api/apis/organization/v1"
 	userv1 "github.com/appuio/control-api/apis/user/v1"
 	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/controllers/targetref"
 	"github.com/appuio/control-api/pkg/sar"
 )
 
@@ -169,6 +171,178 @@ func TestInvitationValidator_Handle(t *testing.T) {
 			errcode: http.StatusOK,
 		},
 
+		"OrganizationMembers with role allowed": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "appuio.io",
+					Kind:      "OrganizationMembers",
+					Namespace: testOrg,
+					Name:      "members",
+					Role:      "viewer",
+				},
+			},
+			allowed: true,
+			errcode: http.StatusOK,
+		},
+
+		"OrganizationMembers with role denied": {
+			requestUser: deniedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "appuio.io",
+					Kind:      "OrganizationMembers",
+					Namespace: testOrg,
+					Name:      "members",
+					Role:      "viewer",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"OrganizationMembers with invalid role denied": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "appuio.io",
+					Kind:      "OrganizationMembers",
+					Namespace: testOrg,
+					Name:      "members",
+					Role:      "../admin",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"OrganizationMembers with role not allowed for members denied": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "appuio.io",
+					Kind:      "OrganizationMembers",
+					Namespace: testOrg,
+					Name:      "members",
+					Role:      "owner",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"Team with role denied": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "appuio.io",
+					Kind:      "Team",
+					Namespace: testOrg,
+					Name:      testTeam,
+					Role:      "viewer",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"BillingEntity admin allowed": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup: "billing.appuio.io",
+					Kind:     "BillingEntity",
+					Name:     "be-1234",
+					Role:     "admin",
+				},
+			},
+			allowed: true,
+			errcode: http.StatusOK,
+		},
+
+		"BillingEntity viewer allowed": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup: "billing.appuio.io",
+					Kind:     "BillingEntity",
+					Name:     "be-1234",
+					Role:     "viewer",
+				},
+			},
+			allowed: true,
+			errcode: http.StatusOK,
+		},
+
+		"BillingEntity viewer denied": {
+			requestUser: deniedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup: "billing.appuio.io",
+					Kind:     "BillingEntity",
+					Name:     "be-1234",
+					Role:     "viewer",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"BillingEntity without role denied": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup: "billing.appuio.io",
+					Kind:     "BillingEntity",
+					Name:     "be-1234",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"BillingEntity with invalid role denied": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup: "billing.appuio.io",
+					Kind:     "BillingEntity",
+					Name:     "be-1234",
+					Role:     "owner",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
+		"generic target allowed": {
+			requestUser: allowedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "projects.example.com",
+					Kind:      "Project",
+					Namespace: testOrg,
+					Name:      "project",
+				},
+			},
+			allowed: true,
+			errcode: http.StatusOK,
+		},
+
+		"generic target denied": {
+			requestUser: deniedUser,
+			targets: []userv1.TargetRef{
+				{
+					APIGroup:  "projects.example.com",
+					Kind:      "Project",
+					Namespace: testOrg,
+					Name:      "project",
+				},
+			},
+			allowed: false,
+			errcode: http.StatusForbidden,
+		},
+
 		"RoleBinding denied": {
 			requestUser: deniedUser,
 			targets: []userv1.TargetRef{
@@ -201,7 +375,7 @@ func TestInvitationValidator_Handle(t *testing.T) {
 					Namespace: testOrg,
 				},
 				Spec: controlv1.OrganizationMembersSpec{
-					UserRefs: []controlv1.UserRef{{Name: allowedUser}},
+					UserRefs: []controlv1.OrganizationMemberRef{{Name: allowedUser}},
 				},
 			}
 			team := controlv1.Team{
@@ -240,6 +414,16 @@ func TestInvitationValidator_Handle(t *testing.T) {
 			}
 
 			iv := prepareInvitationValidatorTest(t, allowedUser, &invitation, &org, &team, &rb, &crb)
+			kinds, err := targetref.NewRegistry(targetref.GenericKind{
+				Group:     "projects.example.com",
+				Version:   "v1",
+				Kind:      "Project",
+				UsersPath: "spec.members",
+			})
+			require.NoError(t, err)
+			iv.TargetKinds = kinds
+			iv.OrganizationRolePrefix = "control-api:organization-"
+			iv.AllowedMemberRoles = []string{"control-api:organization-viewer"}
 
 			invJson, err := json.Marshal(invitation)
 			require.NoError(t, err)
@@ -282,6 +466,148 @@ func TestInvitationValidator_Handle(t *testing.T) {
 	}
 }
 
+func TestInvitationValidator_Handle_Validity(t *testing.T) {
+	validInvitation := func(spec userv1.InvitationSpec) runtime.RawExtension {
+		raw, err := json.Marshal(userv1.Invitation{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-invitation"},
+			Spec:       spec,
+		})
+		require.NoError(t, err)
+		return runtime.RawExtension{Raw: raw}
+	}
+	pastValidUntil := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	tests := map[string]struct {
+		spec    userv1.InvitationSpec
+		oldSpec *userv1.InvitationSpec
+		allowed bool
+	}{
+		"within maximum": {
+			spec:    userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: time.Hour}},
+			allowed: true,
+		},
+		"exceeding maximum": {
+			spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: 48 * time.Hour}},
+		},
+		"past validUntil": {
+			spec: userv1.InvitationSpec{ValidUntil: &pastValidUntil},
+		},
+		"unchanged past validUntil on update": {
+			spec:    userv1.InvitationSpec{ValidUntil: &pastValidUntil, Note: "updated"},
+			oldSpec: &userv1.InvitationSpec{ValidUntil: &pastValidUntil},
+			allowed: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			iv := prepareInvitationValidatorTest(t, "user")
+			iv.MaxValidFor = 24 * time.Hour
+
+			req := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Operation: admissionv1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: "user"},
+					Object:    validInvitation(tc.spec),
+				},
+			}
+			if tc.oldSpec != nil {
+				req.Operation = admissionv1.Update
+				req.OldObject = validInvitation(*tc.oldSpec)
+			}
+
+			resp := iv.Handle(context.Background(), req)
+			assert.Equal(t, tc.allowed, resp.Allowed, resp.Result.Message)
+		})
+	}
+}
+
+func Test_validateValidity(t *testing.T) {
+	now := time.Now()
+	validUntil := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+
+	tests := map[string]struct {
+		spec userv1.InvitationSpec
+		err  string
+	}{
+		"no validity requested": {},
+		"validFor":              {spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: time.Hour}}},
+		"validUntil":            {spec: userv1.InvitationSpec{ValidUntil: validUntil(time.Hour)}},
+		"both": {
+			spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: time.Hour}, ValidUntil: validUntil(time.Hour)},
+			err:  "mutually exclusive",
+		},
+		"negative validFor": {
+			spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: -time.Hour}},
+			err:  "must be positive",
+		},
+		"validFor exceeding maximum": {
+			spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: 25 * time.Hour}},
+			err:  "must not exceed 24h0m0s",
+		},
+		"validUntil in the past": {
+			spec: userv1.InvitationSpec{ValidUntil: validUntil(-time.Hour)},
+			err:  "must be in the future",
+		},
+		"validUntil exceeding maximum": {
+			spec: userv1.InvitationSpec{ValidUntil: validUntil(25 * time.Hour)},
+			err:  "must not be more than 24h0m0s in the future",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateValidity(&userv1.Invitation{Spec: tc.spec}, nil, now, 24*time.Hour)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func Test_validateValidity_Update(t *testing.T) {
+	now := time.Now()
+	validFor := &metav1.Duration{Duration: time.Hour}
+	issued := userv1.InvitationStatus{TokenHash: "hash"}
+
+	tests := map[string]struct {
+		spec userv1.InvitationSpec
+		old  userv1.Invitation
+		err  string
+	}{
+		"changed before token was issued": {
+			spec: userv1.InvitationSpec{ValidFor: validFor},
+		},
+		"unchanged after token was issued": {
+			spec: userv1.InvitationSpec{ValidFor: validFor, Note: "updated"},
+			old:  userv1.Invitation{Spec: userv1.InvitationSpec{ValidFor: validFor}, Status: issued},
+		},
+		"validFor changed after token was issued": {
+			spec: userv1.InvitationSpec{ValidFor: &metav1.Duration{Duration: 2 * time.Hour}},
+			old:  userv1.Invitation{Spec: userv1.InvitationSpec{ValidFor: validFor}, Status: issued},
+			err:  "can't be changed after the token was issued",
+		},
+		"validUntil set after token was issued": {
+			spec: userv1.InvitationSpec{ValidUntil: &metav1.Time{Time: now.Add(time.Hour)}},
+			old:  userv1.Invitation{Status: issued},
+			err:  "can't be changed after the token was issued",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateValidity(&userv1.Invitation{Spec: tc.spec}, &tc.old, now, 24*time.Hour)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
 func prepareInvitationValidatorTest(t *testing.T, sarAllowedUser string, initObjs ...client.Object) *InvitationValidator {
 	scheme := runtime.NewScheme()
 	require.NoError(t, clientgoscheme.AddToScheme(scheme))
@@ -297,6 +623,7 @@ func prepareInvitationValidatorTest(t *testing.T, sarAllowedUser string, initObj
 		[]schema.GroupVersion{
 			{Group: "appuio.io", Version: "v1"},
 			{Group: "rbac.authorization.k8s.io", Version: "v1"},
+			{Group: "projects.example.com", Version: "v1"},
 		},
 	)
 	drm.AddSpecific(schema.GroupVersionKind{
@@ -328,6 +655,11 @@ func prepareInvitationValidatorTest(t *testing.T, sarAllowedUser string, initObj
 		Version: "v1",
 		Kind:    "RoleBinding",
 	}, meta.RESTScopeNamespace)
+	drm.Add(schema.GroupVersionKind{
+		Group:   "projects.example.com",
+		Version: "v1",
+		Kind:    "Project",
+	}, meta.RESTScopeNamespace)
 
 	var client client.WithWatch
 
@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+// +kubebuilder:webhook:path=/validate-namespace-quota,mutating=false,failurePolicy=fail,groups="",resources=namespaces,verbs=create,versions=v1,name=validate-namespace-quota.appuio.io,admissionReviewVersions=v1,sideEffects=None
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups="organization.appuio.io",resources=organizations,verbs=get
+// +kubebuilder:rbac:groups="rbac.appuio.io",resources=organizations,verbs=get
+// +kubebuilder:rbac:groups=appuio.io,resources=usageprofiles,verbs=get
+
+// NamespaceQuotaValidator holds context for the validating admission webhook enforcing the namespace count of usage profiles
+type NamespaceQuotaValidator struct {
+	client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle handles the namespace admission requests
+func (v *NamespaceQuotaValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx).WithName("webhook.validate-namespace-quota.appuio.io")
+
+	ns := &corev1.Namespace{}
+	if err := v.decoder.Decode(req, ns); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	orgName := ns.Labels[controlv1.OrganizationLabel]
+	if orgName == "" {
+		return admission.Allowed("namespace does not belong to an organization")
+	}
+
+	org := &orgv1.Organization{}
+	if err := v.client.Get(ctx, client.ObjectKey{Name: orgName}, org); err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Allowed("organization not found")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	profileName := org.Annotations[controlv1.UsageProfileAnnotation]
+	if profileName == "" {
+		return admission.Allowed("organization has no usage profile")
+	}
+
+	profile := &controlv1.UsageProfile{}
+	if err := v.client.Get(ctx, client.ObjectKey{Name: profileName}, profile); err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("failed to get usage profile %q: %w", profileName, err))
+	}
+	if profile.Spec.NamespaceCount <= 0 {
+		return admission.Allowed("usage profile does not limit namespaces")
+	}
+
+	nsl := &corev1.NamespaceList{}
+	if err := v.client.List(ctx, nsl, client.MatchingLabels{controlv1.OrganizationLabel: orgName}); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	log.V(1).WithValues("organization", orgName, "profile", profileName, "count", len(nsl.Items), "limit", profile.Spec.NamespaceCount).Info("Validating namespace count")
+
+	if len(nsl.Items) >= profile.Spec.NamespaceCount {
+		return admission.Denied(fmt.Sprintf("organization %q has reached the maximum of %d namespaces allowed by usage profile %q", orgName, profile.Spec.NamespaceCount, profileName))
+	}
+
+	return admission.Allowed("namespace count within usage profile limit")
+}
+
+// InjectDecoder injects a Admission request decoder into the NamespaceQuotaValidator
+func (v *NamespaceQuotaValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// InjectClient injects a Kubernetes client into the NamespaceQuotaValidator
+func (v *NamespaceQuotaValidator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
@@ -0,0 +1,147 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+)
+
+func TestNamespaceQuotaValidator_Handle(t *testing.T) {
+	tests := map[string]struct {
+		org            string
+		existing       int
+		namespaceCount int
+		withoutProfile bool
+
+		allowed bool
+	}{
+		"namespace without organization is allowed": {
+			existing: 5, namespaceCount: 1,
+			allowed: true,
+		},
+		"namespace below limit is allowed": {
+			org:      "foo",
+			existing: 1, namespaceCount: 2,
+			allowed: true,
+		},
+		"namespace at limit is denied": {
+			org:      "foo",
+			existing: 2, namespaceCount: 2,
+			allowed: false,
+		},
+		"zero namespace count is unlimited": {
+			org:      "foo",
+			existing: 20, namespaceCount: 0,
+			allowed: true,
+		},
+		"organization without usage profile is allowed": {
+			org:      "foo",
+			existing: 5, namespaceCount: 1,
+			withoutProfile: true,
+			allowed:        true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			org := orgv1.Organization{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "foo",
+					Annotations: map[string]string{},
+				},
+			}
+			if !tc.withoutProfile {
+				org.Annotations[controlv1.UsageProfileAnnotation] = "default"
+			}
+			profile := controlv1.UsageProfile{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "default",
+				},
+				Spec: controlv1.UsageProfileSpec{
+					NamespaceCount: tc.namespaceCount,
+				},
+			}
+			objs := []client.Object{&org, &profile}
+			for i := 0; i < tc.existing; i++ {
+				objs = append(objs, &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:   fmt.Sprintf("foo-%d", i),
+						Labels: map[string]string{controlv1.OrganizationLabel: "foo"},
+					},
+				})
+			}
+
+			v := prepareNamespaceQuotaValidatorTest(t, objs...)
+
+			ns := corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "new-namespace",
+				},
+			}
+			if tc.org != "" {
+				ns.Labels = map[string]string{controlv1.OrganizationLabel: tc.org}
+			}
+			nsJson, err := json.Marshal(ns)
+			require.NoError(t, err)
+
+			resp := v.Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					UID: "e515f52d-7181-494d-a3d3-f0738856bd97",
+					Kind: metav1.GroupVersionKind{
+						Version: "v1",
+						Kind:    "Namespace",
+					},
+					Resource: metav1.GroupVersionResource{
+						Version:  "v1",
+						Resource: "namespaces",
+					},
+					Name:      ns.Name,
+					Operation: admissionv1.Create,
+					Object:    runtime.RawExtension{Raw: nsJson},
+				},
+			})
+
+			assert.Equal(t, tc.allowed, resp.Allowed)
+			if !tc.allowed {
+				assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+			}
+		})
+	}
+}
+
+func prepareNamespaceQuotaValidatorTest(t *testing.T, initObjs ...client.Object) *NamespaceQuotaValidator {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, orgv1.AddToScheme(scheme))
+	require.NoError(t, controlv1.AddToScheme(scheme))
+
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(initObjs...).
+		Build()
+
+	v := &NamespaceQuotaValidator{}
+	v.InjectClient(c)
+	v.InjectDecoder(decoder)
+
+	return v
+}
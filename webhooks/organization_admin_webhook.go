@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/exp/slices"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/orgadmins"
+	"github.com/appuio/control-api/pkg/sar"
+)
+
+// +kubebuilder:webhook:path=/validate-appuio-io-v1-organizationmembers-admins,mutating=false,failurePolicy=fail,groups="appuio.io",resources=organizationmembers,verbs=create;update;delete,versions=v1,name=validate-organizationmembers-admins.appuio.io,admissionReviewVersions=v1,sideEffects=None
+// +kubebuilder:webhook:path=/validate-rbac-v1-rolebinding-organization-admins,mutating=false,failurePolicy=fail,groups="rbac.authorization.k8s.io",resources=rolebindings,verbs=update;delete,versions=v1,name=validate-rolebindings-organization-admins.appuio.io,admissionReviewVersions=v1,sideEffects=None
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
+
+// BreakGlassVerb is the verb on `rbac.appuio.io` organizations allowing platform operators to remove the last admin of an organization.
+// The permission is checked with a SubjectAccessReview with the name of the organization.
+// The ClusterRole `control-api:organization-break-glass` in config/user-rbac grants it for all organizations.
+const BreakGlassVerb = "remove-last-admin"
+
+// OrganizationAdminValidator holds context for the validating admission webhook protecting the last admin of an organization.
+// It rejects updates and deletions of OrganizationMembers and RoleBindings in organization namespaces which would leave the organization
+// without any subject bound to one of the admin ClusterRoles.
+// It also rejects OrganizationMembers assigning roles to members which aren't allowed.
+// The webhook configuration only sends RoleBindings in organization namespaces, see the namespaceSelector patch in config/webhook.
+// Users allowed to `remove-last-admin` on `rbac.appuio.io` organizations can override this.
+type OrganizationAdminValidator struct {
+	client  client.Client
+	decoder *admission.Decoder
+
+	// AdminRoles are the ClusterRoles granting admin permissions on an organization.
+	AdminRoles []string
+	// UserPrefix is the prefix applied to the organization members in the RoleBindings managed by the OrganizationMembers.
+	UserPrefix string
+	// MemberRoles are the ClusterRoles bound to organization members without roles.
+	MemberRoles []string
+	// AllowedMemberRoles are the ClusterRoles which can be assigned to individual organization members in addition to the MemberRoles.
+	AllowedMemberRoles []string
+}
+
+// Handle handles the organizationmembers.appuio.io and rolebindings.rbac.authorization.k8s.io admission requests
+func (v *OrganizationAdminValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	log := log.FromContext(ctx).WithName("webhook.validate-organization-admins.appuio.io")
+
+	if req.Kind.Kind == "OrganizationMembers" && req.Operation != admissionv1.Delete {
+		notAllowed, err := v.notAllowedMemberRoles(req)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if len(notAllowed) > 0 {
+			return admission.Denied(fmt.Sprintf("roles %q can't be assigned to organization members, allowed roles are %q", notAllowed, v.allowedMemberRoles()))
+		}
+	}
+
+	if req.Operation != admissionv1.Update && req.Operation != admissionv1.Delete {
+		return admission.Allowed("operation can't remove admins")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := v.client.Get(ctx, client.ObjectKey{Name: req.Namespace}, ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return admission.Allowed("namespace not found")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if orgv1.NewOrganizationFromNS(ns) == nil {
+		return admission.Allowed("namespace is not an organization")
+	}
+	if !ns.DeletionTimestamp.IsZero() {
+		return admission.Allowed("organization is being deleted")
+	}
+
+	var replace func(rb rbacv1.RoleBinding) []rbacv1.Subject
+	switch req.Kind.Kind {
+	case "OrganizationMembers":
+		memb := &controlv1.OrganizationMembers{}
+		if err := v.decodeChanged(req, memb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		replace = v.admins().ReplaceMembers(req.Name, memb)
+	case "RoleBinding":
+		rb := &rbacv1.RoleBinding{}
+		if err := v.decodeChanged(req, rb); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		replace = replaceRoleBinding(req.Name, rb)
+	default:
+		return admission.Allowed("kind can't remove admins")
+	}
+
+	rbs := &rbacv1.RoleBindingList{}
+	if err := v.client.List(ctx, rbs, client.InNamespace(req.Namespace)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	before, after := len(v.admins().Subjects(rbs.Items, nil)), len(v.admins().Subjects(rbs.Items, replace))
+	log.V(1).WithValues("organization", req.Namespace, "before", before, "after", after).Info("Validating admin count")
+
+	if before == 0 || after > 0 {
+		return admission.Allowed("organization keeps an admin")
+	}
+
+	if err := sar.AuthorizeResource(ctx, v.client, req.UserInfo, sar.ResourceAttributes{
+		Verb:     BreakGlassVerb,
+		Group:    "rbac.appuio.io",
+		Resource: "organizations",
+		Version:  "v1",
+		Name:     req.Namespace,
+	}); err != nil {
+		log.Info("User not authorized to remove the last admin", "request_user", req.UserInfo, "organization", req.Namespace, "error", err)
+		return admission.Denied(fmt.Sprintf("change would remove the last admin of organization %q, add another admin first", req.Namespace))
+	}
+	log.Info("User authorized to remove the last admin", "user", req.UserInfo, "organization", req.Namespace)
+	return admission.Allowed("user is allowed to remove the last admin")
+}
+
+// decodeChanged decodes the object after the change into obj.
+// On deletion obj is left empty.
+func (v *OrganizationAdminValidator) decodeChanged(req admission.Request, obj runtime.Object) error {
+	if req.Operation == admissionv1.Delete {
+		return nil
+	}
+	return v.decoder.Decode(req, obj)
+}
+
+// notAllowedMemberRoles returns the roles assigned to members which aren't allowed.
+// Roles the member already had before an update are ignored, the object can still be changed if a role is no longer allowed.
+func (v *OrganizationAdminValidator) notAllowedMemberRoles(req admission.Request) ([]string, error) {
+	memb := &controlv1.OrganizationMembers{}
+	if err := v.decoder.Decode(req, memb); err != nil {
+		return nil, err
+	}
+	old := &controlv1.OrganizationMembers{}
+	if req.Operation == admissionv1.Update {
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return nil, err
+		}
+	}
+	oldRoles := make(map[string][]string, len(old.Spec.UserRefs))
+	for _, mr := range old.Spec.UserRefs {
+		oldRoles[mr.Name] = append(oldRoles[mr.Name], mr.Roles...)
+	}
+
+	resolver := v.admins().MemberRoles
+	notAllowed := []string{}
+	for _, mr := range memb.Spec.UserRefs {
+		for _, role := range mr.Roles {
+			if resolver.IsAllowed(role) || slices.Contains(oldRoles[mr.Name], role) || slices.Contains(notAllowed, role) {
+				continue
+			}
+			notAllowed = append(notAllowed, role)
+		}
+	}
+	return notAllowed, nil
+}
+
+// allowedMemberRoles returns all roles which can be assigned to organization members.
+func (v *OrganizationAdminValidator) allowedMemberRoles() []string {
+	return append(slices.Clone(v.MemberRoles), v.AllowedMemberRoles...)
+}
+
+// replaceRoleBinding returns the admin subjects of the RoleBinding after the change of the RoleBinding with the given name.
+func replaceRoleBinding(name string, changed *rbacv1.RoleBinding) func(rb rbacv1.RoleBinding) []rbacv1.Subject {
+	return func(rb rbacv1.RoleBinding) []rbacv1.Subject {
+		if rb.Name != name {
+			return rb.Subjects
+		}
+		if changed.RoleRef != rb.RoleRef {
+			return nil
+		}
+		return changed.Subjects
+	}
+}
+
+// admins returns the resolver computing the organization admins, shared with the `organizations/leave` subresource.
+func (v *OrganizationAdminValidator) admins() orgadmins.Resolver {
+	return orgadmins.Resolver{
+		AdminRoles: v.AdminRoles,
+		UserPrefix: v.UserPrefix,
+		MemberRoles: memberroles.Resolver{
+			DefaultRoles: v.MemberRoles,
+			AllowedRoles: v.AllowedMemberRoles,
+		},
+	}
+}
+
+// InjectDecoder injects a Admission request decoder into the OrganizationAdminValidator
+func (v *OrganizationAdminValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// InjectClient injects a Kubernetes client into the OrganizationAdminValidator
+func (v *OrganizationAdminValidator) InjectClient(c client.Client) error {
+	v.client = c
+	return nil
+}
@@ -0,0 +1,332 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	orgv1 "github.com/appuio/control-api/apis/organization/v1"
+	controlv1 "github.com/appuio/control-api/apis/v1"
+	"github.com/appuio/control-api/pkg/memberroles"
+	"github.com/appuio/control-api/pkg/sar"
+)
+
+const testAdminRole = "control-api:organization-admin"
+
+func TestOrganizationAdminValidator_Handle_RoleBinding(t *testing.T) {
+	tests := map[string]struct {
+		namespace   *corev1.Namespace
+		operation   admissionv1.Operation
+		subjects    []string
+		requestUser string
+		allowed     bool
+	}{
+		"removing one of multiple admins allowed": {
+			operation: admissionv1.Update,
+			subjects:  []string{"appuio#bar"},
+			allowed:   true,
+		},
+		"removing all admins denied": {
+			operation: admissionv1.Update,
+			subjects:  []string{},
+			allowed:   false,
+		},
+		"deleting admin RoleBinding denied": {
+			operation: admissionv1.Delete,
+			allowed:   false,
+		},
+		"deleting admin RoleBinding with break-glass permission allowed": {
+			operation:   admissionv1.Delete,
+			requestUser: "platform-operator",
+			allowed:     true,
+		},
+		"deleting admin RoleBinding outside of organization allowed": {
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			operation: admissionv1.Delete,
+			allowed:   true,
+		},
+		"deleting admin RoleBinding in terminating organization allowed": {
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:              "foo",
+				Labels:            map[string]string{orgv1.TypeKey: orgv1.OrgType},
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+				Finalizers:        []string{"kubernetes"},
+			}},
+			operation: admissionv1.Delete,
+			allowed:   true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ns := tc.namespace
+			if ns == nil {
+				ns = testOrgNamespace("foo")
+			}
+			old := testAdminRoleBinding("foo", "appuio#foo", "appuio#bar")
+			v := prepareOrganizationAdminValidatorTest(t, "platform-operator", ns, old.DeepCopy())
+
+			var changed *rbacv1.RoleBinding
+			if tc.operation != admissionv1.Delete {
+				changed = testAdminRoleBinding("foo", tc.subjects...)
+			}
+			resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, tc.operation, tc.requestUser, "RoleBinding", old, changed))
+			assert.Equal(t, tc.allowed, resp.Allowed, resp.Result.Message)
+		})
+	}
+}
+
+func TestOrganizationAdminValidator_Handle_RoleBinding_OtherRoleBindingsKeepAdmin(t *testing.T) {
+	old := testAdminRoleBinding("foo", "appuio#foo")
+	other := testAdminRoleBinding("foo", "appuio#bar")
+	other.Name = "other-admins"
+	viewers := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "viewers", Namespace: "foo"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "appuio#baz"}},
+		RoleRef:    rbacv1.RoleRef{Kind: "ClusterRole", APIGroup: rbacv1.GroupName, Name: "viewer"},
+	}
+
+	v := prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy(), other, viewers)
+	resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Delete, "appuio#foo", "RoleBinding", old, nil))
+	assert.True(t, resp.Allowed, resp.Result.Message)
+
+	v = prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy(), viewers)
+	resp = v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Delete, "appuio#foo", "RoleBinding", old, nil))
+	assert.False(t, resp.Allowed, "viewers are not admins")
+	assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+}
+
+func TestOrganizationAdminValidator_Handle_OrganizationMembers(t *testing.T) {
+	tests := map[string]struct {
+		operation   admissionv1.Operation
+		users       []string
+		requestUser string
+		allowed     bool
+	}{
+		"removing one of multiple members allowed": {
+			operation: admissionv1.Update,
+			users:     []string{"bar"},
+			allowed:   true,
+		},
+		"removing all members denied": {
+			operation: admissionv1.Update,
+			users:     []string{},
+			allowed:   false,
+		},
+		"removing all members with break-glass permission allowed": {
+			operation:   admissionv1.Update,
+			users:       []string{},
+			requestUser: "platform-operator",
+			allowed:     true,
+		},
+		"deleting members denied": {
+			operation: admissionv1.Delete,
+			allowed:   false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			old := testOrganizationMembers("foo", "foo", "bar")
+			rb := testAdminRoleBinding("foo", "appuio#foo", "appuio#bar")
+			rb.Name = memberroles.RoleBindingName(testAdminRole)
+			rb.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: controlv1.GroupVersion.String(),
+				Kind:       "OrganizationMembers",
+				Name:       "members",
+				Controller: pointer.Bool(true),
+			}}
+			v := prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy(), rb)
+
+			var changed *controlv1.OrganizationMembers
+			if tc.operation != admissionv1.Delete {
+				changed = testOrganizationMembers("foo", tc.users...)
+			}
+			resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, tc.operation, tc.requestUser, "OrganizationMembers", old, changed))
+			assert.Equal(t, tc.allowed, resp.Allowed, resp.Result.Message)
+		})
+	}
+}
+
+func TestOrganizationAdminValidator_Handle_OrganizationMembers_NotAdmins(t *testing.T) {
+	old := testOrganizationMembers("foo", "foo", "bar")
+	rb := testAdminRoleBinding("foo", "appuio#foo")
+
+	v := prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy(), rb)
+	resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, testOrganizationMembers("foo")))
+	assert.True(t, resp.Allowed, "admin RoleBinding is not managed by the organization members")
+}
+
+func TestOrganizationAdminValidator_Handle_OrganizationMembers_UserRoles(t *testing.T) {
+	old := testOrganizationMembers("foo", "foo", "bar")
+	old.Spec.UserRefs[0].Roles = []string{testAdminRole}
+	rb := testAdminRoleBinding("foo", "appuio#foo")
+	rb.Name = memberroles.RoleBindingName(testAdminRole)
+	rb.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: controlv1.GroupVersion.String(),
+		Kind:       "OrganizationMembers",
+		Name:       "members",
+		Controller: pointer.Bool(true),
+	}}
+	v := prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy(), rb)
+	v.MemberRoles = []string{"member"}
+	v.AllowedMemberRoles = []string{testAdminRole, "viewer"}
+
+	changed := old.DeepCopy()
+	changed.Spec.UserRefs[0].Roles = []string{"member"}
+	changed.Spec.UserRefs[1].Roles = []string{"viewer"}
+	resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, changed))
+	assert.False(t, resp.Allowed, "removing the admin role from the last admin must be denied")
+
+	changed.Spec.UserRefs[1].Roles = []string{testAdminRole}
+	resp = v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, changed))
+	assert.True(t, resp.Allowed, resp.Result.Message)
+
+	v.AllowedMemberRoles = []string{"viewer"}
+	resp = v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, changed))
+	assert.False(t, resp.Allowed, "roles which are not allowed must be rejected")
+}
+
+func TestOrganizationAdminValidator_Handle_OrganizationMembers_NotAllowedRoles(t *testing.T) {
+	old := testOrganizationMembers("foo", "foo", "bar")
+	old.Spec.UserRefs[0].Roles = []string{"retired"}
+	v := prepareOrganizationAdminValidatorTest(t, "platform-operator", testOrgNamespace("foo"), old.DeepCopy())
+	v.MemberRoles = []string{"member"}
+	v.AllowedMemberRoles = []string{"viewer"}
+
+	created := testOrganizationMembers("foo", "foo")
+	created.Spec.UserRefs[0].Roles = []string{"viewer", "cluster-admin"}
+	resp := v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Create, "appuio#foo", "OrganizationMembers", created, created))
+	assert.False(t, resp.Allowed, "creating members with roles which are not allowed must be denied")
+	assert.Equal(t, int32(http.StatusForbidden), resp.Result.Code)
+	assert.Contains(t, string(resp.Result.Reason), `"cluster-admin"`)
+
+	changed := old.DeepCopy()
+	changed.Spec.UserRefs[1].Roles = []string{"member", "viewer"}
+	resp = v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, changed))
+	assert.True(t, resp.Allowed, "roles the member already had must not block updates: %s", resp.Result.Reason)
+
+	changed.Spec.UserRefs[1].Roles = []string{"retired"}
+	resp = v.Handle(context.Background(), organizationAdminAdmissionRequest(t, admissionv1.Update, "appuio#foo", "OrganizationMembers", old, changed))
+	assert.False(t, resp.Allowed, "assigning a role which is not allowed to another member must be denied")
+}
+
+func testOrgNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{orgv1.TypeKey: orgv1.OrgType},
+		},
+	}
+}
+
+func testAdminRoleBinding(namespace string, subjects ...string) *rbacv1.RoleBinding {
+	rb := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testAdminRole,
+			Namespace: namespace,
+		},
+		Subjects: []rbacv1.Subject{},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "ClusterRole",
+			APIGroup: rbacv1.GroupName,
+			Name:     testAdminRole,
+		},
+	}
+	for _, s := range subjects {
+		rb.Subjects = append(rb.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: s})
+	}
+	return rb
+}
+
+func testOrganizationMembers(namespace string, users ...string) *controlv1.OrganizationMembers {
+	memb := &controlv1.OrganizationMembers{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "members",
+			Namespace: namespace,
+		},
+	}
+	for _, u := range users {
+		memb.Spec.UserRefs = append(memb.Spec.UserRefs, controlv1.OrganizationMemberRef{Name: u})
+	}
+	return memb
+}
+
+func organizationAdminAdmissionRequest(t *testing.T, op admissionv1.Operation, requestUser, kind string, old, changed client.Object) admission.Request {
+	gvk := metav1.GroupVersionKind{Group: "appuio.io", Version: "v1", Kind: kind}
+	gvr := metav1.GroupVersionResource{Group: "appuio.io", Version: "v1", Resource: "organizationmembers"}
+	if kind == "RoleBinding" {
+		gvk.Group = rbacv1.GroupName
+		gvr = metav1.GroupVersionResource{Group: rbacv1.GroupName, Version: "v1", Resource: "rolebindings"}
+	}
+
+	oldJson, err := json.Marshal(old)
+	require.NoError(t, err)
+	req := admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       "e515f52d-7181-494d-a3d3-f0738856bd97",
+			Kind:      gvk,
+			Resource:  gvr,
+			Name:      old.GetName(),
+			Namespace: old.GetNamespace(),
+			Operation: op,
+			UserInfo: authenticationv1.UserInfo{
+				Username: requestUser,
+			},
+			OldObject: runtime.RawExtension{Raw: oldJson},
+		},
+	}
+	if changed != nil {
+		changedJson, err := json.Marshal(changed)
+		require.NoError(t, err)
+		req.Object = runtime.RawExtension{Raw: changedJson}
+	}
+	return req
+}
+
+func prepareOrganizationAdminValidatorTest(t *testing.T, sarAllowedUser string, initObjs ...client.Object) *OrganizationAdminValidator {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, controlv1.AddToScheme(scheme))
+
+	decoder, err := admission.NewDecoder(scheme)
+	require.NoError(t, err)
+
+	var client client.WithWatch
+	client = fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(initObjs...).
+		Build()
+
+	client = sar.MOCK_SubjectAccessReviewResponder{
+		WithWatch:   client,
+		AllowedUser: sarAllowedUser,
+	}
+
+	v := &OrganizationAdminValidator{
+		AdminRoles:  []string{testAdminRole},
+		UserPrefix:  "appuio#",
+		MemberRoles: []string{testAdminRole},
+	}
+	v.InjectClient(client)
+	v.InjectDecoder(decoder)
+
+	return v
+}
@@ -81,9 +81,9 @@ func TestUserValidator_Handle_Preference(t *testing.T) {
 				},
 			}
 
-			userRefs := []controlv1.UserRef{}
+			userRefs := []controlv1.OrganizationMemberRef{}
 			for _, uname := range tc.orgmemb {
-				userRefs = append(userRefs, controlv1.UserRef{Name: uname})
+				userRefs = append(userRefs, controlv1.OrganizationMemberRef{Name: uname})
 			}
 			orgmemb := controlv1.OrganizationMembers{
 				ObjectMeta: metav1.ObjectMeta{